@@ -3,8 +3,12 @@ package api
 
 import (
 	"app/controller/log"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // ====================================================================================
@@ -13,14 +17,67 @@ import (
 
 var port = "8080"
 
-// APIサーバーを起動する関数
-func StartServer() {
-	http.HandleFunc("/", handler)
-	http.ListenAndServe(":"+port, nil)
+// シャットダウン時に処理中のリクエストの完了を待つ最大時間
+const shutdownTimeout = 15 * time.Second
+
+// アプリケーションのコンテキスト（リクエストの終了後も続く処理に使用する、例: 管理 API から開始したクロール）
+var appCtx = context.Background()
+
+/*
+APIサーバーを起動する関数（ctx がキャンセルされるまでブロックし、キャンセル後は処理中のリクエストの完了を shutdownTimeout まで待って終了する）
+  - ctx			アプリケーションのコンテキスト
+  - return) err	エラー
+*/
+func StartServer(ctx context.Context) (err error) {
+	appCtx = ctx
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: mux,
+	}
+
+	// ctx がキャンセルされたら新規の接続を止め、処理中のリクエストの完了を待つ
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		log.Info("API サーバー停止中")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdownErr <- server.Shutdown(shutdownCtx)
+	}()
+
+	err = server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Error(err)
+		return err
+	}
+	err = <-shutdownErr
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
 }
 
 // リクエストを処理する関数
 func handler(w http.ResponseWriter, r *http.Request) {
+	// 管理 API は認証してから処理
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		if authorizeAdmin(w, r) {
+			adminHandler(w, r)
+		}
+		return
+	}
+
+	// JSON API はエラーも JSON で返す
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		apiV1Handler(w, r)
+		return
+	}
+
 	// リクエストのメソッドによって処理を分岐
 	switch r.Method {
 	case "GET":
@@ -48,6 +105,12 @@ func getHandler(w http.ResponseWriter, r *http.Request) {
 	case "/rag_search":
 		ragSearchHandler(w, r)
 
+	// ヘルスチェック（liveness: プロセスが応答できるか、readiness: DB と NLP サーバーが利用できるか）
+	case "/healthz":
+		healthzHandler(w, r)
+	case "/readyz":
+		readyzHandler(w, r)
+
 	// 静的ファイル
 	case "/favicon.ico":
 		http.ServeFile(w, r, "controller/api/public/smile.ico")
@@ -86,6 +149,49 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// 管理 API のリクエストを処理する関数
+func adminHandler(w http.ResponseWriter, r *http.Request) {
+	// リクエストのメソッドとパスによって処理を分岐
+	switch r.Method + " " + r.URL.Path {
+
+	// ドメインの一覧・作成・更新・無効化
+	case "GET /admin/domains":
+		adminGetDomainsHandler(w, r)
+	case "POST /admin/domains":
+		adminCreateDomainHandler(w, r)
+	case "PUT /admin/domains":
+		adminUpdateDomainHandler(w, r)
+	case "POST /admin/domains/disable":
+		adminDisableDomainHandler(w, r)
+
+	// クロールの一覧・開始・単一 URL のクロール・中断
+	case "GET /admin/crawl":
+		adminGetRunningCrawlsHandler(w, r)
+	case "POST /admin/crawl":
+		adminStartCrawlHandler(w, r)
+	case "POST /admin/crawl/url":
+		adminStartCrawlURLHandler(w, r)
+	case "POST /admin/crawl/cancel":
+		adminCancelCrawlHandler(w, r)
+
+	// クロール実行履歴・クロール中に失敗した URL
+	case "GET /admin/crawl_runs":
+		adminGetCrawlRunsHandler(w, r)
+	case "GET /admin/crawl_failures":
+		adminGetCrawlFailuresHandler(w, r)
+
+	// NLP設定の一覧・検索に使用する NLP設定の切り替え
+	case "GET /admin/nlp_configs":
+		adminGetNlpConfigsHandler(w, r)
+	case "POST /admin/nlp_configs/activate":
+		adminActivateNlpConfigHandler(w, r)
+
+	default:
+		log.Info("Not found: " + r.Method + " " + r.URL.Path)
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
 // ====================================================================================
 // リクエストボディの処理関数
 // ====================================================================================
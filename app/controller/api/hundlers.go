@@ -5,15 +5,19 @@ import (
 	"app/controller/log"
 	"app/usecase/usecase"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // ====================================================================================
 // 各エンドポイントのハンドラ関数
 // ====================================================================================
 
-// ベクトル検索
+// 検索（ベクトル検索・キーワード検索・ハイブリッド検索）
 func searchHandler(w http.ResponseWriter, r *http.Request) {
 	// 検索クエリを取得
 	query := r.URL.Query().Get("q")
@@ -23,15 +27,22 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// ベクトル検索を実行
-	resultLimit := 20
-	similarPages, err := usecase.VectorSearch(query, resultLimit)
+	// 検索のオプション（検索モード、リランクの有無、スコアの集計方法、追加で含める項目）を取得
+	options, err := parseSearchOptions(r)
+	if err != nil {
+		log.Info(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 検索を実行
+	response, err := usecase.VectorSearch(r.Context(), query, options)
 	if err != nil {
 		log.Error(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), searchErrorStatus(err))
 		return
 	}
-	sendJsonResponse(w, similarPages)
+	sendJsonResponse(w, response)
 }
 
 // RAG検索（ベクトル検索 + OpenAI API）- ストリーミング対応
@@ -44,14 +55,24 @@ func ragSearchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// ベクトル検索を実行（上位5件）
-	resultLimit := 5
-	similarPages, err := usecase.VectorSearch(query, resultLimit)
+	// 検索のオプション（検索モード、リランクの有無、スコアの集計方法、追加で含める項目）を取得
+	options, err := parseSearchOptions(r)
+	if err != nil {
+		log.Info(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 検索を実行（上位5件、回答の生成にページ全体の Markdown を使用する）
+	options.Limit, options.Offset = 5, 0
+	options.IncludeMarkdown = true
+	response, err := usecase.VectorSearch(r.Context(), query, options)
 	if err != nil {
 		log.Error(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), searchErrorStatus(err))
 		return
 	}
+	similarPages := response.Results
 
 	// 検索結果のMarkdownを収集
 	contextMarkdowns := make([]string, 0, len(similarPages))
@@ -81,7 +102,7 @@ func ragSearchHandler(w http.ResponseWriter, r *http.Request) {
 		flusher.Flush()
 	}
 
-	err = generateRAGResponseStream(query, contextMarkdowns, w)
+	err = generateRAGResponseStream(r.Context(), query, contextMarkdowns, w)
 	if err != nil {
 		log.Error(err)
 		fmt.Fprintf(w, "data: {\"type\":\"error\",\"message\":\"%s\"}\n\n", err.Error())
@@ -98,11 +119,364 @@ func ragSearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// liveness チェック（プロセスが応答できれば常に 200）
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	sendJsonResponse(w, map[string]string{"status": "ok"})
+}
+
+// readiness チェック（DB, pgvector, NLP サーバーのいずれかが利用できない場合は 503）
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	checks, isReady := usecase.CheckReadiness(r.Context())
+	status, statusCode := "ok", http.StatusOK
+	if !isReady {
+		status, statusCode = "unavailable", http.StatusServiceUnavailable
+	}
+	sendJsonResponseWithStatus(w, statusCode, map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// ====================================================================================
+// 管理 API のハンドラ関数
+// ====================================================================================
+
+// ドメインの一覧
+func adminGetDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	domains, err := usecase.GetDomains(r.Context())
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponse(w, domains)
+}
+
+// ドメインの作成（クロール設定を含む JSON をリクエストボディで受け取る）
+func adminCreateDomainHandler(w http.ResponseWriter, r *http.Request) {
+	var input usecase.DomainInput
+	if err := decodeJsonBody(w, r, &input); err != nil {
+		log.Info(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	domain, err := usecase.CreateDomain(r.Context(), input)
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponseWithStatus(w, http.StatusCreated, domain)
+}
+
+// ドメインの更新（指定された項目のみ更新する）
+func adminUpdateDomainHandler(w http.ResponseWriter, r *http.Request) {
+	domainId, err := parseIntParam(r, "id", 0)
+	if err != nil || domainId < 1 {
+		log.Info("query parameter 'id' is required")
+		http.Error(w, "query parameter 'id' is required", http.StatusBadRequest)
+		return
+	}
+	var input usecase.DomainInput
+	if err := decodeJsonBody(w, r, &input); err != nil {
+		log.Info(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	domain, err := usecase.UpdateDomain(r.Context(), int64(domainId), input)
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponse(w, domain)
+}
+
+// ドメインの無効化（クロール対象外にする）
+func adminDisableDomainHandler(w http.ResponseWriter, r *http.Request) {
+	domainId, err := parseIntParam(r, "id", 0)
+	if err != nil || domainId < 1 {
+		log.Info("query parameter 'id' is required")
+		http.Error(w, "query parameter 'id' is required", http.StatusBadRequest)
+		return
+	}
+
+	domain, err := usecase.DisableDomain(r.Context(), int64(domainId))
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponse(w, domain)
+}
+
+// 実行中のクロールの一覧
+func adminGetRunningCrawlsHandler(w http.ResponseWriter, r *http.Request) {
+	sendJsonResponse(w, usecase.GetRunningCrawls())
+}
+
+// ドメイン全体のクロールを開始（完了を待たずに 202 を返す）
+func adminStartCrawlHandler(w http.ResponseWriter, r *http.Request) {
+	domainId, err := parseIntParam(r, "domain_id", 0)
+	if err != nil || domainId < 1 {
+		log.Info("query parameter 'domain_id' is required")
+		http.Error(w, "query parameter 'domain_id' is required", http.StatusBadRequest)
+		return
+	}
+
+	err = usecase.StartCrawl(appCtx, int64(domainId))
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponseWithStatus(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+// 単一 URL のクロールを開始（リンクはたどらず、完了を待たずに 202 を返す）
+func adminStartCrawlURLHandler(w http.ResponseWriter, r *http.Request) {
+	domainId, err := parseIntParam(r, "domain_id", 0)
+	if err != nil || domainId < 1 {
+		log.Info("query parameter 'domain_id' is required")
+		http.Error(w, "query parameter 'domain_id' is required", http.StatusBadRequest)
+		return
+	}
+	targetUrl := r.URL.Query().Get("url")
+	if targetUrl == "" {
+		log.Info("query parameter 'url' is required")
+		http.Error(w, "query parameter 'url' is required", http.StatusBadRequest)
+		return
+	}
+
+	err = usecase.StartCrawlURL(appCtx, int64(domainId), targetUrl)
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponseWithStatus(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+// 実行中のクロールを中断
+func adminCancelCrawlHandler(w http.ResponseWriter, r *http.Request) {
+	domainId, err := parseIntParam(r, "domain_id", 0)
+	if err != nil || domainId < 1 {
+		log.Info("query parameter 'domain_id' is required")
+		http.Error(w, "query parameter 'domain_id' is required", http.StatusBadRequest)
+		return
+	}
+
+	err = usecase.CancelCrawl(int64(domainId))
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponse(w, map[string]string{"status": "canceling"})
+}
+
+// クロール実行履歴（新しい順）
+func adminGetCrawlRunsHandler(w http.ResponseWriter, r *http.Request) {
+	// ドメインID（省略時は全ドメイン）と件数を取得
+	domainId, err := parseIntParam(r, "domain_id", 0)
+	if err != nil {
+		log.Info(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseIntParam(r, "limit", 20)
+	if err != nil || limit < 1 || limit > 100 {
+		log.Info("query parameter 'limit' must be between 1 and 100")
+		http.Error(w, "query parameter 'limit' must be between 1 and 100", http.StatusBadRequest)
+		return
+	}
+
+	runs, err := usecase.GetCrawlRuns(r.Context(), int64(domainId), limit)
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponse(w, runs)
+}
+
+// クロール中に失敗した URL（内部の URL やエラーメッセージを含むため管理 API で提供する）
+func adminGetCrawlFailuresHandler(w http.ResponseWriter, r *http.Request) {
+	// クロール実行ID（必須）、失敗の種類（省略時は全種類）と件数を取得
+	runId, err := parseIntParam(r, "run_id", 0)
+	if err != nil || runId < 1 {
+		log.Info("query parameter 'run_id' is required")
+		http.Error(w, "query parameter 'run_id' is required", http.StatusBadRequest)
+		return
+	}
+	errorType := r.URL.Query().Get("type")
+	limit, err := parseIntParam(r, "limit", 100)
+	if err != nil || limit < 1 || limit > 1000 {
+		log.Info("query parameter 'limit' must be between 1 and 1000")
+		http.Error(w, "query parameter 'limit' must be between 1 and 1000", http.StatusBadRequest)
+		return
+	}
+
+	failures, err := usecase.GetCrawlFailures(r.Context(), int64(runId), errorType, limit)
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponse(w, failures)
+}
+
+// NLP設定の一覧（is_active が検索に使用する設定）
+func adminGetNlpConfigsHandler(w http.ResponseWriter, r *http.Request) {
+	configs, err := usecase.GetNlpConfigs(r.Context())
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponse(w, configs)
+}
+
+// 検索に使用する NLP設定の切り替え
+func adminActivateNlpConfigHandler(w http.ResponseWriter, r *http.Request) {
+	nlpConfigId, err := parseIntParam(r, "id", 0)
+	if err != nil || nlpConfigId < 1 {
+		log.Info("query parameter 'id' is required")
+		http.Error(w, "query parameter 'id' is required", http.StatusBadRequest)
+		return
+	}
+
+	config, err := usecase.ActivateNlpConfig(r.Context(), int64(nlpConfigId))
+	if err != nil {
+		sendAdminError(w, err)
+		return
+	}
+	sendJsonResponse(w, config)
+}
+
+// ====================================================================================
+// リクエストの処理関数
+// ====================================================================================
+// リクエストボディの JSON を構造体に変換する関数（未知のフィールドはエラー）
+func decodeJsonBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024*1024))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON body: %s", err.Error())
+	}
+	return nil
+}
+
+// 検索 API で先頭から飛ばせる件数の上限（深いページは取得するチャンク数が多くなるため）
+const maxSearchOffset = 500
+
+// クエリパラメータを整数として取得する関数（省略時はデフォルト値を返す）
+func parseIntParam(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("query parameter '%s' must be an integer", name)
+	}
+	return parsed, nil
+}
+
+// クエリパラメータを真偽値として取得する関数（省略時はデフォルト値を返す）
+func parseBoolParam(r *http.Request, name string, defaultValue bool) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("query parameter '%s' must be a boolean", name)
+	}
+	return parsed, nil
+}
+
+// 検索のオプションをクエリパラメータから取得する関数（mode: hybrid, vector, keyword、省略時は vector、rerank: 省略時は false、aggregation: max, sum、省略時は max、include: markdown、件数と絞り込み条件）
+func parseSearchOptions(r *http.Request) (options usecase.SearchOptions, err error) {
+	options.Mode, err = usecase.ParseSearchMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		return usecase.SearchOptions{}, err
+	}
+	options.Rerank, err = parseBoolParam(r, "rerank", false)
+	if err != nil {
+		return usecase.SearchOptions{}, err
+	}
+	options.Aggregation, err = usecase.ParseAggregation(r.URL.Query().Get("aggregation"))
+	if err != nil {
+		return usecase.SearchOptions{}, err
+	}
+
+	// 検索結果に追加で含める項目（カンマ区切り）
+	for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+		case "markdown":
+			options.IncludeMarkdown = true
+		default:
+			return usecase.SearchOptions{}, fmt.Errorf("query parameter 'include' must be 'markdown': %s", include)
+		}
+	}
+
+	// 件数（1〜100、省略時は 20）と先頭から飛ばす件数（offset または前回のレスポンスの next_cursor）
+	options.Limit, err = parseIntParam(r, "limit", 20)
+	if err != nil || options.Limit < 1 || options.Limit > 100 {
+		return usecase.SearchOptions{}, fmt.Errorf("query parameter 'limit' must be between 1 and 100")
+	}
+	options.Offset, err = parseIntParam(r, "offset", 0)
+	if err != nil || options.Offset < 0 || options.Offset > maxSearchOffset {
+		return usecase.SearchOptions{}, fmt.Errorf("query parameter 'offset' must be between 0 and %d", maxSearchOffset)
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if r.URL.Query().Get("offset") != "" {
+			return usecase.SearchOptions{}, fmt.Errorf("query parameters 'offset' and 'cursor' cannot be used together")
+		}
+		options.Offset, err = usecase.ParseCursor(cursor)
+		if err != nil || options.Offset > maxSearchOffset {
+			return usecase.SearchOptions{}, fmt.Errorf("query parameter 'cursor' is invalid")
+		}
+	}
+
+	// 絞り込み条件（ドメイン、パスの前方一致、更新日時、スコアの下限）
+	options.Filter.Domain = r.URL.Query().Get("domain")
+	options.Filter.PathPrefix = r.URL.Query().Get("path_prefix")
+	options.Filter.UpdatedAfter, err = parseTimeParam(r, "updated_after")
+	if err != nil {
+		return usecase.SearchOptions{}, err
+	}
+	if value := r.URL.Query().Get("min_score"); value != "" {
+		minScore, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return usecase.SearchOptions{}, fmt.Errorf("query parameter 'min_score' must be a number")
+		}
+		minScore32 := float32(minScore)
+		options.Filter.MinScore = &minScore32
+	}
+	return options, nil
+}
+
+// クエリパラメータを日時（RFC3339 または YYYY-MM-DD）として取得する関数（省略時はゼロ値を返す）
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query parameter '%s' must be RFC3339 or YYYY-MM-DD", name)
+	}
+	return parsed, nil
+}
+
 // ====================================================================================
 // レスポンスの処理関数
 // ====================================================================================
 // 構造体をjson形式の文字列に変換してレスポンスを返す関数
 func sendJsonResponse(w http.ResponseWriter, data interface{}) {
+	sendJsonResponseWithStatus(w, http.StatusOK, data)
+}
+
+// 構造体をjson形式の文字列に変換して、指定したステータスコードでレスポンスを返す関数
+func sendJsonResponseWithStatus(w http.ResponseWriter, statusCode int, data interface{}) {
 	jsonBytes, err := json.Marshal(data)
 	if err != nil {
 		log.Error(err)
@@ -110,6 +484,33 @@ func sendJsonResponse(w http.ResponseWriter, data interface{}) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(statusCode)
 	w.Write(jsonBytes)
 }
+
+// 検索のエラーのステータスコードを返す関数（検索条件で指定の範囲の結果を返せない場合は 400）
+func searchErrorStatus(err error) int {
+	if errors.Is(err, usecase.ErrInvalidInput) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// 管理 API のエラーを種類に応じたステータスコードで返す関数
+func sendAdminError(w http.ResponseWriter, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, usecase.ErrInvalidInput):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, usecase.ErrNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, usecase.ErrConflict):
+		statusCode = http.StatusConflict
+	}
+	if statusCode == http.StatusInternalServerError {
+		log.Error(err)
+	} else {
+		log.Info(err.Error())
+	}
+	http.Error(w, err.Error(), statusCode)
+}
@@ -5,6 +5,7 @@ import (
 	"app/controller/log"
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -41,13 +42,37 @@ type Delta struct {
 }
 
 /*
-OpenAI APIを呼び出してRAG応答をストリーミングで生成する関数
+OpenAI APIを呼び出してRAG応答をストリーミングで生成し、SSE形式で書き込む関数
+  - ctx					コンテキスト（クライアントが切断した場合は生成を中断する）
   - query				ユーザーの質問
   - contextMarkdowns	検索結果のMarkdownコンテンツ（上位3件など）
   - writer				ストリーミング結果を書き込むWriter
   - return) err			エラー
 */
-func generateRAGResponseStream(query string, contextMarkdowns []string, writer io.Writer) error {
+func generateRAGResponseStream(ctx context.Context, query string, contextMarkdowns []string, writer io.Writer) error {
+	return generateRAGResponse(ctx, query, contextMarkdowns, func(content string) {
+		// JSON文字列として正しくエンコード
+		jsonContent, err := json.Marshal(content)
+		if err != nil {
+			return
+		}
+		// SSE形式でデータを送信
+		fmt.Fprintf(writer, "data: %s\n\n", string(jsonContent))
+		if flusher, ok := writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	})
+}
+
+/*
+OpenAI APIを呼び出してRAG応答をストリーミングで生成する関数
+  - ctx					コンテキスト（クライアントが切断した場合は生成を中断する）
+  - query				ユーザーの質問
+  - contextMarkdowns	検索結果のMarkdownコンテンツ（上位3件など）
+  - onContent			生成された文字列を受け取るたびに呼ばれる関数
+  - return) err			エラー
+*/
+func generateRAGResponse(ctx context.Context, query string, contextMarkdowns []string, onContent func(content string)) error {
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	modelName := os.Getenv("OPENAI_MODEL_NAME")
 	if modelName == "" {
@@ -87,7 +112,7 @@ func generateRAGResponseStream(query string, contextMarkdowns []string, writer i
 	client := &http.Client{Timeout: 180 * time.Second}
 
 	// リクエストを作成
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		log.Error(err)
 		return err
@@ -139,19 +164,9 @@ func generateRAGResponseStream(query string, contextMarkdowns []string, writer i
 			continue
 		}
 
-		// コンテンツを書き込み
+		// コンテンツを渡す
 		if len(streamResp.Choices) > 0 && streamResp.Choices[0].Delta.Content != "" {
-			content := streamResp.Choices[0].Delta.Content
-			// JSON文字列として正しくエンコード
-			jsonContent, err := json.Marshal(content)
-			if err != nil {
-				continue
-			}
-			// SSE形式でデータを送信
-			fmt.Fprintf(writer, "data: %s\n\n", string(jsonContent))
-			if flusher, ok := writer.(http.Flusher); ok {
-				flusher.Flush()
-			}
+			onContent(streamResp.Choices[0].Delta.Content)
 		}
 	}
 
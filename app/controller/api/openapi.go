@@ -0,0 +1,183 @@
+// 主にスプレッドシートからの利用を想定したAPIを提供する
+package api
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ====================================================================================
+// OpenAPI ドキュメントの生成
+// JSON API のリクエスト・レスポンスの型から JSON スキーマを生成するため、型とドキュメントが食い違わない
+// ====================================================================================
+
+/*
+JSON API の OpenAPI ドキュメントを生成する関数
+  - return)	OpenAPI 3.0 のドキュメント（JSON に変換して返す）
+*/
+func openApiDocument() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]any{
+		"/api/v1/search": map[string]any{
+			"post": openApiOperation("検索", "ベクトル検索・キーワード検索・ハイブリッド検索の結果をページごとに返す", SearchRequest{}, SearchResponse{}, schemas),
+		},
+		"/api/v1/rag": map[string]any{
+			"post": openApiOperation("RAG", "検索結果を参照情報として回答を生成し、回答と参照情報を返す", RagRequest{}, RagResponse{}, schemas),
+		},
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "VectorLibrarian API",
+			"version": apiVersion,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}
+}
+
+/*
+JSON のリクエストボディを受け取るエンドポイントの定義を生成する関数
+  - summary		概要
+  - description	説明
+  - request		リクエストボディの型の値
+  - response	成功時のレスポンスの型の値
+  - schemas		生成したスキーマの追加先（components.schemas）
+  - return)		OpenAPI の Operation オブジェクト
+*/
+func openApiOperation(summary string, description string, request any, response any, schemas map[string]any) map[string]any {
+	jsonContent := func(value any) map[string]any {
+		return map[string]any{
+			"application/json": map[string]any{
+				"schema": openApiSchema(reflect.TypeOf(value), schemas),
+			},
+		}
+	}
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     jsonContent(ErrorResponse{}),
+		}
+	}
+
+	return map[string]any{
+		"summary":     summary,
+		"description": description,
+		"requestBody": map[string]any{
+			"required": true,
+			"content":  jsonContent(request),
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "成功",
+				"content":     jsonContent(response),
+			},
+			"400": errorResponse("リクエストが無効（error.code: " + errorCodeInvalidRequest + "）"),
+			"405": errorResponse("メソッドが無効（error.code: " + errorCodeMethodNotAllowed + "）"),
+			"500": errorResponse("サーバーエラー（error.code: " + errorCodeInternal + "）"),
+		},
+	}
+}
+
+/*
+Go の型から JSON スキーマを生成する関数（encoding/json と同じ規則で json タグを解釈する）
+構造体は components.schemas に openApiSchemaName の名前で追加して参照を返し、埋め込みの構造体のフィールドは展開する
+omitempty が付いていないフィールドは必須（required）とする
+  - t			型
+  - schemas		生成したスキーマの追加先（components.schemas）
+  - return)		JSON スキーマ
+*/
+func openApiSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": openApiSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": openApiSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		// 再帰的な型でも無限に展開しないよう、生成前に名前を登録する
+		name := openApiSchemaName(t)
+		if _, exists := schemas[name]; !exists {
+			schemas[name] = map[string]any{}
+			properties := map[string]any{}
+			required := []string{}
+			addOpenApiProperties(t, properties, &required, schemas)
+			schema := map[string]any{"type": "object", "properties": properties}
+			if len(required) > 0 {
+				schema["required"] = required
+			}
+			schemas[name] = schema
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+/*
+構造体のスキーマの名前（components.schemas のキー）を返す関数
+別のパッケージに同じ名前の型があっても衝突しないよう、このパッケージ以外の型はパッケージ名で修飾する（"usecase.SearchResult" など）
+  - t			構造体の型
+  - return)		スキーマの名前
+*/
+func openApiSchemaName(t reflect.Type) string {
+	if t.PkgPath() == reflect.TypeOf(ErrorResponse{}).PkgPath() {
+		return t.Name()
+	}
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+/*
+構造体のフィールドを JSON スキーマのプロパティに追加する関数（埋め込みの構造体は再帰的に展開する）
+  - t			構造体の型
+  - properties	プロパティの追加先
+  - required	必須のプロパティ名の追加先
+  - schemas		生成したスキーマの追加先（components.schemas）
+*/
+func addOpenApiProperties(t reflect.Type, properties map[string]any, required *[]string, schemas map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		// json タグのない埋め込みの構造体は、encoding/json と同じくフィールドを展開する
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addOpenApiProperties(field.Type, properties, required, schemas)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = openApiSchema(field.Type, schemas)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"app/usecase/usecase"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// 単体テスト（外部依存がない関数のテスト）を定義
+// `docker compose exec app go test ./controller/api`
+
+func TestOpenApiDocument(t *testing.T) {
+	document := openApiDocument()
+	if _, err := json.Marshal(document); err != nil {
+		t.Fatalf("OpenAPI ドキュメントを JSON に変換できませんでした: %v", err)
+	}
+	schemas := document["components"].(map[string]any)["schemas"].(map[string]any)
+
+	// リクエスト・レスポンスとそこから参照される型のスキーマが生成される（このパッケージ以外の型はパッケージ名で修飾する）
+	for _, name := range []string{"SearchRequest", "SearchFilters", "SearchResponse", "usecase.SearchResult", "usecase.ChunkHit", "usecase.SearchFacets", "usecase.FacetCount", "RagRequest", "RagResponse", "ErrorResponse", "ErrorDetail"} {
+		if _, exists := schemas[name]; !exists {
+			t.Errorf("スキーマ '%s' が生成されていません", name)
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		schema           string
+		expectedRequired []string
+		expectedProperty string
+		expectedType     map[string]any
+	}{
+		{
+			name:             "omitempty がないフィールドのみ必須",
+			schema:           "SearchRequest",
+			expectedRequired: []string{"query"},
+			expectedProperty: "filters",
+			expectedType:     map[string]any{"$ref": "#/components/schemas/SearchFilters"},
+		},
+		{
+			name:             "日時は date-time 形式の文字列",
+			schema:           "SearchFilters",
+			expectedRequired: nil,
+			expectedProperty: "updated_after",
+			expectedType:     map[string]any{"type": "string", "format": "date-time"},
+		},
+		{
+			name:             "埋め込みの構造体のフィールドを展開する",
+			schema:           "usecase.SearchResult",
+			expectedRequired: []string{"domain", "path", "title", "description", "keywords", "content_type", "score", "snippet", "chunks"},
+			expectedProperty: "chunks",
+			expectedType:     map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/usecase.ChunkHit"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schema := schemas[tc.schema].(map[string]any)
+			required, _ := schema["required"].([]string)
+			if !reflect.DeepEqual(required, tc.expectedRequired) {
+				t.Errorf("期待される必須のプロパティ '%v' ですが、実際は '%v' でした", tc.expectedRequired, required)
+			}
+			property := schema["properties"].(map[string]any)[tc.expectedProperty]
+			if !reflect.DeepEqual(property, tc.expectedType) {
+				t.Errorf("期待されるプロパティ '%s' のスキーマ '%v' ですが、実際は '%v' でした", tc.expectedProperty, tc.expectedType, property)
+			}
+		})
+	}
+}
+
+func TestOpenApiSchemaName(t *testing.T) {
+	// 別のパッケージの同じ名前の型（api.SearchResponse と usecase.SearchResponse）は別のスキーマになる
+	schemas := map[string]any{}
+	apiRef := openApiSchema(reflect.TypeOf(SearchResponse{}), schemas)
+	usecaseRef := openApiSchema(reflect.TypeOf(usecase.SearchResponse{}), schemas)
+	if reflect.DeepEqual(apiRef, usecaseRef) {
+		t.Fatalf("期待されるスキーマの参照は型ごとに異なる値ですが、実際はどちらも '%v' でした", apiRef)
+	}
+
+	testCases := []struct {
+		name          string
+		expectedField string
+	}{
+		{"SearchResponse", "api_version"},
+		{"usecase.SearchResponse", "next_cursor"},
+	}
+	for _, tc := range testCases {
+		schema, exists := schemas[tc.name].(map[string]any)
+		if !exists {
+			t.Errorf("スキーマ '%s' が生成されていません", tc.name)
+			continue
+		}
+		if _, exists := schema["properties"].(map[string]any)[tc.expectedField]; !exists {
+			t.Errorf("期待されるスキーマ '%s' のプロパティ '%s' がありません", tc.name, tc.expectedField)
+		}
+	}
+}
@@ -20,11 +20,11 @@ if (window.location.pathname === '/') {
     loadingIndicator.style.display = 'block'; // Show loading indicator
 
     try {
-      const response = await fetch(`/search?q=${query}`);
+      const response = await fetch(`/search?q=${encodeURIComponent(query)}`);
       if (!response.ok) {
         throw new Error(`HTTP error! status: ${response.status}`);
       }
-      const searchResults = await response.json();
+      const searchResults = (await response.json()).results;
 
       if (searchResults.length > 0) {
         searchResults.forEach((result) => {
@@ -37,8 +37,9 @@ if (window.location.pathname === '/') {
           }
           resultItem.classList.add('result-item');
           resultItem.innerHTML = `
-                        <h3><a href="https://${result.domain}${result.path}" target="_blank" rel="noopener noreferrer">${result.title}</a></h3>
+                        <h3><a href="https://${result.domain}${result.path}" target="_blank" rel="noopener noreferrer">${result.content_type === 'application/pdf' ? '[PDF] ' : ''}${result.title}</a></h3>
                         <p>${result.description}</p>
+                        <p class="result-snippet">${result.snippet}</p>
                     `;
           resultsDiv.appendChild(resultItem);
         });
@@ -1,2 +1,36 @@
 // 主にスプレッドシートからの利用を想定したAPIを提供する
 package api
+
+import (
+	"app/controller/log"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+/*
+管理 API の認証を行う関数（Authorization: Bearer <ADMIN_API_TOKEN>）
+環境変数 ADMIN_API_TOKEN が未設定の場合、管理 API は常に拒否される
+  - w			レスポンスライター
+  - r			リクエスト
+  - return)		認証に成功した場合は true（失敗時はエラーレスポンスを返し済み）
+*/
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	adminToken := os.Getenv("ADMIN_API_TOKEN")
+	if adminToken == "" {
+		log.Info("ADMIN_API_TOKEN is not set, admin API is disabled")
+		http.Error(w, "admin API is disabled", http.StatusForbidden)
+		return false
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		log.Info("Unauthorized admin access: " + r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,299 @@
+// 主にスプレッドシートからの利用を想定したAPIを提供する
+package api
+
+import (
+	"app/controller/log"
+	"app/usecase/usecase"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ====================================================================================
+// JSON API（/api/v1/）のリクエスト・レスポンスの型
+// 型を変更すると /api/v1/openapi.json の OpenAPI ドキュメントにも反映される
+// ====================================================================================
+
+// JSON API のバージョン（レスポンスの api_version、互換性のない変更をする場合は /api/v2/ を追加する）
+const apiVersion = "v1"
+
+// 検索リクエスト（POST /api/v1/search）
+type SearchRequest struct {
+	Query       string        `json:"query"`                 // 検索クエリ（必須）
+	Mode        string        `json:"mode,omitempty"`        // 検索モード（vector, keyword, hybrid、省略時は vector）
+	Rerank      bool          `json:"rerank,omitempty"`      // クロスエンコーダーでリランクするかどうか
+	Aggregation string        `json:"aggregation,omitempty"` // ページのスコアの集計方法（max, sum、省略時は max）
+	Include     []string      `json:"include,omitempty"`     // 検索結果に追加で含める項目（markdown）
+	Limit       int           `json:"limit,omitempty"`       // 返却するページ数（1〜100、省略時は 20）
+	Offset      int           `json:"offset,omitempty"`      // 先頭から飛ばすページ数（cursor と同時には指定できない）
+	Cursor      string        `json:"cursor,omitempty"`      // 前回のレスポンスの next_cursor
+	Filters     SearchFilters `json:"filters,omitempty"`     // 絞り込み条件
+}
+
+// 検索の絞り込み条件
+type SearchFilters struct {
+	Domain       string     `json:"domain,omitempty"`        // ドメイン（完全一致）
+	PathPrefix   string     `json:"path_prefix,omitempty"`   // パスの前方一致
+	UpdatedAfter *time.Time `json:"updated_after,omitempty"` // ページの更新日時の下限（RFC3339）
+	MinScore     *float32   `json:"min_score,omitempty"`     // チャンクのスコアの下限
+}
+
+// 検索レスポンス
+type SearchResponse struct {
+	ApiVersion string                 `json:"api_version"`
+	Results    []usecase.SearchResult `json:"results"`
+	Facets     usecase.SearchFacets   `json:"facets"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// RAG リクエスト（POST /api/v1/rag）
+type RagRequest struct {
+	Query       string        `json:"query"`                 // 質問（必須）
+	Mode        string        `json:"mode,omitempty"`        // 参照情報の検索モード（vector, keyword, hybrid、省略時は vector）
+	Rerank      bool          `json:"rerank,omitempty"`      // 参照情報をクロスエンコーダーでリランクするかどうか
+	Aggregation string        `json:"aggregation,omitempty"` // ページのスコアの集計方法（max, sum、省略時は max）
+	Limit       int           `json:"limit,omitempty"`       // 参照情報のページ数（1〜10、省略時は 5）
+	Filters     SearchFilters `json:"filters,omitempty"`     // 参照情報の絞り込み条件
+}
+
+// RAG レスポンス
+type RagResponse struct {
+	ApiVersion string                 `json:"api_version"`
+	Answer     string                 `json:"answer"`  // 生成した回答
+	Sources    []usecase.SearchResult `json:"sources"` // 参照情報のページ（Markdown は含まない）
+}
+
+// エラーレスポンス（JSON API のすべてのエラーで共通）
+type ErrorResponse struct {
+	ApiVersion string      `json:"api_version"`
+	Error      ErrorDetail `json:"error"`
+}
+
+// エラーの内容
+type ErrorDetail struct {
+	Code    string `json:"code"`    // エラーの種類（invalid_request, not_found, method_not_allowed, internal_error）
+	Message string `json:"message"` // エラーの内容
+}
+
+// エラーの種類
+const (
+	errorCodeInvalidRequest   = "invalid_request"
+	errorCodeNotFound         = "not_found"
+	errorCodeMethodNotAllowed = "method_not_allowed"
+	errorCodeInternal         = "internal_error"
+)
+
+// サーバーエラーのメッセージ（内部のエラーの内容はレスポンスに含めず、ログに記録する）
+const internalErrorMessage = "internal server error"
+
+// RAG の参照情報のページ数の上限（回答の生成に全文を渡すため検索より少なくする）
+const maxRagLimit = 10
+
+// ====================================================================================
+// JSON API のハンドラ関数
+// ====================================================================================
+
+// JSON API のエンドポイント（メソッドが異なる場合は 405 を返すため、パスごとに許可するメソッドを定義）
+var apiV1Routes = map[string]struct {
+	method  string
+	handler http.HandlerFunc
+}{
+	"/api/v1/search":       {http.MethodPost, apiV1SearchHandler},
+	"/api/v1/rag":          {http.MethodPost, apiV1RagHandler},
+	"/api/v1/openapi.json": {http.MethodGet, apiV1OpenApiHandler},
+}
+
+// JSON API のリクエストを処理する関数
+func apiV1Handler(w http.ResponseWriter, r *http.Request) {
+	route, exists := apiV1Routes[r.URL.Path]
+	if !exists {
+		log.Info("Not found: " + r.Method + " " + r.URL.Path)
+		sendApiError(w, http.StatusNotFound, errorCodeNotFound, "not found: "+r.URL.Path)
+		return
+	}
+	if r.Method != route.method {
+		log.Info("Method not allowed: " + r.Method + " " + r.URL.Path)
+		w.Header().Set("Allow", route.method)
+		sendApiError(w, http.StatusMethodNotAllowed, errorCodeMethodNotAllowed, "method not allowed: "+r.Method)
+		return
+	}
+	route.handler(w, r)
+}
+
+// 検索（JSON のリクエストボディで検索条件を受け取る）
+func apiV1SearchHandler(w http.ResponseWriter, r *http.Request) {
+	var req SearchRequest
+	if err := decodeJsonBody(w, r, &req); err != nil {
+		log.Info(err.Error())
+		sendApiError(w, http.StatusBadRequest, errorCodeInvalidRequest, err.Error())
+		return
+	}
+	options, err := req.searchOptions()
+	if err != nil {
+		log.Info(err.Error())
+		sendApiError(w, http.StatusBadRequest, errorCodeInvalidRequest, err.Error())
+		return
+	}
+
+	response, err := usecase.VectorSearch(r.Context(), req.Query, options)
+	if err != nil {
+		log.Error(err)
+		sendSearchApiError(w, err)
+		return
+	}
+	sendJsonResponse(w, SearchResponse{
+		ApiVersion: apiVersion,
+		Results:    response.Results,
+		Facets:     response.Facets,
+		NextCursor: response.NextCursor,
+	})
+}
+
+// RAG（検索結果を参照情報として回答を生成し、回答と参照情報をまとめて返す）
+func apiV1RagHandler(w http.ResponseWriter, r *http.Request) {
+	var req RagRequest
+	if err := decodeJsonBody(w, r, &req); err != nil {
+		log.Info(err.Error())
+		sendApiError(w, http.StatusBadRequest, errorCodeInvalidRequest, err.Error())
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 5
+	}
+	if req.Limit < 1 || req.Limit > maxRagLimit {
+		err := fmt.Errorf("field 'limit' must be between 1 and %d", maxRagLimit)
+		log.Info(err.Error())
+		sendApiError(w, http.StatusBadRequest, errorCodeInvalidRequest, err.Error())
+		return
+	}
+	searchReq := SearchRequest{
+		Query:       req.Query,
+		Mode:        req.Mode,
+		Rerank:      req.Rerank,
+		Aggregation: req.Aggregation,
+		Include:     []string{"markdown"}, // 回答の生成にページ全体の Markdown を使用する
+		Limit:       req.Limit,
+		Filters:     req.Filters,
+	}
+	options, err := searchReq.searchOptions()
+	if err != nil {
+		log.Info(err.Error())
+		sendApiError(w, http.StatusBadRequest, errorCodeInvalidRequest, err.Error())
+		return
+	}
+
+	response, err := usecase.VectorSearch(r.Context(), req.Query, options)
+	if err != nil {
+		log.Error(err)
+		sendSearchApiError(w, err)
+		return
+	}
+
+	// 参照情報の Markdown を収集し、レスポンスからは除外する
+	sources := response.Results
+	contextMarkdowns := make([]string, 0, len(sources))
+	for i := range sources {
+		contextMarkdowns = append(contextMarkdowns, sources[i].Markdown)
+		sources[i].Markdown = ""
+	}
+
+	var answer strings.Builder
+	err = generateRAGResponse(r.Context(), req.Query, contextMarkdowns, func(content string) {
+		answer.WriteString(content)
+	})
+	if err != nil {
+		log.Error(err)
+		sendApiError(w, http.StatusInternalServerError, errorCodeInternal, internalErrorMessage)
+		return
+	}
+	sendJsonResponse(w, RagResponse{
+		ApiVersion: apiVersion,
+		Answer:     answer.String(),
+		Sources:    sources,
+	})
+}
+
+// OpenAPI ドキュメント（リクエスト・レスポンスの型から生成）
+func apiV1OpenApiHandler(w http.ResponseWriter, r *http.Request) {
+	sendJsonResponse(w, openApiDocument())
+}
+
+// ====================================================================================
+// JSON API のリクエスト・レスポンスの処理関数
+// ====================================================================================
+
+/*
+検索リクエストを検証して検索のオプションに変換する関数
+  - return) options	検索のオプション
+  - return) err		エラー（無効な値がある場合）
+*/
+func (req SearchRequest) searchOptions() (options usecase.SearchOptions, err error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return usecase.SearchOptions{}, fmt.Errorf("field 'query' is required")
+	}
+	options.Mode, err = usecase.ParseSearchMode(req.Mode)
+	if err != nil {
+		return usecase.SearchOptions{}, err
+	}
+	options.Rerank = req.Rerank
+	options.Aggregation, err = usecase.ParseAggregation(req.Aggregation)
+	if err != nil {
+		return usecase.SearchOptions{}, err
+	}
+	for _, include := range req.Include {
+		if include != "markdown" {
+			return usecase.SearchOptions{}, fmt.Errorf("field 'include' must contain only 'markdown': %s", include)
+		}
+		options.IncludeMarkdown = true
+	}
+
+	// 件数（省略時は 20）と先頭から飛ばす件数（offset または前回のレスポンスの next_cursor）
+	options.Limit = req.Limit
+	if options.Limit == 0 {
+		options.Limit = 20
+	}
+	if options.Limit < 1 || options.Limit > 100 {
+		return usecase.SearchOptions{}, fmt.Errorf("field 'limit' must be between 1 and 100")
+	}
+	options.Offset = req.Offset
+	if options.Offset < 0 || options.Offset > maxSearchOffset {
+		return usecase.SearchOptions{}, fmt.Errorf("field 'offset' must be between 0 and %d", maxSearchOffset)
+	}
+	if req.Cursor != "" {
+		if req.Offset != 0 {
+			return usecase.SearchOptions{}, fmt.Errorf("fields 'offset' and 'cursor' cannot be used together")
+		}
+		options.Offset, err = usecase.ParseCursor(req.Cursor)
+		if err != nil || options.Offset > maxSearchOffset {
+			return usecase.SearchOptions{}, fmt.Errorf("field 'cursor' is invalid")
+		}
+	}
+
+	// 絞り込み条件
+	options.Filter.Domain = req.Filters.Domain
+	options.Filter.PathPrefix = req.Filters.PathPrefix
+	if req.Filters.UpdatedAfter != nil {
+		options.Filter.UpdatedAfter = *req.Filters.UpdatedAfter
+	}
+	options.Filter.MinScore = req.Filters.MinScore
+
+	return options, nil
+}
+
+// 検索のエラーを種類に応じたステータスコードとエラーの種類で返す関数（サーバーエラーの場合は内容を返さない）
+func sendSearchApiError(w http.ResponseWriter, err error) {
+	if searchErrorStatus(err) == http.StatusBadRequest {
+		sendApiError(w, http.StatusBadRequest, errorCodeInvalidRequest, err.Error())
+		return
+	}
+	sendApiError(w, http.StatusInternalServerError, errorCodeInternal, internalErrorMessage)
+}
+
+// JSON API のエラーをエラーレスポンスの形式で返す関数
+func sendApiError(w http.ResponseWriter, statusCode int, code string, message string) {
+	sendJsonResponseWithStatus(w, statusCode, ErrorResponse{
+		ApiVersion: apiVersion,
+		Error:      ErrorDetail{Code: code, Message: message},
+	})
+}
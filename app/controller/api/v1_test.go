@@ -0,0 +1,57 @@
+package api
+
+import (
+	"app/usecase/usecase"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 単体テスト（外部依存がない関数のテスト）を定義
+// `docker compose exec app go test ./controller/api`
+
+func TestSendSearchApiError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		expectedStatus  int
+		expectedCode    string
+		expectedMessage string
+	}{
+		{
+			name:            "入力値が不正な場合は内容を返す",
+			err:             fmt.Errorf("%w: offset が大きすぎます", usecase.ErrInvalidInput),
+			expectedStatus:  http.StatusBadRequest,
+			expectedCode:    errorCodeInvalidRequest,
+			expectedMessage: "入力値が不正です: offset が大きすぎます",
+		},
+		{
+			name:            "サーバーエラーの場合は内容を返さない",
+			err:             errors.New("pq: relation \"chunks\" does not exist"),
+			expectedStatus:  http.StatusInternalServerError,
+			expectedCode:    errorCodeInternal,
+			expectedMessage: internalErrorMessage,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			sendSearchApiError(recorder, tc.err)
+
+			if recorder.Code != tc.expectedStatus {
+				t.Errorf("期待されるステータスコード '%d' ですが、実際は '%d' でした", tc.expectedStatus, recorder.Code)
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("レスポンスを JSON として解析できませんでした: %v", err)
+			}
+			if response.Error.Code != tc.expectedCode || response.Error.Message != tc.expectedMessage {
+				t.Errorf("期待されるエラー '%s: %s' ですが、実際は '%s: %s' でした", tc.expectedCode, tc.expectedMessage, response.Error.Code, response.Error.Message)
+			}
+		})
+	}
+}
@@ -4,103 +4,330 @@ import (
 	"app/controller/log"
 	"app/controller/nlp"
 	"app/controller/postgres"
+	"app/domain/model"
+	"app/usecase/entity"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gocolly/colly/v2"
+	"github.com/gocolly/colly/v2/queue"
 	_ "github.com/lib/pq"
 )
 
-// スケジューラーから呼び出すための関数、一旦定数などもここで設定
-func Start() (err error) {
-	// 初期設定・定数
-	startPath := "/"
-	allowedPaths := []string{
-		"/", // すべてのパスを許可する場合は "/" のみ指定
-	}
-	maxScrapeDepth := 15
+// レスポンスボディの最大サイズ（バイト）
+const maxBodySize = 50 * 1024 * 1024
+
+// nlp サーバーに 1 回のリクエストでまとめてベクトル化を依頼するページ数
+const vectorizeBatchSize = 8
+
+// 同時にクロールするドメイン数の上限（ドメイン内のリクエスト間隔は CrawlDomain 側で守る）
+const maxParallelDomains = 3
 
-	// キャッシュ無効化、ログ出力強化
+// スケジューラーから呼び出すための関数、有効なドメインをすべてクロールする（ctx がキャンセルされた場合は中断する）
+func Start(ctx context.Context) (err error) {
+	// 条件付きリクエスト無効化、ログ出力強化
 	isTest := false
 
 	// ドメイン情報を取得
-	domains, err := postgres.GetDomains()
+	domains, err := postgres.GetDomains(ctx)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
-	if len(domains) == 0 {
+
+	// クロール対象（有効）のドメインのみ抽出
+	activeDomains := make([]entity.DBDomain, 0, len(domains))
+	for _, domain := range domains {
+		if domain.IsActive {
+			activeDomains = append(activeDomains, domain)
+		}
+	}
+	if len(activeDomains) == 0 {
 		log.Info("クロール対象のドメインが存在しません。")
 		return nil
 	}
 
-	// とりあえず最初のドメインだけクロール
-	targetDomainId := domains[0].ID
-	targetDomain := domains[0].Domain
+	// ドメイン単位で並列にクロール（同時実行数は maxParallelDomains まで）
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	semaphore := make(chan struct{}, maxParallelDomains)
+	for _, domain := range activeDomains {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(domain entity.DBDomain) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
 
-	log.Info("クロール対象ドメイン: " + targetDomain)
+			log.Info("クロール対象ドメイン: " + domain.Domain)
 
-	// クロールを開始
-	err = CrawlDomain(targetDomainId, targetDomain, startPath, allowedPaths, maxScrapeDepth, isTest)
+			// クロールを開始
+			if err := CrawlDomain(ctx, domain, isTest); err != nil {
+				log.Error(err)
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(domain)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// クロール時のオプション
+type crawlOptions struct {
+	isTest    bool   // テストモードの真偽値（条件付きリクエスト無効化、ログ出力強化、削除処理なし）
+	singleUrl string // 指定時はこの URL のみ取得する（リンクをたどらず、削除処理も行わない）
+}
+
+/*
+対象ドメインをクロールする関数（終了するまで待機する）
+  - ctx				キャンセルされた場合はクロールを中断する
+  - domain			クロール対象のドメイン情報（クロール設定を含む）
+  - isTest			テストモードの真偽値
+  - return) err		エラー（同じドメインのクロールが実行中の場合は ErrCrawlAlreadyRunning）
+
+※ AllowedPaths について
+["/docs/", "/articles/"] なら "~/docs/abc", "~/articles/xyz" は許可されるが "~/blog/123" は許可されない
+["/"] 指定であれば全て許可される
+※ DeniedPaths について
+AllowedPaths で許可されていても、いずれかの文字列を含むパスは除外される
+*/
+func CrawlDomain(ctx context.Context, domain entity.DBDomain, isTest bool) (err error) {
+	ctx, release, err := registerCrawl(ctx, domain.ID, domain.Domain, "")
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	defer release()
 
+	return crawl(ctx, domain, crawlOptions{isTest: isTest})
+}
+
+/*
+対象ドメインのクロールをバックグラウンドで開始する関数（管理 API 用）
+  - ctx				キャンセルされた場合はクロールを中断する（リクエストではなくアプリケーションのコンテキストを渡す）
+  - domain			クロール対象のドメイン情報（クロール設定を含む）
+  - return) err		エラー（同じドメインのクロールが実行中の場合は ErrCrawlAlreadyRunning）
+*/
+func StartCrawlDomain(ctx context.Context, domain entity.DBDomain) (err error) {
+	ctx, release, err := registerCrawl(ctx, domain.ID, domain.Domain, "")
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer release()
+		if err := crawl(ctx, domain, crawlOptions{}); err != nil {
+			log.Error(err)
+		}
+	}()
 	return nil
 }
 
 /*
-対象ドメインをクロールする関数
-  - targetDomainId	クロール対象のドメインのID
-  - targetDomain	クロール対象のドメイン
-  - startPath		クロールを開始するパス
-  - allowedPaths	パスに必ず含まれなければならない文字列のリスト
-  - maxScrapeDepth	最大スクレイピング深度
-  - isTest			テストモードの真偽値
-  - return) err		エラー
+対象ドメインの単一 URL のクロールをバックグラウンドで開始する関数（管理 API 用）
+  - ctx				キャンセルされた場合はクロールを中断する（リクエストではなくアプリケーションのコンテキストを渡す）
+  - domain			クロール対象のドメイン情報（クロール設定を含む）
+  - targetUrl		クロールする URL（対象ドメインの https URL であること）
+  - return) err		エラー（同じドメインのクロールが実行中の場合は ErrCrawlAlreadyRunning）
+*/
+func StartCrawlURL(ctx context.Context, domain entity.DBDomain, targetUrl string) (err error) {
+	u, err := url.Parse(targetUrl)
+	if err != nil || u.Scheme != "https" || u.Host != domain.Domain {
+		return fmt.Errorf("対象ドメインの https URL ではありません: %s", targetUrl)
+	}
 
-※ allowedPaths について
-["/docs/", "/articles/"] なら "~/docs/abc", "~/articles/xyz" は許可されるが "~/blog/123" は許可されない
-["/"] 指定であれば全て許可される
+	ctx, release, err := registerCrawl(ctx, domain.ID, domain.Domain, targetUrl)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer release()
+		if err := crawl(ctx, domain, crawlOptions{singleUrl: targetUrl}); err != nil {
+			log.Error(err)
+		}
+	}()
+	return nil
+}
+
+/*
+対象ドメインをクロールする関数（CrawlDomain, StartCrawlDomain, StartCrawlURL の共通処理）
+  - ctx				キャンセルされた場合は残りのリクエストを中断する
+  - domain			クロール対象のドメイン情報（クロール設定を含む）
+  - opts			クロール時のオプション
+  - return) err		エラー
 */
-func CrawlDomain(targetDomainId int64, targetDomain string, startPath string, allowedPaths []string, maxScrapeDepth int, isTest bool) (err error) {
+func crawl(ctx context.Context, domain entity.DBDomain, opts crawlOptions) (err error) {
+	isTest := opts.isTest
+	targetDomainId := domain.ID
+	targetDomain := domain.Domain
+	config := withDefaultCrawlConfig(domain.CrawlConfig)
+
+	baseUrl := "https://" + targetDomain
+
+	// クロール結果の記録を開始し、終了時に結果（エラーを含む）を保存
+	recorder, err := startRunRecorder(ctx, targetDomainId)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer func() { recorder.finish(err) }()
+
+	// 保存済みページの取得状態を取得（条件付きリクエストと変更検知に使用）
+	fetchStates, err := postgres.GetPageFetchStates(ctx, targetDomainId)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	// 開始パスはリンクを再発見するため、常に条件なしで取得する
+	startPaths := make(map[string]bool, len(config.StartPaths))
+	for _, startPath := range config.StartPaths {
+		startPaths[startPath] = true
+	}
+
+	// robots.txt を取得し、自身のユーザーエージェントに適用されるルールを取得
+	client := &http.Client{Timeout: 30 * time.Second}
+	robots := fetchRobots(client, baseUrl)
+	robotsGroup := robots.FindGroup(userAgent)
 
 	// デフォルトのコレクターを作成
 	c := colly.NewCollector(
 		colly.AllowedDomains(targetDomain), // 許可するドメインを設定
-		colly.MaxDepth(maxScrapeDepth),     // 最大深度を設定
+		colly.MaxDepth(config.MaxDepth),    // 最大深度を設定
+		colly.UserAgent(userAgent),         // ユーザーエージェントを設定
+		colly.StdlibContext(ctx),           // 中断時に実行中のリクエストもキャンセルする
 	)
 
-	// Colly のキャッシュディレクトリを設定（テストモード時はキャッシュしない）
-	if !isTest {
-		c.CacheDir = "./cache"
-	}
+	// PDF はサイズが大きいことがあるため、レスポンスサイズの上限を引き上げる
+	c.MaxBodySize = maxBodySize
 
-	// リクエスト間で 1 秒の時間を空ける
+	// ドメインごとに設定された時間と robots.txt の Crawl-delay の長い方をリクエスト間で空ける
+	delay := time.Duration(config.DelayMs) * time.Millisecond
+	if robotsGroup.CrawlDelay > delay {
+		delay = robotsGroup.CrawlDelay
+	}
 	c.Limit(&colly.LimitRule{
 		DomainGlob: targetDomain, // 対象ドメインを指定
-		Delay:      time.Second,  // リクエスト間の最小遅延
+		Delay:      delay,        // リクエスト間の最小遅延
 	})
 
-	// リクエスト前に "アクセス >> " を表示
+	// クロールキュー（ドメイン内はスレッド数 1 で逐次処理し、リクエスト間隔を守る）
+	q, err := queue.New(1, &queue.InMemoryQueueStorage{MaxSize: 100000})
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	// 保存済みのページはキューに追加せず、開始パス、サイトマップ、リンクからたどれた場合のみ取得する
+	frontier := newLinkFrontier(baseUrl, config.MaxDepth, fetchStates, func(u *url.URL, depth int) error {
+		return q.AddRequest(&colly.Request{URL: u, Method: "GET", Depth: depth})
+	})
+
+	// リクエスト前に "アクセス >> " を表示、中断された場合と robots.txt で拒否されているパスは中断
 	c.OnRequest(func(r *colly.Request) {
+		if ctx.Err() != nil {
+			r.Abort()
+			return
+		}
+		if !robotsGroup.Test(r.URL.RequestURI()) {
+			log.Info(">> robots.txt により除外:" + r.URL.String())
+			r.Abort()
+			return
+		}
 		log.Info(">> URL:" + r.URL.String())
+
+		// 保存済みのページは条件付きリクエストにする（テストモード時、開始パス、単一 URL のクロール、リンクを記録していないページは除く）
+		if knownPage, ok := frontier.conditionalPage(r.URL.Path); ok && !isTest && !startPaths[r.URL.Path] && opts.singleUrl == "" {
+			if knownPage.ETag != "" {
+				r.Headers.Set("If-None-Match", knownPage.ETag)
+			}
+			if knownPage.LastModified != "" {
+				r.Headers.Set("If-Modified-Since", knownPage.LastModified)
+			}
+		}
 	})
 
-	// html タグを見つけたときの処理
-	c.OnHTML("html", func(e *colly.HTMLElement) {
-		// ページデータを抽出
-		pageInfo, err := htmlToPageData(e)
-		if err != nil {
-			log.Error(err)
+	// 正常に取得できたレスポンス数（0 件の場合はサイト側の障害とみなし、ページの削除を行わない）
+	fetchedCount := 0
+	c.OnResponse(func(r *colly.Response) {
+		fetchedCount++
+		frontier.markSeen(r.Request.URL.Path)
+		recorder.addVisited()
+	})
+
+	// エラー時の処理（304 Not Modified もエラーとして扱われる）
+	c.OnError(func(r *colly.Response, err error) {
+		// 中断によりキャンセルされたリクエストは記録しない
+		if ctx.Err() != nil {
 			return
 		}
 
-		// ドメインIDを設定
+		requestUrl := r.Request.URL.String()
+		if r.StatusCode != 0 {
+			recorder.addVisited()
+		}
+		frontier.markSeen(r.Request.URL.Path)
+
+		switch r.StatusCode {
+		case http.StatusNotModified:
+			// 変更がないため取得状態のみ更新し、Markdown 変換とベクトル化は行わない
+			fetchedCount++
+			// ページを解析できないため、前回のクロールで記録したリンクをたどる（単一 URL のクロールではリンクをたどらない）
+			if opts.singleUrl == "" {
+				frontier.enqueueKnownLinks(r.Request.URL.Path, r.Request.Depth)
+			}
+			if err := postgres.UpdatePageFetchInfo(ctx, targetDomainId, r.Request.URL.Path, responseToFetchInfo(r)); err != nil {
+				log.Error(err)
+				recorder.addFailure(requestUrl, failureTypeDb, r.StatusCode, err)
+				return
+			}
+			recorder.addUnchanged()
+		case http.StatusNotFound, http.StatusGone:
+			// ページが削除されたため論理削除して検索対象から外す
+			log.Info(">> 削除されたページ:" + requestUrl)
+			deleted, err := postgres.DeletePage(ctx, targetDomainId, r.Request.URL.Path)
+			if err != nil {
+				log.Error(err)
+				recorder.addFailure(requestUrl, failureTypeDb, r.StatusCode, err)
+				return
+			}
+			recorder.addDeleted(int(deleted))
+		default:
+			// 一時的なエラーの可能性があるため、取得状態のみ更新して削除対象にはしない
+			log.Info(">> エラー:" + requestUrl)
+			log.Error(err)
+			recorder.addFailure(requestUrl, failureTypeFetch, r.StatusCode, err)
+			if err := postgres.UpdatePageFetchInfo(ctx, targetDomainId, r.Request.URL.Path, responseToFetchInfo(r)); err != nil {
+				log.Error(err)
+			}
+		}
+	})
+
+	// ベクトル化待ちのページ（コールバックはキューのスレッドで逐次実行されるため排他制御は不要）
+	var pendingPages []pendingPage
+	flushPendingPages := func() {
+		vectorizeAndSavePages(ctx, pendingPages, recorder)
+		pendingPages = nil
+	}
+
+	// 抽出したページデータをベクトル化して保存する処理（HTML と PDF で共通）
+	savePage := func(pageInfo model.PageInfo, links []string, r *colly.Response) {
+		// ドメインIDと取得状態（次回の 304 の場合にたどるページ内のリンクを含む）を設定
 		pageInfo.DomainID = targetDomainId
+		fetchInfo := responseToFetchInfo(r)
+		fetchInfo.Links = frontier.toStoredLinks(links)
 
 		if isTest {
 			log.Info(">> path:" + pageInfo.Path)
+			log.Info(">> contentType:" + pageInfo.ContentType)
 			log.Info(">> pageTitle:" + pageInfo.Title)
 			log.Info(">> description:" + pageInfo.Description)
 			log.Info(">> keywords:" + pageInfo.Keywords)
@@ -109,46 +336,235 @@ func CrawlDomain(targetDomainId int64, targetDomain string, startPath string, al
 			log.Info("\n")
 		}
 
-		// ハッシュ値を照合
-		isHashExists, err := postgres.CheckHashExists(pageInfo.Hash)
-		if err != nil {
-			log.Error(err)
+		// 保存済みのハッシュ値と照合し、変更がなければ取得状態のみ更新してスキップ（テストモード時はスキップしない）
+		if knownPage, ok := frontier.knownPages[pageInfo.Path]; ok && knownPage.Hash == pageInfo.Hash && !isTest {
+			if err := postgres.UpdatePageFetchInfo(ctx, targetDomainId, pageInfo.Path, fetchInfo); err != nil {
+				log.Error(err)
+				recorder.addFailure(r.Request.URL.String(), failureTypeDb, r.StatusCode, err)
+				return
+			}
+			recorder.addUnchanged()
 			return
 		}
 
-		if isHashExists && !isTest {
-			return // 既に保存されているハッシュがあればスキップ（テストモード時はスキップしない）
+		// ベクトル化待ちに追加し、一定数たまったら NLP サーバーにまとめて送信して保存
+		pendingPages = append(pendingPages, pendingPage{
+			pageInfo:   pageInfo,
+			fetchInfo:  fetchInfo,
+			url:        r.Request.URL.String(),
+			statusCode: r.StatusCode,
+		})
+		if len(pendingPages) >= vectorizeBatchSize {
+			flushPendingPages()
 		}
+	}
 
-		// 箇条書きをテキスト正規化、ベクトル化のリクエストを NLP サーバーに送信
-		convertResult, err := nlp.ConvertToVector(pageInfo.Markdown, false)
+	// html タグを見つけたときの処理
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		// ページ内のリンクを取得してたどる（ページデータの抽出に失敗してもたどり、保存して次回の 304 の場合にもたどれるようにする）
+		var links []string
+		e.ForEach("a[href]", func(_ int, a *colly.HTMLElement) {
+			link, isValid := validateAndFormatLinkUrl(a, targetDomain, config.AllowedPaths, config.DeniedPaths)
+			if isValid {
+				links = append(links, link)
+			}
+		})
+		// 単一 URL のクロールではリンクをたどらない
+		if opts.singleUrl == "" {
+			for _, link := range links {
+				frontier.enqueue(link, e.Request.Depth+1)
+			}
+		}
+
+		// ページデータを抽出
+		pageInfo, err := htmlToPageData(e)
 		if err != nil {
 			log.Error(err)
+			recorder.addFailure(e.Request.URL.String(), failureTypeParse, e.Response.StatusCode, err)
+			return
+		}
+		savePage(pageInfo, links, e.Response)
+	})
+
+	// PDF を受信したときの処理
+	c.OnResponse(func(r *colly.Response) {
+		if !isPdfResponse(r) {
 			return
 		}
 
-		// ページデータをデータベースに保存
-		err = postgres.SaveCrawledData(pageInfo, convertResult)
+		// PDF からテキストを抽出
+		pageInfo, err := pdfToPageData(r)
 		if err != nil {
 			log.Error(err)
+			recorder.addFailure(r.Request.URL.String(), failureTypeParse, r.StatusCode, err)
 			return
 		}
+		// PDF 内のリンクはたどらない
+		savePage(pageInfo, nil, r)
 	})
 
-	// a タグを見つけたときの処理
-	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		// URL を取得
-		url, isValid := validateAndFormatLinkUrl(e, targetDomain, allowedPaths)
-		if !isValid {
-			return // 無効なリンクはスキップ
+	if opts.singleUrl != "" {
+		// 単一 URL のクロールでは指定された URL のみキューに追加
+		frontier.enqueue(opts.singleUrl, 1)
+	} else {
+		// サイトマップに記載された URL を最終更新日時の新しい順にキューへ追加（リンクされていないページも対象にするため）
+		sitemapUrls := robots.Sitemaps
+		if len(sitemapUrls) == 0 {
+			sitemapUrls = []string{baseUrl + "/sitemap.xml"}
+		}
+		for _, entry := range fetchSitemapEntries(client, sitemapUrls) {
+			link := strings.Replace(entry.Loc, "http://", "https://", 1)
+			if isAllowedLink(link, targetDomain, config.AllowedPaths, config.DeniedPaths) {
+				frontier.enqueue(link, 1)
+			}
 		}
 
-		// ページ内で見つかったリンクを訪問
-		e.Request.Visit(url)
-	})
+		// 指定ドメインの開始パスそれぞれをキューに追加
+		for _, startPath := range config.StartPaths {
+			frontier.enqueue(baseUrl+startPath, 1)
+		}
+	}
+
+	// キューが空になるまでスクレイピングを実行
+	err = q.Run(c)
+
+	// 中断されていなければ、ベクトル化待ちの残りのページを保存
+	if ctx.Err() == nil {
+		flushPendingPages()
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// 中断された場合は取得していないページが多いため、削除処理を行わない
+	if ctx.Err() != nil {
+		err = fmt.Errorf("クロールが中断されました: %s: %w", targetDomain, ctx.Err())
+		log.Error(err)
+		return err
+	}
+
+	// テストモード時と単一 URL のクロール時は一部のページのみクロールするため、削除処理を行わない
+	if isTest || opts.singleUrl != "" {
+		return nil
+	}
+
+	// 今回のクロールでたどれなかった（リンクされなくなった、またはレスポンスがなかった）ページを論理削除
+	if fetchedCount == 0 {
+		log.Info("取得できたページがないため、ページの削除をスキップします: " + targetDomain)
+	} else {
+		deleted, err := postgres.DeleteUnseenPages(ctx, targetDomainId, frontier.seen())
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		recorder.addDeleted(int(deleted))
+		log.Info(fmt.Sprintf("たどれなかったページを %d 件削除しました: %s", deleted, targetDomain))
+	}
 
-	// 指定ドメインからスクレイピングを開始
-	c.Visit("https://" + targetDomain + startPath)
+	// 猶予日数を過ぎた削除済みページを完全に削除
+	purged, err := postgres.PurgeDeletedPages(ctx, targetDomainId, config.PurgeGraceDays)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	log.Info(fmt.Sprintf("削除済みページを %d 件完全に削除しました: %s", purged, targetDomain))
 
 	return nil
 }
+
+// ベクトル化待ちのページ
+type pendingPage struct {
+	pageInfo   model.PageInfo  // ページデータ
+	fetchInfo  model.FetchInfo // 取得状態
+	url        string          // 失敗の記録用の URL
+	statusCode int             // 失敗の記録用のステータスコード
+}
+
+/*
+ページをまとめて NLP サーバーでベクトル化し、データベースに保存する関数
+バッチでの変換に失敗した場合は、1 ページの不具合で他のページが保存されなくならないよう 1 ページずつ変換し直す
+  - ctx			コンテキスト
+  - pages		ベクトル化待ちのページ
+  - recorder	クロール結果の記録
+*/
+func vectorizeAndSavePages(ctx context.Context, pages []pendingPage, recorder *runRecorder) {
+	if len(pages) == 0 {
+		return
+	}
+
+	// 箇条書きをテキスト正規化、ベクトル化のリクエストをインデックスに使用するモデルごとに NLP サーバーにまとめて送信
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = page.pageInfo.Markdown
+	}
+	convertResultsList, err := nlp.ConvertToVectorBatchForIndex(ctx, texts)
+	if err != nil {
+		log.Error(err)
+		convertResultsList = nil
+	}
+
+	for i, page := range pages {
+		var convertResults []nlp.ConvertResponse
+		if convertResultsList != nil {
+			convertResults = convertResultsList[i]
+		} else {
+			convertResults, err = nlp.ConvertToVectorForIndex(ctx, page.pageInfo.Markdown)
+			if err != nil {
+				log.Error(err)
+				recorder.addFailure(page.url, failureTypeNlp, page.statusCode, err)
+				continue
+			}
+		}
+
+		// ページデータをデータベースに保存
+		changeType, err := postgres.SaveCrawledData(ctx, page.pageInfo, page.fetchInfo, convertResults)
+		if err != nil {
+			log.Error(err)
+			recorder.addFailure(page.url, failureTypeDb, page.statusCode, err)
+			continue
+		}
+		recorder.addSaved(changeType)
+	}
+}
+
+/*
+レスポンスからページの取得状態を作成する関数
+  - r		レスポンス
+  - return)	ページの取得状態
+*/
+func responseToFetchInfo(r *colly.Response) model.FetchInfo {
+	fetchInfo := model.FetchInfo{
+		StatusCode:    r.StatusCode,
+		LastFetchedAt: time.Now(),
+	}
+	if r.Headers != nil {
+		fetchInfo.ETag = r.Headers.Get("ETag")
+		fetchInfo.LastModified = r.Headers.Get("Last-Modified")
+	}
+	return fetchInfo
+}
+
+/*
+クロール設定の未設定項目にデフォルト値を補う関数
+  - config		ドメインのクロール設定
+  - return)		デフォルト値を補ったクロール設定
+*/
+func withDefaultCrawlConfig(config model.CrawlConfig) model.CrawlConfig {
+	if len(config.StartPaths) == 0 {
+		config.StartPaths = []string{"/"}
+	}
+	if len(config.AllowedPaths) == 0 {
+		config.AllowedPaths = []string{"/"}
+	}
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = 15
+	}
+	if config.DelayMs <= 0 {
+		config.DelayMs = 1000
+	}
+	if config.PurgeGraceDays <= 0 {
+		config.PurgeGraceDays = 30
+	}
+	return config
+}
@@ -1,12 +1,21 @@
 package crawler
 
 import (
+	"app/domain/model"
+	"app/usecase/entity"
+	"compress/gzip"
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
@@ -66,6 +75,7 @@ More text.
 func TestValidateAndFormatLinkUrl(t *testing.T) {
 	targetDomain := "example.com"
 	allowedPaths := []string{"/allowed"}
+	deniedPaths := []string{"/allowed/private/"}
 
 	// モックリクエスト
 	u, _ := url.Parse("https://example.com/")
@@ -85,12 +95,13 @@ func TestValidateAndFormatLinkUrl(t *testing.T) {
 		{"有効な絶対パス", "https://example.com/allowed/page2", "https://example.com/allowed/page2", true},
 		{"HTTP を HTTPS に変換", "http://example.com/allowed/page3", "https://example.com/allowed/page3", true},
 		{"外部ドメイン", "https://another.com/page", "", false},
-		{"PDF リンク", "/allowed/document.pdf", "", false},
+		{"PDF リンク", "/allowed/document.pdf", "https://example.com/allowed/document.pdf", true},
 		{"Mailto リンク", "mailto:test@example.com", "", false},
 		{"JavaScript リンク", "javascript:void(0)", "", false},
 		{"空のリンク", "", "", false},
 		{"アンカーリンク", "#section1", "", false},
 		{"許可されていないパス", "/disallowed/page", "", false},
+		{"除外されたパス", "/allowed/private/page", "", false},
 	}
 
 	for _, tc := range testCases {
@@ -105,7 +116,7 @@ func TestValidateAndFormatLinkUrl(t *testing.T) {
 			aNode := doc.Find("a").Get(0)
 			e := colly.NewHTMLElementFromSelectionNode(resp, doc.Find("a"), aNode, 0)
 
-			formattedLink, isValid := validateAndFormatLinkUrl(e, targetDomain, allowedPaths)
+			formattedLink, isValid := validateAndFormatLinkUrl(e, targetDomain, allowedPaths, deniedPaths)
 
 			if formattedLink != tc.expectedLink {
 				t.Errorf("期待されるリンク '%s' ですが、実際は '%s' でした", tc.expectedLink, formattedLink)
@@ -215,3 +226,372 @@ func TestHtmlToPageData(t *testing.T) {
 		t.Errorf("期待されるハッシュ '%s' ですが、実際は '%s' でした", expectedHash, pageInfo.Hash)
 	}
 }
+
+// robots.txt とサイトマップを返すテスト用サーバーを作成する関数
+func newRobotsTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `User-agent: *
+Disallow: /
+
+User-agent: %s
+Disallow: /private/
+Allow: /private/public.html
+Crawl-delay: 3
+
+Sitemap: %s/sitemap_index.xml
+`, userAgent, server.URL)
+	})
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%s/sitemap_pages.xml</loc></sitemap>
+  <sitemap><loc>%s/sitemap_news.xml.gz</loc></sitemap>
+  <sitemap><loc>%s/sitemap_missing.xml</loc></sitemap>
+</sitemapindex>`, server.URL, server.URL, server.URL)
+	})
+	mux.HandleFunc("/sitemap_pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/old.html</loc><lastmod>2024-01-01</lastmod></url>
+  <url><loc>https://example.com/nolastmod.html</loc></url>
+  <url><loc> https://example.com/new.html </loc><lastmod>2025-06-01T10:00:00+09:00</lastmod></url>
+</urlset>`)
+	})
+	mux.HandleFunc("/sitemap_news.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		fmt.Fprint(gz, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/news.html</loc><lastmod>2025-01-01</lastmod></url>
+  <url><loc>https://example.com/old.html</loc><lastmod>2024-01-01</lastmod></url>
+</urlset>`)
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchRobots(t *testing.T) {
+	server := newRobotsTestServer(t)
+
+	robots := fetchRobots(server.Client(), server.URL)
+	group := robots.FindGroup(userAgent)
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected bool
+	}{
+		{"許可されたパス", "/index.html", true},
+		{"拒否されたパス", "/private/secret.html", false},
+		{"拒否されたディレクトリ内で許可されたパス", "/private/public.html", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := group.Test(tc.path); actual != tc.expected {
+				t.Errorf("パス '%s' の判定は %v が期待されますが、実際は %v でした", tc.path, tc.expected, actual)
+			}
+		})
+	}
+
+	if group.CrawlDelay != 3*time.Second {
+		t.Errorf("期待される Crawl-delay は %v ですが、実際は %v でした", 3*time.Second, group.CrawlDelay)
+	}
+	if len(robots.Sitemaps) != 1 || robots.Sitemaps[0] != server.URL+"/sitemap_index.xml" {
+		t.Errorf("期待される Sitemap は '%s' ですが、実際は %v でした", server.URL+"/sitemap_index.xml", robots.Sitemaps)
+	}
+
+	// 他のユーザーエージェントは全て拒否される
+	if robots.TestAgent("/index.html", "OtherBot") {
+		t.Errorf("他のユーザーエージェントは拒否されるべきです")
+	}
+}
+
+func TestFetchRobotsNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	// robots.txt が存在しない場合は全て許可される
+	robots := fetchRobots(server.Client(), server.URL)
+	if !robots.TestAgent("/any/path", userAgent) {
+		t.Errorf("robots.txt が存在しない場合は全て許可されるべきです")
+	}
+}
+
+func TestFetchSitemapEntries(t *testing.T) {
+	server := newRobotsTestServer(t)
+
+	entries := fetchSitemapEntries(server.Client(), []string{server.URL + "/sitemap_index.xml"})
+
+	// 最終更新日時の新しい順、記載なしは最後、重複は除外
+	expectedLocs := []string{
+		"https://example.com/new.html",
+		"https://example.com/news.html",
+		"https://example.com/old.html",
+		"https://example.com/nolastmod.html",
+	}
+	if len(entries) != len(expectedLocs) {
+		t.Fatalf("期待される件数は %d ですが、実際は %d でした: %v", len(expectedLocs), len(entries), entries)
+	}
+	for i, expectedLoc := range expectedLocs {
+		if entries[i].Loc != expectedLoc {
+			t.Errorf("%d 件目は '%s' が期待されますが、実際は '%s' でした", i, expectedLoc, entries[i].Loc)
+		}
+	}
+	if !entries[len(entries)-1].LastMod.IsZero() {
+		t.Errorf("lastmod の記載がない場合はゼロ値であるべきです")
+	}
+}
+
+// テキストを 1 ページ含む最小構成の PDF を生成する関数
+func buildTestPdf(title string, text string) []byte {
+	content := "BT /F1 24 Tf 72 720 Td (" + text + ") Tj ET"
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Title (" + title + ") >>",
+	}
+
+	var buf strings.Builder
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xrefOffset)
+
+	return []byte(buf.String())
+}
+
+func TestPdfToPageData(t *testing.T) {
+	testCases := []struct {
+		name          string
+		title         string
+		path          string
+		expectedTitle string
+	}{
+		{"メタデータのタイトル", "Budget Report", "/docs/budget.pdf", "Budget Report"},
+		{"タイトルなしはファイル名", "", "/docs/%E4%BA%88%E7%AE%97.pdf", "予算.pdf"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, _ := url.Parse("https://example.com" + tc.path)
+			headers := http.Header{"Content-Type": []string{"application/pdf"}}
+			resp := &colly.Response{
+				Request:    &colly.Request{URL: u, Method: "GET", Ctx: colly.NewContext()},
+				StatusCode: http.StatusOK,
+				Body:       buildTestPdf(tc.title, "Hello PDF"),
+				Headers:    &headers,
+			}
+
+			if !isPdfResponse(resp) {
+				t.Fatalf("PDF のレスポンスとして判定されるべきです")
+			}
+
+			pageInfo, err := pdfToPageData(resp)
+			if err != nil {
+				t.Fatalf("予期しないエラー: %v", err)
+			}
+			if pageInfo.Title != tc.expectedTitle {
+				t.Errorf("期待されるタイトル '%s' ですが、実際は '%s' でした", tc.expectedTitle, pageInfo.Title)
+			}
+			if pageInfo.Path != u.Path {
+				t.Errorf("期待されるパス '%s' ですが、実際は '%s' でした", u.Path, pageInfo.Path)
+			}
+			if pageInfo.ContentType != "application/pdf" {
+				t.Errorf("期待されるコンテンツの種類 'application/pdf' ですが、実際は '%s' でした", pageInfo.ContentType)
+			}
+			if !strings.Contains(pageInfo.Markdown, "Hello PDF") {
+				t.Errorf("抽出したテキストに 'Hello PDF' が含まれるべきですが、実際は '%s' でした", pageInfo.Markdown)
+			}
+			hashBin := sha1.Sum([]byte(pageInfo.Markdown))
+			if pageInfo.Hash != hex.EncodeToString(hashBin[:]) {
+				t.Errorf("ハッシュ値が抽出したテキストと一致しません")
+			}
+		})
+	}
+}
+
+func TestPdfToPageDataInvalid(t *testing.T) {
+	u, _ := url.Parse("https://example.com/broken.pdf")
+	resp := &colly.Response{
+		Request: &colly.Request{URL: u, Method: "GET", Ctx: colly.NewContext()},
+		Body:    []byte("this is not a pdf"),
+	}
+
+	if _, err := pdfToPageData(resp); err == nil {
+		t.Errorf("壊れた PDF の場合はエラーになるべきです")
+	}
+}
+
+func TestResponseToFetchInfo(t *testing.T) {
+	headers := http.Header{
+		"Etag":          []string{`"abc123"`},
+		"Last-Modified": []string{"Wed, 21 Oct 2025 07:28:00 GMT"},
+	}
+	resp := &colly.Response{StatusCode: http.StatusNotModified, Headers: &headers}
+
+	fetchInfo := responseToFetchInfo(resp)
+
+	if fetchInfo.ETag != `"abc123"` {
+		t.Errorf("期待される ETag '%s' ですが、実際は '%s' でした", `"abc123"`, fetchInfo.ETag)
+	}
+	if fetchInfo.LastModified != "Wed, 21 Oct 2025 07:28:00 GMT" {
+		t.Errorf("期待される Last-Modified '%s' ですが、実際は '%s' でした", "Wed, 21 Oct 2025 07:28:00 GMT", fetchInfo.LastModified)
+	}
+	if fetchInfo.StatusCode != http.StatusNotModified {
+		t.Errorf("期待されるステータスコード %d ですが、実際は %d でした", http.StatusNotModified, fetchInfo.StatusCode)
+	}
+	if fetchInfo.LastFetchedAt.IsZero() {
+		t.Errorf("最終取得日時が設定されるべきです")
+	}
+}
+
+func TestRunRecorderAddSaved(t *testing.T) {
+	recorder := &runRecorder{run: &entity.DBCrawlRun{CrawlRunInfo: model.CrawlRunInfo{ErrorCounts: map[string]int{}}}}
+
+	for _, changeType := range []string{"created", "restored", "updated", "updated", ""} {
+		recorder.addSaved(changeType)
+	}
+	recorder.addUnchanged()
+	recorder.addDeleted(3)
+
+	tests := []struct {
+		name     string
+		expected int
+		actual   int
+	}{
+		{"新規作成", 2, recorder.run.PagesNew},
+		{"変更", 2, recorder.run.PagesChanged},
+		{"変更なし", 2, recorder.run.PagesUnchanged},
+		{"削除", 3, recorder.run.PagesDeleted},
+	}
+	for _, tt := range tests {
+		if tt.actual != tt.expected {
+			t.Errorf("%s: 期待される件数 %d ですが、実際は %d でした", tt.name, tt.expected, tt.actual)
+		}
+	}
+}
+
+func TestRegisterCrawl(t *testing.T) {
+	ctx, release, err := registerCrawl(context.Background(), 999, "example.com", "")
+	if err != nil {
+		t.Fatalf("登録に失敗しました: %v", err)
+	}
+
+	// 同じドメインは重複して登録できない
+	if _, _, err := registerCrawl(context.Background(), 999, "example.com", ""); err != ErrCrawlAlreadyRunning {
+		t.Errorf("期待されるエラー '%v' ですが、実際は '%v' でした", ErrCrawlAlreadyRunning, err)
+	}
+	if len(GetRunningCrawls()) != 1 {
+		t.Errorf("期待される実行中のクロール数 %d ですが、実際は %d でした", 1, len(GetRunningCrawls()))
+	}
+
+	// 中断するとコンテキストがキャンセルされる
+	if !CancelCrawl(999) {
+		t.Errorf("実行中のクロールを中断できるべきです")
+	}
+	if ctx.Err() == nil {
+		t.Errorf("中断後はコンテキストがキャンセルされるべきです")
+	}
+
+	// 登録解除後は再登録でき、存在しないクロールは中断できない
+	release()
+	if CancelCrawl(999) {
+		t.Errorf("登録解除後は中断できないべきです")
+	}
+	_, release, err = registerCrawl(context.Background(), 999, "example.com", "")
+	if err != nil {
+		t.Errorf("登録解除後は再登録できるべきです: %v", err)
+	}
+	release()
+}
+
+func TestLinkFrontier(t *testing.T) {
+	baseUrl := "https://example.com"
+	fetchStates := []entity.DBPage{
+		{FetchInfo: model.FetchInfo{Links: []string{"/kurashi/"}}, PageInfo: model.PageInfo{Path: "/"}},
+		{FetchInfo: model.FetchInfo{Links: []string{"/kurashi/gomi.html"}}, PageInfo: model.PageInfo{Path: "/kurashi/"}},
+		{FetchInfo: model.FetchInfo{Links: []string{}}, PageInfo: model.PageInfo{Path: "/kurashi/gomi.html"}},
+		// リンクされなくなったが、サイト上には残っていて 200 を返すページ
+		{FetchInfo: model.FetchInfo{Links: []string{}}, PageInfo: model.PageInfo{Path: "/unlinked.html"}},
+		// リンクを記録していないページ
+		{PageInfo: model.PageInfo{Path: "/legacy.html"}},
+	}
+
+	var queue []string
+	frontier := newLinkFrontier(baseUrl, 15, fetchStates, func(u *url.URL, depth int) error {
+		queue = append(queue, u.Path)
+		return nil
+	})
+
+	// 開始パスは 200 でリンクを解析し、リンク先は 304 のため前回のクロールで記録したリンクをたどる
+	frontier.enqueue(baseUrl+"/", 1)
+	frontier.markSeen("/")
+	frontier.enqueue(baseUrl+"/kurashi/", 2)
+	frontier.enqueue(baseUrl+"/kurashi/", 2)
+	frontier.markSeen("/kurashi/")
+	frontier.enqueueKnownLinks("/kurashi/", 2)
+	frontier.markSeen("/kurashi/gomi.html")
+	frontier.enqueueKnownLinks("/kurashi/gomi.html", 3)
+
+	expectedQueue := []string{"/", "/kurashi/", "/kurashi/gomi.html"}
+	if !reflect.DeepEqual(queue, expectedQueue) {
+		t.Errorf("期待されるキュー '%v' ですが、実際は '%v' でした", expectedQueue, queue)
+	}
+
+	// リンクからたどれないページは 200 を返す場合でも取得しないため、レスポンスがあったパスに含まれず削除対象になる
+	seen := frontier.seen()
+	sort.Strings(seen)
+	if !reflect.DeepEqual(seen, expectedQueue) {
+		t.Errorf("期待されるレスポンスがあったパス '%v' ですが、実際は '%v' でした", expectedQueue, seen)
+	}
+
+	testCases := []struct {
+		path     string
+		expected bool
+	}{
+		{"/kurashi/", true},
+		{"/legacy.html", false},
+		{"/new.html", false},
+	}
+	for _, tc := range testCases {
+		if _, ok := frontier.conditionalPage(tc.path); ok != tc.expected {
+			t.Errorf("期待される条件付きリクエストの可否 '%v' ですが、実際は '%v' でした（パス: %s）", tc.expected, ok, tc.path)
+		}
+	}
+
+	// 最大深度を超えるリンクはたどらない
+	frontier.enqueue(baseUrl+"/deep.html", 16)
+	if len(queue) != len(expectedQueue) {
+		t.Errorf("最大深度を超えるリンクはキューに追加されないべきです: %v", queue)
+	}
+}
+
+func TestLinkFrontierToStoredLinks(t *testing.T) {
+	frontier := newLinkFrontier("https://example.com", 15, nil, nil)
+
+	actual := frontier.toStoredLinks([]string{"https://example.com/a.html", "https://example.com/b?page=2"})
+	expected := []string{"/a.html", "/b?page=2"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("期待されるリンク '%v' ですが、実際は '%v' でした", expected, actual)
+	}
+
+	// PDF などリンクがないページも記録済みとして扱うため、nil ではなく空のスライスにする
+	if links := frontier.toStoredLinks(nil); links == nil || len(links) != 0 {
+		t.Errorf("期待されるリンクは空のスライスですが、実際は '%v' でした", links)
+	}
+}
@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"app/controller/log"
+	"app/usecase/entity"
+	"net/url"
+	"strings"
+)
+
+/*
+クロールでたどる URL を管理する構造体
+開始パス、サイトマップ、ページ内のリンク（304 Not Modified の場合は前回のクロールで記録したリンク）からたどれた URL のみキューに追加する
+保存済みのページでもリンクからたどれなくなったものは取得しないため、200 を返し続けていてもクロール後に削除対象になる
+*/
+type linkFrontier struct {
+	baseUrl    string                   // "https://" + 対象ドメイン
+	maxDepth   int                      // 最大スクレイピング深度
+	knownPages map[string]entity.DBPage // パスごとの保存済みページの取得状態
+	enqueued   map[string]bool          // キューに追加した URL
+	seenPaths  map[string]bool          // 今回のクロールでレスポンスがあったパス
+	addRequest func(u *url.URL, depth int) error
+}
+
+/*
+クロールでたどる URL の管理を作成する関数
+  - baseUrl			"https://" + 対象ドメイン
+  - maxDepth		最大スクレイピング深度
+  - fetchStates		保存済みページの取得状態
+  - addRequest		URL をクロールキューに追加する関数
+  - return)			クロールでたどる URL の管理
+*/
+func newLinkFrontier(baseUrl string, maxDepth int, fetchStates []entity.DBPage, addRequest func(u *url.URL, depth int) error) *linkFrontier {
+	knownPages := make(map[string]entity.DBPage, len(fetchStates))
+	for _, page := range fetchStates {
+		knownPages[page.Path] = page
+	}
+	return &linkFrontier{
+		baseUrl:    baseUrl,
+		maxDepth:   maxDepth,
+		knownPages: knownPages,
+		enqueued:   map[string]bool{},
+		seenPaths:  map[string]bool{},
+		addRequest: addRequest,
+	}
+}
+
+/*
+URL をクロールキューに追加する関数（追加済みの URL と最大深度を超える URL は追加しない）
+  - link		絶対 URL
+  - depth		深度
+*/
+func (f *linkFrontier) enqueue(link string, depth int) {
+	if f.enqueued[link] || depth > f.maxDepth {
+		return
+	}
+	f.enqueued[link] = true
+	u, err := url.Parse(link)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if err := f.addRequest(u, depth); err != nil {
+		log.Error(err)
+	}
+}
+
+/*
+前回のクロールで記録したページ内のリンクをクロールキューに追加する関数（304 Not Modified でページを解析できない場合に使用）
+  - path		変更がなかったページのパス
+  - depth		変更がなかったページの深度
+*/
+func (f *linkFrontier) enqueueKnownLinks(path string, depth int) {
+	for _, link := range f.knownPages[path].Links {
+		f.enqueue(f.baseUrl+link, depth+1)
+	}
+}
+
+/*
+条件付きリクエストにできる保存済みのページか判定する関数
+リンクを記録していないページ（links 列の追加前に保存されたページ）は、304 の場合にリンクをたどれないため条件なしで取得する
+  - path				パス
+  - return) knownPage	保存済みページの取得状態
+  - return) ok			条件付きリクエストにできるかどうか
+*/
+func (f *linkFrontier) conditionalPage(path string) (knownPage entity.DBPage, ok bool) {
+	knownPage, exists := f.knownPages[path]
+	return knownPage, exists && knownPage.Links != nil
+}
+
+// レスポンスがあったパスを記録する関数（解析・ベクトル化・保存に失敗しても削除対象にしない）
+func (f *linkFrontier) markSeen(path string) {
+	f.seenPaths[path] = true
+}
+
+// 今回のクロールでレスポンスがあったパスを返す関数（これ以外の保存済みのページは削除対象）
+func (f *linkFrontier) seen() []string {
+	paths := make([]string, 0, len(f.seenPaths))
+	for path := range f.seenPaths {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+/*
+ページ内のリンクを次回のクロールで再利用できる形式（ドメインを除いたパス）に変換する関数
+  - links		対象ドメインの絶対 URL
+  - return)		ドメインを除いたパス（クエリを含む、リンクがない場合も nil ではなく空のスライス）
+*/
+func (f *linkFrontier) toStoredLinks(links []string) []string {
+	storedLinks := make([]string, 0, len(links))
+	for _, link := range links {
+		storedLinks = append(storedLinks, strings.TrimPrefix(link, f.baseUrl))
+	}
+	return storedLinks
+}
@@ -3,8 +3,12 @@ package crawler
 import (
 	"app/controller/log"
 	"app/domain/model"
+	"bytes"
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
 	"regexp"
 	"strings"
 
@@ -13,6 +17,13 @@ import (
 	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/commonmark"
 	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/table"
 	"github.com/gocolly/colly/v2"
+	"github.com/ledongthuc/pdf"
+)
+
+// ページのコンテンツの種類
+const (
+	contentTypeHTML = "text/html"
+	contentTypePDF  = "application/pdf"
 )
 
 /*
@@ -37,6 +48,7 @@ func htmlToPageData(e *colly.HTMLElement) (pageInfo model.PageInfo, err error) {
 	// URL からドメインとパスを取得
 	pageInfo.DomainID = 0 // 仮の値、後で設定する
 	pageInfo.Path = e.Request.URL.Path
+	pageInfo.ContentType = contentTypeHTML
 
 	// ページタイトル、ディスクリプション、キーワードを取得（それぞれ存在しない場合は "--" を設定）
 	pageInfo.Title = e.ChildText("title")
@@ -79,19 +91,92 @@ func htmlToPageData(e *colly.HTMLElement) (pageInfo model.PageInfo, err error) {
 	return pageInfo, nil
 }
 
+/*
+PDF のレスポンスからページに関する各データを抽出する関数
+  - r					PDF のレスポンス
+  - return)	pageInfo	抽出したページ情報（Markdown には抽出したテキストを設定）
+  - return) err			エラー
+*/
+func pdfToPageData(r *colly.Response) (pageInfo model.PageInfo, err error) {
+	// 壊れた PDF でライブラリが panic した場合もエラーとして扱う
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("PDF の解析に失敗しました: %v", rec)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(r.Body), int64(len(r.Body)))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	// URL からドメインとパスを取得
+	pageInfo.DomainID = 0 // 仮の値、後で設定する
+	pageInfo.Path = r.Request.URL.Path
+	pageInfo.ContentType = contentTypePDF
+
+	// タイトルは PDF のメタデータから取得し、無い場合はファイル名を使用
+	pageInfo.Title = strings.TrimSpace(reader.Trailer().Key("Info").Key("Title").Text())
+	if pageInfo.Title == "" {
+		pageInfo.Title, _ = url.PathUnescape(path.Base(r.Request.URL.Path))
+	}
+	pageInfo.Description = "--"
+	pageInfo.Keywords = "--"
+
+	// ページごとにテキストを抽出して空行区切りで結合
+	texts := make([]string, 0, reader.NumPage())
+	for i := 1; i <= reader.NumPage(); i++ {
+		text, err := reader.Page(i).GetPlainText(nil)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	if len(texts) == 0 {
+		err = fmt.Errorf("PDF からテキストを抽出できませんでした: %s", r.Request.URL.String())
+		return
+	}
+	pageInfo.Markdown = strings.Join(texts, "\n\n")
+
+	// テキストのハッシュを計算
+	hashBin := sha1.Sum([]byte(pageInfo.Markdown))
+	pageInfo.Hash = hex.EncodeToString(hashBin[:])
+
+	return pageInfo, nil
+}
+
+/*
+レスポンスが PDF かどうかを判定する関数
+  - r		レスポンス
+  - return)	PDF かどうか
+*/
+func isPdfResponse(r *colly.Response) bool {
+	contentType := strings.ToLower(r.Headers.Get("Content-Type"))
+	if strings.HasPrefix(contentType, contentTypePDF) {
+		return true
+	}
+	// Content-Type が汎用的な場合は拡張子で判定
+	return strings.HasPrefix(contentType, "application/octet-stream") && strings.HasSuffix(strings.ToLower(r.Request.URL.Path), ".pdf")
+}
+
 /*
 URL パスの検証と絶対パス変換、https 変換を行う関数
   - e						HTMLElement
   - targetDomain			対象ドメイン
   - allowedPaths			許可するパスの配列
+  - deniedPaths				除外するパスの配列
   - return)	formattedLink	フォーマット済みのリンクURL
   - return) isValid			URLが有効かどうか
 */
-func validateAndFormatLinkUrl(e *colly.HTMLElement, targetDomain string, allowedPaths []string) (formattedLink string, isValid bool) {
+func validateAndFormatLinkUrl(e *colly.HTMLElement, targetDomain string, allowedPaths []string, deniedPaths []string) (formattedLink string, isValid bool) {
 	link := e.Attr("href")
 
-	// .pdf で終わるリンク、mailto:/javascript:/# 始まるリンク、空のリンクはスキップ
-	if matched, _ := regexp.MatchString(`(?i)\.pdf$|^mailto:|^javascript:|^$|^#`, link); matched {
+	// mailto:/javascript:/# 始まるリンク、空のリンクはスキップ
+	if matched, _ := regexp.MatchString(`(?i)^mailto:|^javascript:|^$|^#`, link); matched {
 		return "", false
 	}
 	// http:// を https:// に変換
@@ -102,9 +187,28 @@ func validateAndFormatLinkUrl(e *colly.HTMLElement, targetDomain string, allowed
 	if !strings.HasPrefix(link, "https://") {
 		link = e.Request.AbsoluteURL(link)
 	}
+	// 外部ドメインや許可されていないパスはスキップ
+	if !isAllowedLink(link, targetDomain, allowedPaths, deniedPaths) {
+		return "", false
+	}
+	formattedLink = link
+	isValid = true
+
+	return formattedLink, isValid
+}
+
+/*
+絶対 URL が対象ドメインかつクロール対象のパスかを判定する関数
+  - link				https:// から始まる絶対 URL
+  - targetDomain		対象ドメイン
+  - allowedPaths		許可するパスの配列
+  - deniedPaths			除外するパスの配列
+  - return)	isAllowed	クロール対象かどうか
+*/
+func isAllowedLink(link string, targetDomain string, allowedPaths []string, deniedPaths []string) (isAllowed bool) {
 	// 外部ドメインはスキップ
 	if !strings.HasPrefix(link, "https://"+targetDomain) {
-		return "", false
+		return false
 	}
 	// 特定のパス以外をスキップ
 	matched := false
@@ -115,12 +219,15 @@ func validateAndFormatLinkUrl(e *colly.HTMLElement, targetDomain string, allowed
 		}
 	}
 	if !matched {
-		return "", false
+		return false
 	}
-	formattedLink = link
-	isValid = true
-
-	return formattedLink, isValid
+	// 除外するパスを含む場合はスキップ
+	for _, deniedPath := range deniedPaths {
+		if strings.Contains(link, deniedPath) {
+			return false
+		}
+	}
+	return true
 }
 
 /*
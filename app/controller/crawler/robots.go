@@ -0,0 +1,176 @@
+package crawler
+
+import (
+	"app/controller/log"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// クローラーのユーザーエージェント（robots.txt のグループ判定にも使用する）
+const userAgent = "VectorLibrarianBot"
+
+// サイトマップインデックスをたどる最大の深さ
+const maxSitemapDepth = 3
+
+// サイトマップから取得した URL 情報
+type sitemapEntry struct {
+	Loc     string    // URL
+	LastMod time.Time // 最終更新日時（記載がない場合はゼロ値）
+}
+
+// sitemap.xml（urlset）とサイトマップインデックス（sitemapindex）の両方を読み込むための構造体
+type sitemapDocument struct {
+	XMLName  xml.Name     // ルート要素名（urlset / sitemapindex）
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+/*
+robots.txt を取得して解析する関数
+取得に失敗した場合や 4xx の場合は全て許可、5xx の場合は全て拒否として扱う
+  - client		HTTP クライアント
+  - baseUrl		対象サイトのベース URL（例: https://example.com）
+  - return)		robots.txt の解析結果
+*/
+func fetchRobots(client *http.Client, baseUrl string) (robots *robotstxt.RobotsData) {
+	resp, err := client.Get(baseUrl + "/robots.txt")
+	if err != nil {
+		log.Error(err)
+		robots, _ = robotstxt.FromStatusAndBytes(http.StatusNotFound, nil)
+		return robots
+	}
+	defer resp.Body.Close()
+
+	robots, err = robotstxt.FromResponse(resp)
+	if err != nil {
+		// 解析できない robots.txt は存在しないものとして扱う
+		log.Error(err)
+		robots, _ = robotstxt.FromStatusAndBytes(http.StatusNotFound, nil)
+	}
+
+	return robots
+}
+
+/*
+サイトマップ（インデックスを含む）をたどって URL 情報を取得する関数
+最終更新日時の新しい順（記載がないものは最後）に並べて返す
+  - client			HTTP クライアント
+  - sitemapUrls		起点となるサイトマップの URL のリスト
+  - return) entries	サイトマップに記載された URL 情報
+*/
+func fetchSitemapEntries(client *http.Client, sitemapUrls []string) (entries []sitemapEntry) {
+	visited := map[string]bool{}
+	seen := map[string]bool{}
+
+	var walk func(sitemapUrl string, depth int)
+	walk = func(sitemapUrl string, depth int) {
+		if depth > maxSitemapDepth || visited[sitemapUrl] {
+			return
+		}
+		visited[sitemapUrl] = true
+
+		doc, err := fetchSitemap(client, sitemapUrl)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		// サイトマップインデックスの場合は子サイトマップをたどる
+		for _, sitemap := range doc.Sitemaps {
+			walk(strings.TrimSpace(sitemap.Loc), depth+1)
+		}
+
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" || seen[loc] {
+				continue
+			}
+			seen[loc] = true
+			entries = append(entries, sitemapEntry{
+				Loc:     loc,
+				LastMod: parseLastMod(u.LastMod),
+			})
+		}
+	}
+	for _, sitemapUrl := range sitemapUrls {
+		walk(sitemapUrl, 0)
+	}
+
+	// 最終更新日時の新しい順に並べる（変更されたページを優先してクロールするため）
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastMod.After(entries[j].LastMod)
+	})
+
+	return entries
+}
+
+/*
+サイトマップを 1 件取得して解析する関数（gzip 圧縮されたサイトマップにも対応）
+  - client		HTTP クライアント
+  - sitemapUrl	サイトマップの URL
+  - return) doc	解析したサイトマップ
+  - return) err	エラー
+*/
+func fetchSitemap(client *http.Client, sitemapUrl string) (doc sitemapDocument, err error) {
+	resp, err := client.Get(sitemapUrl)
+	if err != nil {
+		return doc, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return doc, fmt.Errorf("サイトマップの取得に失敗しました: %s (%d)", sitemapUrl, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return doc, err
+	}
+
+	// gzip のマジックナンバーで始まる場合は展開する
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return doc, err
+		}
+		defer gz.Close()
+		body, err = io.ReadAll(gz)
+		if err != nil {
+			return doc, err
+		}
+	}
+
+	err = xml.Unmarshal(body, &doc)
+	return doc, err
+}
+
+// サイトマップの lastmod（W3C Datetime 形式）を解析する関数、解析できない場合はゼロ値を返す
+func parseLastMod(lastMod string) time.Time {
+	layouts := []string{
+		time.RFC3339,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(lastMod)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
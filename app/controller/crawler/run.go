@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"app/controller/log"
+	"app/controller/postgres"
+	"app/domain/model"
+	"app/usecase/entity"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// クロール失敗の種類
+const (
+	failureTypeFetch = "fetch" // ページの取得に失敗（タイムアウト、5xx など）
+	failureTypeParse = "parse" // HTML / PDF からのデータ抽出に失敗
+	failureTypeNlp   = "nlp"   // NLP サーバーでのベクトル化に失敗
+	failureTypeDb    = "db"    // データベースへの保存に失敗
+)
+
+// クロール 1 回分の結果を集計し、crawl_runs と crawl_failures に記録する構造体
+type runRecorder struct {
+	mu  sync.Mutex
+	ctx context.Context // 記録用のコンテキスト（クロールが中断されても結果を保存できるよう、キャンセルを引き継がない）
+	run *entity.DBCrawlRun
+}
+
+/*
+クロール実行情報を作成して記録を開始する関数
+  - ctx					クロールのコンテキスト
+  - domainId			ドメインID
+  - return) recorder	クロール結果の記録用構造体
+  - return) err			エラー
+*/
+func startRunRecorder(ctx context.Context, domainId int64) (recorder *runRecorder, err error) {
+	ctx = context.WithoutCancel(ctx)
+	run, err := postgres.CreateCrawlRun(ctx, domainId)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return &runRecorder{ctx: ctx, run: run}, nil
+}
+
+// レスポンスを受け取ったページ数を加算する
+func (r *runRecorder) addVisited() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.PagesVisited++
+}
+
+// 内容に変更がなかったページ数を加算する
+func (r *runRecorder) addUnchanged() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.PagesUnchanged++
+}
+
+// 論理削除したページ数を加算する
+func (r *runRecorder) addDeleted(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.PagesDeleted += count
+}
+
+/*
+保存したページを変更の種類ごとに加算する
+  - changeType		SaveCrawledData が返す変更の種類
+*/
+func (r *runRecorder) addSaved(changeType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch changeType {
+	case "created", "restored":
+		r.run.PagesNew++
+	case "updated":
+		r.run.PagesChanged++
+	default:
+		r.run.PagesUnchanged++
+	}
+}
+
+/*
+失敗したページを加算し、URL 単位の失敗情報を保存する
+  - url				失敗した URL
+  - failureType		失敗の種類
+  - statusCode		HTTP ステータスコード（レスポンスがない場合は 0）
+  - err				発生したエラー
+*/
+func (r *runRecorder) addFailure(url string, failureType string, statusCode int, err error) {
+	// 中断によるエラーは失敗として扱わない
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	r.mu.Lock()
+	r.run.PagesFailed++
+	r.run.ErrorCounts[failureType]++
+	crawlRunId := r.run.ID
+	r.mu.Unlock()
+
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	postgres.SaveCrawlFailure(r.ctx, model.CrawlFailureInfo{
+		CrawlRunID: crawlRunId,
+		URL:        url,
+		ErrorType:  failureType,
+		StatusCode: statusCode,
+		Message:    message,
+	})
+}
+
+/*
+クロール実行情報を終了状態にして保存する
+  - err		クロール全体のエラー（成功時は nil）
+*/
+func (r *runRecorder) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.FinishedAt = time.Now()
+	r.run.Status = "succeeded"
+	if errors.Is(err, context.Canceled) {
+		r.run.Status = "canceled"
+		r.run.ErrorMessage = err.Error()
+	} else if err != nil {
+		r.run.Status = "failed"
+		r.run.ErrorMessage = err.Error()
+	}
+	postgres.FinishCrawlRun(r.ctx, r.run)
+}
@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 同じドメインのクロールが既に実行中の場合のエラー
+var ErrCrawlAlreadyRunning = errors.New("このドメインのクロールは既に実行中です")
+
+// 実行中のクロール情報
+type RunningCrawl struct {
+	DomainID  int64     `json:"domain_id"`  // ドメインID
+	Domain    string    `json:"domain"`     // ドメイン
+	URL       string    `json:"url"`        // 単一 URL のクロールの場合はその URL（ドメイン全体の場合は空文字）
+	StartedAt time.Time `json:"started_at"` // 開始日時
+
+	cancel context.CancelFunc // クロールを中断する関数
+}
+
+// 実行中のクロール（ドメインID をキーとし、同じドメインを同時にクロールしないようにする）
+var runningCrawls = struct {
+	sync.Mutex
+	crawls map[int64]*RunningCrawl
+	wg     sync.WaitGroup // シャットダウン時にクロールの終了を待つため
+}{crawls: map[int64]*RunningCrawl{}}
+
+/*
+クロールを実行中として登録する関数
+  - parent				親のコンテキスト（キャンセルされた場合はクロールも中断される）
+  - domainId			ドメインID
+  - domain				ドメイン
+  - targetUrl			単一 URL のクロールの場合はその URL
+  - return) ctx			中断時にキャンセルされるコンテキスト
+  - return) release		クロール終了時に呼び出して登録を解除する関数
+  - return) err			既に実行中の場合は ErrCrawlAlreadyRunning
+*/
+func registerCrawl(parent context.Context, domainId int64, domain string, targetUrl string) (ctx context.Context, release func(), err error) {
+	runningCrawls.Lock()
+	defer runningCrawls.Unlock()
+
+	if _, exists := runningCrawls.crawls[domainId]; exists {
+		return nil, nil, ErrCrawlAlreadyRunning
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	runningCrawls.wg.Add(1)
+	runningCrawls.crawls[domainId] = &RunningCrawl{
+		DomainID:  domainId,
+		Domain:    domain,
+		URL:       targetUrl,
+		StartedAt: time.Now(),
+		cancel:    cancel,
+	}
+
+	release = func() {
+		runningCrawls.Lock()
+		defer runningCrawls.Unlock()
+		delete(runningCrawls.crawls, domainId)
+		cancel()
+		runningCrawls.wg.Done()
+	}
+	return ctx, release, nil
+}
+
+/*
+実行中のクロールを中断する関数
+  - domainId	ドメインID
+  - return)		実行中のクロールがあり中断した場合は true
+*/
+func CancelCrawl(domainId int64) bool {
+	runningCrawls.Lock()
+	defer runningCrawls.Unlock()
+
+	running, exists := runningCrawls.crawls[domainId]
+	if !exists {
+		return false
+	}
+	running.cancel()
+	return true
+}
+
+/*
+実行中のクロールの一覧を取得する関数
+  - return)		実行中のクロール情報のスライス（開始日時順）
+*/
+func GetRunningCrawls() []RunningCrawl {
+	runningCrawls.Lock()
+	defer runningCrawls.Unlock()
+
+	crawls := make([]RunningCrawl, 0, len(runningCrawls.crawls))
+	for _, running := range runningCrawls.crawls {
+		crawls = append(crawls, *running)
+	}
+	sort.Slice(crawls, func(i, j int) bool {
+		return crawls[i].StartedAt.Before(crawls[j].StartedAt)
+	})
+	return crawls
+}
+
+/*
+実行中のクロールがすべて終了するまで待機する関数（シャットダウン時に使用）
+  - ctx			待機を打ち切るためのコンテキスト（タイムアウトを指定する）
+  - return) err	待機を打ち切った場合は ctx のエラー
+*/
+func WaitRunningCrawls(ctx context.Context) (err error) {
+	done := make(chan struct{})
+	go func() {
+		runningCrawls.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
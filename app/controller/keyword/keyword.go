@@ -0,0 +1,185 @@
+// キーワード検索用に、テキストを形態素解析して正規化した語を抽出するパッケージ
+package keyword
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+	"textnorm"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ikawaha/kagome-dict/ipa"
+	"github.com/ikawaha/kagome/v2/tokenizer"
+)
+
+// 形態素解析器（辞書の読み込みに時間がかかるため、最初に使用するときに一度だけ作成して使い回す）
+var (
+	analyzer     *tokenizer.Tokenizer
+	analyzerErr  error
+	analyzerOnce sync.Once
+)
+
+// キーワードとして残す品詞（IPA 辞書の品詞の大分類）
+// 助詞・助動詞・記号などは多くの文に含まれ検索の役に立たないため除外する
+var keywordPos = map[string]bool{
+	"名詞":   true,
+	"動詞":   true,
+	"形容詞":  true,
+	"形容動詞": true,
+}
+
+// キーワードとして残す品詞でも除外する品詞の細分類（「こと」「もの」「これ」など）
+var excludedPosDetails = map[string]bool{
+	"非自立": true,
+	"代名詞": true,
+	"接尾":  true,
+}
+
+var whitespaceRe = regexp.MustCompile(`\s+`) // スニペットでは改行を含む連続する空白を 1 つの空白にする
+
+// 形態素解析器を取得する関数（最初に呼ばれたときに作成する）
+func getAnalyzer() (*tokenizer.Tokenizer, error) {
+	analyzerOnce.Do(func() {
+		analyzer, analyzerErr = tokenizer.New(ipa.Dict(), tokenizer.OmitBosEos())
+	})
+	return analyzer, analyzerErr
+}
+
+/*
+テキストを正規化・形態素解析して、キーワード検索用の語を抽出する関数（チャンクの保存時と検索クエリで同じ関数を使用する）
+  - text			テキスト
+  - return) terms	出現順の語（重複なし、活用する語は基本形）
+  - return) err		エラー（形態素解析器の作成に失敗した場合）
+*/
+func Terms(text string) (terms []string, err error) {
+	analyzer, err := getAnalyzer()
+	if err != nil {
+		return nil, err
+	}
+
+	// Search モードは複合語（「羽村市役所」など）を構成する語に分割する
+	tokens := analyzer.Analyze(textnorm.Normalize(text), tokenizer.Search)
+
+	terms = []string{}
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		term, ok := tokenTerm(token)
+		if !ok || seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+
+	return terms, nil
+}
+
+/*
+形態素をキーワード検索用の語に変換する関数
+  - token		形態素
+  - return)		語（活用する語は基本形）
+  - return) ok	キーワードとして残す語かどうか
+*/
+func tokenTerm(token tokenizer.Token) (term string, ok bool) {
+	pos := token.POS()
+	if len(pos) == 0 || !keywordPos[pos[0]] {
+		return "", false
+	}
+	if len(pos) > 1 && excludedPosDetails[pos[1]] {
+		return "", false
+	}
+
+	// 活用する語（「行っ」「高く」など）は基本形（「行く」「高い」）にそろえる
+	term = token.Surface
+	if baseForm, ok := token.BaseForm(); ok && baseForm != "*" && baseForm != "" {
+		term = baseForm
+	}
+
+	// 未知語として名詞に分類された記号（「-」など）は除外する
+	if !strings.ContainsFunc(term, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) {
+		return "", false
+	}
+	return term, true
+}
+
+// スニペットでクエリの語を囲むタグ（スニペットのその他の部分は HTML エスケープする）
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+/*
+テキストからクエリの語を含む部分を切り出し、クエリの語をハイライトしたスニペットを作成する関数
+テキストを形態素解析し、Terms と同じ規則で変換した語がクエリの語に含まれる部分を HighlightStart, HighlightEnd で囲む
+  - text			テキスト（保存されたチャンクなど、正規化済みのもの）
+  - queryTerms		検索クエリを形態素解析した語（Terms の結果）
+  - maxLength		スニペットの最大文字数（省略記号とタグを除く）
+  - return) snippet	HTML エスケープしたスニペット（切り出した場合は前後に「…」を付ける、クエリの語がない場合はテキストの先頭）
+  - return) err		エラー（形態素解析器の作成に失敗した場合）
+*/
+func Snippet(text string, queryTerms []string, maxLength int) (snippet string, err error) {
+	analyzer, err := getAnalyzer()
+	if err != nil {
+		return "", err
+	}
+
+	// クエリの語に一致する形態素の範囲（文字単位、隣接する範囲は 1 つにまとめる）
+	queryTermSet := make(map[string]bool, len(queryTerms))
+	for _, term := range queryTerms {
+		queryTermSet[term] = true
+	}
+	type span struct{ start, end int }
+	var spans []span
+	for _, token := range analyzer.Analyze(text, tokenizer.Search) {
+		term, ok := tokenTerm(token)
+		if !ok || !queryTermSet[term] {
+			continue
+		}
+		start := utf8.RuneCountInString(text[:token.Position])
+		end := start + utf8.RuneCountInString(token.Surface)
+		if len(spans) > 0 && spans[len(spans)-1].end >= start {
+			spans[len(spans)-1].end = max(spans[len(spans)-1].end, end)
+			continue
+		}
+		spans = append(spans, span{start, end})
+	}
+
+	// 最初に一致した語の少し前から最大文字数分を切り出す（末尾に達する場合は先頭側に広げる）
+	runes := []rune(text)
+	windowStart := 0
+	if len(spans) > 0 {
+		windowStart = max(0, spans[0].start-maxLength/4)
+	}
+	windowEnd := min(len(runes), windowStart+maxLength)
+	windowStart = max(0, windowEnd-maxLength)
+
+	var builder strings.Builder
+	if windowStart > 0 {
+		builder.WriteString("…")
+	}
+	writeEscaped := func(from int, to int) {
+		if from < to {
+			builder.WriteString(whitespaceRe.ReplaceAllString(html.EscapeString(string(runes[from:to])), " "))
+		}
+	}
+	position := windowStart
+	for _, s := range spans {
+		start, end := max(s.start, windowStart), min(s.end, windowEnd)
+		if start >= end {
+			continue
+		}
+		writeEscaped(position, start)
+		builder.WriteString(HighlightStart)
+		writeEscaped(start, end)
+		builder.WriteString(HighlightEnd)
+		position = end
+	}
+	writeEscaped(position, windowEnd)
+	if windowEnd < len(runes) {
+		builder.WriteString("…")
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,108 @@
+package keyword
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 単体テスト（外部依存がない関数のテスト）を定義
+// `docker compose exec app go test ./controller/keyword`
+
+func TestTerms(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			name:     "助詞・助動詞・記号を除外し、全角数字を半角にそろえる",
+			text:     "羽村市役所の電話番号は０４２－５５５－１２３４です。",
+			expected: []string{"羽村", "役所", "電話", "番号", "042", "555", "1234"},
+		},
+		{
+			name:     "活用する語を基本形にそろえる",
+			text:     "住民票の写しを取りに行った",
+			expected: []string{"住民", "写し", "取る", "行く"},
+		},
+		{
+			name:     "英単語を小文字化し、重複を除外する",
+			text:     "Hello World hello",
+			expected: []string{"hello", "world"},
+		},
+		{
+			name:     "語がない場合は空",
+			text:     "、。！？",
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			terms, err := Terms(tc.text)
+			if err != nil {
+				t.Fatalf("語の抽出に失敗しました: %v", err)
+			}
+			if !reflect.DeepEqual(terms, tc.expected) {
+				t.Errorf("期待される語 '%q' ですが、実際は '%q' でした", tc.expected, terms)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	testCases := []struct {
+		name       string
+		text       string
+		queryTerms []string
+		maxLength  int
+		expected   string
+	}{
+		{
+			name:       "隣接するクエリの語をまとめてハイライトする",
+			text:       "羽村市役所の電話番号は042-555-1234です。",
+			queryTerms: []string{"電話", "番号"},
+			maxLength:  100,
+			expected:   "羽村市役所の<mark>電話番号</mark>は042-555-1234です。",
+		},
+		{
+			name:       "活用する語は基本形で照合する",
+			text:       "住民票の写しを取りに行った",
+			queryTerms: []string{"行く"},
+			maxLength:  100,
+			expected:   "住民票の写しを取りに<mark>行っ</mark>た",
+		},
+		{
+			name:       "クエリの語の少し前から切り出す",
+			text:       "あいうえおかきくけこさしすせそ。役所はこちら。たちつてとなにぬねの",
+			queryTerms: []string{"役所"},
+			maxLength:  12,
+			expected:   "…せそ。<mark>役所</mark>はこちら。たち…",
+		},
+		{
+			name:       "クエリの語がない場合は先頭を切り出す",
+			text:       "あいうえおかきくけこ",
+			queryTerms: []string{"役所"},
+			maxLength:  5,
+			expected:   "あいうえお…",
+		},
+		{
+			name:       "HTML をエスケープし、改行を空白にする",
+			text:       "<b>電話</b>\n\n窓口",
+			queryTerms: []string{"窓口"},
+			maxLength:  100,
+			expected:   "&lt;b&gt;電話&lt;/b&gt; <mark>窓口</mark>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			snippet, err := Snippet(tc.text, tc.queryTerms, tc.maxLength)
+			if err != nil {
+				t.Fatalf("スニペットの作成に失敗しました: %v", err)
+			}
+			if snippet != tc.expected {
+				t.Errorf("期待されるスニペット '%q' ですが、実際は '%q' でした", tc.expected, snippet)
+			}
+		})
+	}
+}
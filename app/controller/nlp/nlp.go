@@ -4,10 +4,14 @@ import (
 	"app/controller/log"
 	"app/domain/model"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -15,6 +19,7 @@ import (
 type ConvertRequest struct {
 	Text    string `json:"text"`
 	IsQuery bool   `json:"is_query"`
+	Model   string `json:"model,omitempty"` // 使用するモデル名（空文字の場合は nlp サーバーのデフォルトのモデル）
 }
 
 // NLPサーバーからのレスポンス用の構造体
@@ -25,49 +30,290 @@ type ConvertResponse struct {
 	Vectors [][]float32 `json:"vectors"`
 }
 
+// NLPサーバーへのバッチリクエスト用の構造体
+type EmbedBatchRequest struct {
+	Texts   []string `json:"texts"`
+	IsQuery bool     `json:"is_query"`
+	Model   string   `json:"model,omitempty"` // 使用するモデル名（空文字の場合は nlp サーバーのデフォルトのモデル）
+}
+
+// NLPサーバーからのバッチレスポンス用の構造体（Results は Texts と同じ順番）
+// nlp/api/api.go と同じ構造体
+type EmbedBatchResponse struct {
+	model.NlpConfigInfo
+	Results []struct {
+		Chunks  []string    `json:"chunks"`
+		Vectors [][]float32 `json:"vectors"`
+	} `json:"results"`
+}
+
+// 1 回のバッチリクエストで送信するテキスト数の上限（nlp サーバー側の上限と合わせる）
+const MaxBatchTexts = 256
+
+// NLPサーバーへのリランクリクエスト用の構造体
+type RerankRequest struct {
+	Query    string   `json:"query"`
+	Passages []string `json:"passages"`
+}
+
+// NLPサーバーからのリランクレスポンス用の構造体（Scores は Passages と同じ順番）
+// nlp/api/api.go と同じ構造体
+type RerankResponse struct {
+	ModelName string    `json:"model_name"`
+	Scores    []float32 `json:"scores"` // クエリとの関連度（0〜1、1 に近いほど関連が強い）
+}
+
+// 1 回のリランクリクエストで送信する文書数の上限（nlp サーバー側の上限と合わせる）
+const MaxRerankPassages = 256
+
+// nlp サーバーにリランカーが設定されていない（RERANKER_MODEL_PATH が未設定の）場合のエラー
+var ErrRerankUnavailable = errors.New("rerank not available: nlp サーバーにリランカーが設定されていません")
+
+// nlp サーバーが 200 以外のステータスコードを返した場合のエラー
+type serverError struct {
+	statusCode int
+	body       string
+}
+
+func (e *serverError) Error() string {
+	return fmt.Sprintf("nlp サーバーエラー: %d - %s", e.statusCode, e.body)
+}
+
 /*
 nlp サーバーにテキストを送信してベクトルに変換する関数
 正規化も nlp サーバー側で行う
+  - ctx)		コンテキスト（キャンセルされた場合はリクエストを中断する）
   - text)		変換するテキスト
-  - isQuery)	クエリかどうかの真偽値（True なら「query: 」、False なら「passage: 」のプレフィックスが文頭に付与される）
+  - isQuery)	クエリかどうかの真偽値（True ならクエリ用、False なら文書用のプレフィックスが文頭に付与される）
+  - modelName)	使用するモデル名（空文字の場合は nlp サーバーのデフォルトのモデル）
   - return)		最大トークン長、オーバーラップトークン長、モデル名、モデル特有のベクトル長、チャンクの配列、ベクトルの2次元配列、エラー
 */
-func ConvertToVector(text string, isQuery bool) (resp ConvertResponse, err error) {
+func ConvertToVector(ctx context.Context, text string, isQuery bool, modelName string) (resp ConvertResponse, err error) {
 	// リクエストボディを作成
 	requestBody := ConvertRequest{
 		Text:    text,
 		IsQuery: isQuery,
+		Model:   modelName,
 	}
 
-	jsonData, err := json.Marshal(requestBody)
+	err = postJson(ctx, "/convert", requestBody, &resp)
 	if err != nil {
 		log.Error(err)
 		return ConvertResponse{}, err
 	}
 
+	return resp, nil
+}
+
+/*
+nlp サーバーに複数のテキストをまとめて送信してベクトルに変換する関数（nlp サーバー側でバッチ推論される）
+  - ctx)		コンテキスト（キャンセルされた場合はリクエストを中断する）
+  - texts)		変換するテキストの配列（MaxBatchTexts 件以下）
+  - isQuery)	クエリかどうかの真偽値（True ならクエリ用、False なら文書用のプレフィックスが文頭に付与される）
+  - modelName)	使用するモデル名（空文字の場合は nlp サーバーのデフォルトのモデル）
+  - return)		テキストごとの変換結果（texts と同じ順番）、エラー
+*/
+func ConvertToVectorBatch(ctx context.Context, texts []string, isQuery bool, modelName string) (resps []ConvertResponse, err error) {
+	if len(texts) > MaxBatchTexts {
+		err = fmt.Errorf("一度に変換できるテキストは %d 件までです: %d 件", MaxBatchTexts, len(texts))
+		log.Error(err)
+		return nil, err
+	}
+
+	// リクエストボディを作成
+	requestBody := EmbedBatchRequest{
+		Texts:   texts,
+		IsQuery: isQuery,
+		Model:   modelName,
+	}
+
+	var batchResp EmbedBatchResponse
+	err = postJson(ctx, "/embed_batch", requestBody, &batchResp)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	if len(batchResp.Results) != len(texts) {
+		err = fmt.Errorf("nlp サーバーの結果の件数が一致しません: %d 件送信、%d 件受信", len(texts), len(batchResp.Results))
+		log.Error(err)
+		return nil, err
+	}
+
+	// テキストごとの変換結果に分割（モデルの設定は共通）
+	resps = make([]ConvertResponse, len(batchResp.Results))
+	for i, result := range batchResp.Results {
+		resps[i] = ConvertResponse{
+			NlpConfigInfo: batchResp.NlpConfigInfo,
+			Chunks:        result.Chunks,
+			Vectors:       result.Vectors,
+		}
+	}
+
+	return resps, nil
+}
+
+/*
+nlp サーバーのクロスエンコーダーで、クエリと各文書の関連度を計算する関数（nlp サーバーにリランカーが設定されている場合のみ）
+正規化も nlp サーバー側で行う
+  - ctx)		コンテキスト（キャンセルされた場合はリクエストを中断する）
+  - query)		検索クエリ
+  - passages)	関連度を計算する文書の配列（MaxRerankPassages 件以下）
+  - return)		文書ごとの関連度（passages と同じ順番）、エラー（リランカーが設定されていない場合は ErrRerankUnavailable）
+*/
+func Rerank(ctx context.Context, query string, passages []string) (scores []float32, err error) {
+	if len(passages) > MaxRerankPassages {
+		err = fmt.Errorf("一度にリランクできる文書は %d 件までです: %d 件", MaxRerankPassages, len(passages))
+		log.Error(err)
+		return nil, err
+	}
+
+	// リクエストボディを作成
+	requestBody := RerankRequest{
+		Query:    query,
+		Passages: passages,
+	}
+
+	var resp RerankResponse
+	err = postJson(ctx, "/rerank", requestBody, &resp)
+	var serverErr *serverError
+	if errors.As(err, &serverErr) && serverErr.statusCode == http.StatusServiceUnavailable {
+		log.Info(err.Error())
+		return nil, ErrRerankUnavailable
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	if len(resp.Scores) != len(passages) {
+		err = fmt.Errorf("nlp サーバーの結果の件数が一致しません: %d 件送信、%d 件受信", len(passages), len(resp.Scores))
+		log.Error(err)
+		return nil, err
+	}
+
+	return resp.Scores, nil
+}
+
+/*
+インデックス（クロール時の保存）に使用するモデル名の一覧を取得する関数
+NLP_INDEX_MODELS 環境変数にカンマ区切りで指定し、未指定の場合は nlp サーバーのデフォルトのモデルのみ（空文字）
+クロールでは内容が変わったページのみベクトル化するため、モデルを追加した場合は -mode=repair で全ページをベクトル化する
+モデルを外した場合も -mode=repair で外したモデルのチャンクとベクトルを削除する（検索に使用する NLP設定は残す）
+  - return)	モデル名のスライス
+*/
+func IndexModels() (modelNames []string) {
+	for _, modelName := range strings.Split(os.Getenv("NLP_INDEX_MODELS"), ",") {
+		if modelName = strings.TrimSpace(modelName); modelName != "" {
+			modelNames = append(modelNames, modelName)
+		}
+	}
+	if len(modelNames) == 0 {
+		return []string{""}
+	}
+	return modelNames
+}
+
+/*
+インデックスに使用するすべてのモデルでテキストをベクトルに変換する関数（文書用）
+  - ctx)		コンテキスト
+  - text)		変換するテキスト
+  - return)		モデルごとの変換結果（IndexModels と同じ順番）、エラー
+*/
+func ConvertToVectorForIndex(ctx context.Context, text string) (resps []ConvertResponse, err error) {
+	for _, modelName := range IndexModels() {
+		resp, err := ConvertToVector(ctx, text, false, modelName)
+		if err != nil {
+			return nil, err
+		}
+		resps = append(resps, resp)
+	}
+	return resps, nil
+}
+
+/*
+インデックスに使用するすべてのモデルで複数のテキストをまとめてベクトルに変換する関数（文書用）
+  - ctx)		コンテキスト
+  - texts)		変換するテキストの配列（MaxBatchTexts 件以下）
+  - return)		テキストごとの、モデルごとの変換結果（texts と同じ順番、各要素は IndexModels と同じ順番）、エラー
+*/
+func ConvertToVectorBatchForIndex(ctx context.Context, texts []string) (respsList [][]ConvertResponse, err error) {
+	respsList = make([][]ConvertResponse, len(texts))
+	for _, modelName := range IndexModels() {
+		resps, err := ConvertToVectorBatch(ctx, texts, false, modelName)
+		if err != nil {
+			return nil, err
+		}
+		for i, resp := range resps {
+			respsList[i] = append(respsList[i], resp)
+		}
+	}
+	return respsList, nil
+}
+
+/*
+nlp サーバーに JSON を POST し、レスポンスをデコードする関数
+  - ctx			コンテキスト（キャンセルされた場合はリクエストを中断する）
+  - path		リクエストパス
+  - body		リクエストボディ
+  - out			レスポンスのデコード先
+  - return) err	エラー（ステータスコードが 200 以外の場合を含む）
+*/
+func postJson(ctx context.Context, path string, body any, out any) (err error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
 	// リクエスト URL とタイムアウト設定
-	requestUrl := "http://" + os.Getenv("NLP_HOST") + ":" + os.Getenv("NLP_PORT") + "/convert"
+	requestUrl := "http://" + os.Getenv("NLP_HOST") + ":" + os.Getenv("NLP_PORT") + path
 	client := &http.Client{Timeout: 1200 * time.Second}
 
 	// POSTリクエストを送信
-	httpResp, err := client.Post(requestUrl, "application/json", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", requestUrl, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Error(err)
-		return ConvertResponse{}, err
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
 	}
 	defer httpResp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(httpResp.Body)
 	if err != nil {
-		log.Error(err)
-		return ConvertResponse{}, err
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return &serverError{statusCode: httpResp.StatusCode, body: strings.TrimSpace(string(bodyBytes))}
 	}
 
 	// 構造体にデコード
-	if err := json.Unmarshal(bodyBytes, &resp); err != nil {
-		log.Error(err)
-		return ConvertResponse{}, err
+	return json.Unmarshal(bodyBytes, out)
+}
+
+/*
+nlp サーバーがリクエストを受け付けられる状態（モデル読み込み済み）か確認する関数
+  - ctx			コンテキスト
+  - return) err	エラー（準備ができていない場合を含む）
+*/
+func CheckReady(ctx context.Context) (err error) {
+	requestUrl := "http://" + os.Getenv("NLP_HOST") + ":" + os.Getenv("NLP_PORT") + "/readyz"
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", requestUrl, nil)
+	if err != nil {
+		return err
 	}
 
-	return resp, nil
+	client := &http.Client{Timeout: 5 * time.Second}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(httpResp.Body)
+		return fmt.Errorf("nlp サーバーの準備ができていません: %d - %s", httpResp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
 }
@@ -0,0 +1,86 @@
+// PostgreSQL を利用するための関数をまとめたパッケージ
+package postgres
+
+import (
+	"app/controller/log"
+	"app/usecase/entity"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// 同じドメインが既に登録されている場合のエラー
+var ErrDomainExists = errors.New("このドメインは既に登録されています")
+
+/*
+ID を指定してドメイン情報を取得する関数
+  - ctx				コンテキスト
+  - domainId		ドメインID
+  - return) domain	ドメイン情報
+  - return) err		エラー（存在しない場合は sql.ErrNoRows）
+*/
+func GetDomain(ctx context.Context, domainId int64) (domain entity.DBDomain, err error) {
+	err = db.NewSelect().
+		Model(&domain).
+		Where("id = ?", domainId).
+		Scan(ctx)
+	if err != nil {
+		log.Error(err)
+		return domain, err
+	}
+
+	return domain, nil
+}
+
+/*
+ドメイン情報を作成する関数
+  - ctx			コンテキスト
+  - domain		作成するドメイン情報（ID, 作成日時などが設定される）
+  - return) err	エラー（同じドメインが登録済みの場合は ErrDomainExists）
+*/
+func CreateDomain(ctx context.Context, domain *entity.DBDomain) (err error) {
+	_, err = db.NewInsert().
+		Model(domain).
+		Value("is_active", "?", domain.IsActive). // false の場合にデフォルト値（true）が使われないよう明示する
+		Returning("*").
+		Exec(ctx)
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) && pgErr.IntegrityViolation() {
+		return ErrDomainExists
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+/*
+ドメイン情報の指定したカラムを更新する関数
+  - ctx			コンテキスト
+  - domain		更新するドメイン情報（ID で対象を指定）
+  - columns		更新するカラム名のリスト
+  - return) err	エラー（同じドメインが登録済みの場合は ErrDomainExists）
+*/
+func UpdateDomain(ctx context.Context, domain *entity.DBDomain, columns []string) (err error) {
+	domain.UpdatedAt = time.Now()
+	_, err = db.NewUpdate().
+		Model(domain).
+		Column(append(columns, "updated_at")...).
+		WherePK().
+		Returning("*").
+		Exec(ctx)
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) && pgErr.IntegrityViolation() {
+		return ErrDomainExists
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
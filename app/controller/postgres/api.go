@@ -3,8 +3,10 @@ package postgres
 
 import (
 	"app/controller/log"
+	"app/domain/model"
 	"app/usecase/entity"
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 
@@ -12,15 +14,25 @@ import (
 )
 
 /*
-ベクトルを入力して、コサイン類似度が上位のデータを指定の件数返却する関数
+ベクトルを入力して、指定の NLP設定のベクトルのうちコサイン類似度が上位のチャンクを指定の件数返却する関数
+vector 列は次元数なしの vector 型のため、NLP設定の次元数にキャストして比較する（NLP設定ごとの HNSW インデックスと同じ式）
+  - ctx			コンテキスト
+  - nlpConfig	比較対象の NLP設定（入力するベクトルを生成したもの）
   - vector		入力するベクトル
+  - filter		絞り込み条件（MinScore はコサイン類似度の下限）
   - resultLimit	返却する件数
-  - return)		コサイン類似度が上位のページデータ
+  - return)		コサイン類似度が上位のチャンク（ページ・ドメイン情報を含む）
   - return)		コサイン類似度スコア（1に近いほど類似）
   - return) err	エラー
 */
-func GetSimilarPages(vector []float32, resultLimit int) (similarPages []entity.DBPage, scores []float32, err error) {
+func GetSimilarChunks(ctx context.Context, nlpConfig entity.DBNlpConfig, vector []float32, filter model.SearchFilter, resultLimit int) (similarChunks []entity.DBChunk, scores []float32, err error) {
+	if int64(len(vector)) != nlpConfig.ModelVectorLength {
+		err = fmt.Errorf("ベクトルの次元数が NLP設定と一致しません: %d 次元、NLP設定 %d は %d 次元", len(vector), nlpConfig.ID, nlpConfig.ModelVectorLength)
+		log.Error(err)
+		return nil, nil, err
+	}
 	vectorStr := vectorToString(vector)
+	vectorExpr := vectorCastExpr(nlpConfig.ModelVectorLength)
 
 	// スコアを含むクエリ結果用の構造体
 	type VectorWithScore struct {
@@ -29,30 +41,172 @@ func GetSimilarPages(vector []float32, resultLimit int) (similarPages []entity.D
 		Score float32 `bun:"score"`
 	}
 
+	// hnsw.ef_search は SET LOCAL で検索ごとに設定するため、トランザクション内で検索する
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		log.Error(err)
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+	err = setEfSearch(ctx, tx, getHnswParams().EfSearch, resultLimit)
+	if err != nil {
+		log.Error(err)
+		return nil, nil, err
+	}
+
+	// 絞り込む場合、HNSW インデックスの探索結果（ef_search 件）から除外されて件数が足りなくならないよう、件数に達するまで探索を続ける
+	if !isEmptyFilter(filter) {
+		_, err = tx.ExecContext(ctx, "SET LOCAL hnsw.iterative_scan = strict_order")
+		if err != nil {
+			log.Error(err)
+			return nil, nil, err
+		}
+	}
+
 	var results []VectorWithScore
-	err = db.NewSelect().
+	query := tx.NewSelect().
 		Model(&results).
 		Relation("Chunk.Page.Domain").
-		ColumnExpr("vectors.*, 1 - (vector <=> ?) AS score", vectorStr).
-		OrderExpr("vector <=> ?", vectorStr).
+		ColumnExpr("vectors.*, 1 - (? <=> ?) AS score", vectorExpr, vectorStr).
+		Where("vectors.nlp_config_id = ?", nlpConfig.ID). // 異なるモデル・設定のベクトルとは比較しない
+		Where("chunk__page.id IS NOT NULL")               // 論理削除されたチャンク・ページのベクトルを除外
+	query = applySearchFilter(query, filter, "chunk__page", "chunk__page__domain")
+	if filter.MinScore != nil {
+		query = query.Where("1 - (? <=> ?) >= ?", vectorExpr, vectorStr, *filter.MinScore)
+	}
+	err = query.
+		OrderExpr("? <=> ?", vectorExpr, vectorStr).
 		Limit(resultLimit).
-		Scan(context.Background())
+		Scan(ctx)
 	if err != nil {
 		log.Error(err)
 		return nil, nil, err
 	}
 
-	// entity.DBVector から entity.DBPage に変換（ドメイン情報を含む）
-	similarPages = make([]entity.DBPage, 0, len(results))
+	// entity.DBVector から entity.DBChunk に変換（ページ・ドメイン情報を含む）
+	similarChunks = make([]entity.DBChunk, 0, len(results))
 	scores = make([]float32, 0, len(results))
 	for _, result := range results {
 		if result.Chunk != nil && result.Chunk.Page != nil {
-			similarPages = append(similarPages, *result.Chunk.Page)
+			similarChunks = append(similarChunks, *result.Chunk)
+			scores = append(scores, result.Score)
+		}
+	}
+
+	return similarChunks, scores, nil
+}
+
+/*
+検索クエリの語を入力して、指定の NLP設定のチャンクのうちクエリの語を含むチャンクを一致度順に指定の件数返却する関数
+日本語は単語の区切りがないため、PostgreSQL の to_tsvector ではなく保存時に形態素解析した語（chunks.terms）で照合する（chunks_terms インデックスを使用）
+  - ctx			コンテキスト
+  - nlpConfig	検索対象の NLP設定（チャンクは NLP設定ごとに保存されている）
+  - queryTerms	検索クエリを形態素解析した語（keyword.Terms の結果）
+  - filter		絞り込み条件（MinScore は一致度スコアの下限）
+  - resultLimit	返却する件数
+  - return)		クエリの語との一致度が上位のチャンク（ページ・ドメイン情報を含む）
+  - return)		一致度スコア（ts_rank、クエリの語を多く含むほど大きい）
+  - return) err	エラー
+*/
+func GetKeywordMatchedChunks(ctx context.Context, nlpConfig entity.DBNlpConfig, queryTerms []string, filter model.SearchFilter, resultLimit int) (matchedChunks []entity.DBChunk, scores []float32, err error) {
+	if len(queryTerms) == 0 {
+		return []entity.DBChunk{}, []float32{}, nil
+	}
+	tsQuery := termsToTsQuery(queryTerms)
+
+	// スコアを含むクエリ結果用の構造体
+	type ChunkWithScore struct {
+		bun.BaseModel `bun:"table:chunks,alias:chunks"`
+		entity.DBChunk
+		Score float32 `bun:"score"`
+	}
+
+	var results []ChunkWithScore
+	query := db.NewSelect().
+		Model(&results).
+		Relation("Page.Domain").
+		ColumnExpr("chunks.*, ts_rank(array_to_tsvector(chunks.terms), ?::tsquery) AS score", tsQuery).
+		Where("chunks.nlp_config_id = ?", nlpConfig.ID).                 // 同じチャンクが NLP設定ごとに保存されているため、1 つの NLP設定に絞る
+		Where("array_to_tsvector(chunks.terms) @@ ?::tsquery", tsQuery). // クエリの語のいずれかを含むもの
+		Where("page.id IS NOT NULL")                                     // 論理削除されたページのチャンクを除外
+	query = applySearchFilter(query, filter, "page", "page__domain")
+	if filter.MinScore != nil {
+		query = query.Where("ts_rank(array_to_tsvector(chunks.terms), ?::tsquery) >= ?", tsQuery, *filter.MinScore)
+	}
+	err = query.
+		OrderExpr("score DESC, chunks.id").
+		Limit(resultLimit).
+		Scan(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, nil, err
+	}
+
+	matchedChunks = make([]entity.DBChunk, 0, len(results))
+	scores = make([]float32, 0, len(results))
+	for _, result := range results {
+		if result.Page != nil {
+			matchedChunks = append(matchedChunks, result.DBChunk)
 			scores = append(scores, result.Score)
 		}
 	}
 
-	return similarPages, scores, nil
+	return matchedChunks, scores, nil
+}
+
+/*
+検索クエリにページ・ドメインの絞り込み条件を追加する関数（MinScore はスコアの式が異なるため呼び出し側で追加する）
+  - query		検索クエリ
+  - filter		絞り込み条件
+  - pageAlias	ページのテーブルの別名
+  - domainAlias	ドメインのテーブルの別名
+  - return)		絞り込み条件を追加した検索クエリ
+*/
+func applySearchFilter(query *bun.SelectQuery, filter model.SearchFilter, pageAlias string, domainAlias string) *bun.SelectQuery {
+	if filter.Domain != "" {
+		query = query.Where("?.domain = ?", bun.Ident(domainAlias), filter.Domain)
+	}
+	if filter.PathPrefix != "" {
+		query = query.Where("?.path LIKE ?", bun.Ident(pageAlias), escapeLike(filter.PathPrefix)+"%")
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		query = query.Where("?.updated_at > ?", bun.Ident(pageAlias), filter.UpdatedAfter)
+	}
+	return query
+}
+
+// 絞り込み条件が空かどうかを判定する関数
+func isEmptyFilter(filter model.SearchFilter) bool {
+	return filter.Domain == "" && filter.PathPrefix == "" && filter.UpdatedAfter.IsZero() && filter.MinScore == nil
+}
+
+// LIKE のパターンで特別な意味を持つ文字（\, %, _）をエスケープする関数
+func escapeLike(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, "%", `\%`)
+	text = strings.ReplaceAll(text, "_", `\_`)
+	return text
+}
+
+/*
+語の配列を、いずれかの語を含む場合に一致する tsquery の文字列に変換する関数
+語は形態素解析済みのため、to_tsquery で再度分割・正規化せずにそのまま語として扱う（'語1' | '語2' | ...）
+  - terms		語の配列
+  - return)		tsquery の文字列
+*/
+func termsToTsQuery(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		term = strings.ReplaceAll(term, `\`, `\\`)
+		term = strings.ReplaceAll(term, "'", "''")
+		quoted = append(quoted, "'"+term+"'")
+	}
+	return strings.Join(quoted, " | ")
+}
+
+// vector 列を NLP設定の次元数にキャストする SQL の式（次元数は整数のためそのまま埋め込む）
+func vectorCastExpr(dimensions int64) bun.Safe {
+	return bun.Safe(fmt.Sprintf("vectors.vector::vector(%d)", dimensions))
 }
 
 // float32スライスをPostgreSQLのベクトル形式の文字列に変換
@@ -63,3 +217,57 @@ func vectorToString(vector []float32) string {
 	}
 	return "[" + strings.Join(strSlice, ",") + "]"
 }
+
+/*
+クロール実行情報を新しい順に取得する関数
+  - ctx				コンテキスト
+  - domainId		ドメインID（0 の場合は全ドメイン）
+  - limit			取得する件数
+  - return) runs	クロール実行情報のスライス
+  - return) err		エラー
+*/
+func GetCrawlRuns(ctx context.Context, domainId int64, limit int) (runs []entity.DBCrawlRun, err error) {
+	query := db.NewSelect().
+		Model(&runs).
+		OrderExpr("id DESC").
+		Limit(limit)
+	if domainId != 0 {
+		query = query.Where("domain_id = ?", domainId)
+	}
+
+	err = query.Scan(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+/*
+クロール実行中に失敗した URL の情報を取得する関数
+  - ctx				コンテキスト
+  - crawlRunId		クロール実行ID
+  - errorType		失敗の種類（空文字の場合は全種類）
+  - limit			取得する件数
+  - return) failures	クロール失敗情報のスライス
+  - return) err			エラー
+*/
+func GetCrawlFailures(ctx context.Context, crawlRunId int64, errorType string, limit int) (failures []entity.DBCrawlFailure, err error) {
+	query := db.NewSelect().
+		Model(&failures).
+		Where("crawl_run_id = ?", crawlRunId).
+		Order("id").
+		Limit(limit)
+	if errorType != "" {
+		query = query.Where("error_type = ?", errorType)
+	}
+
+	err = query.Scan(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return failures, nil
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"testing"
+)
+
+// 単体テスト（DB に接続しない関数のテスト）を定義
+// `docker compose exec app go test ./controller/postgres`
+
+func TestTermsToTsQuery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		terms    []string
+		expected string
+	}{
+		{"1 語", []string{"羽村"}, `'羽村'`},
+		{"複数の語はいずれかを含む", []string{"電話", "番号"}, `'電話' | '番号'`},
+		{"引用符とバックスラッシュをエスケープ", []string{"it's", `a\b`}, `'it''s' | 'a\\b'`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := termsToTsQuery(tc.terms)
+			if result != tc.expected {
+				t.Errorf("期待される tsquery '%s' ですが、実際は '%s' でした", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"特別な文字がない場合はそのまま", "/kurashi/", "/kurashi/"},
+		{"% と _ をエスケープ", "/100%_off/", `/100\%\_off/`},
+		{"バックスラッシュをエスケープ", `/a\b/`, `/a\\b/`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := escapeLike(tc.text)
+			if result != tc.expected {
+				t.Errorf("期待されるパターン '%s' ですが、実際は '%s' でした", tc.expected, result)
+			}
+		})
+	}
+}
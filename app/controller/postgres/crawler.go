@@ -2,24 +2,33 @@
 package postgres
 
 import (
+	"app/controller/keyword"
 	"app/controller/log"
 	"app/controller/nlp"
 	"app/domain/model"
 	"app/usecase/entity"
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
 )
 
 /*
 ドメイン情報を取得する関数
+  - ctx				コンテキスト
   - return) domains	ドメイン情報のスライス
   - return) err		エラー
 */
-func GetDomains() (dbDomains []entity.DBDomain, err error) {
+func GetDomains(ctx context.Context) (dbDomains []entity.DBDomain, err error) {
 	// ドメイン情報を取得
 	err = db.NewSelect().
 		Model(&dbDomains).
-		Scan(context.Background())
+		Scan(ctx)
 	if err != nil {
 		log.Error(err)
 		return nil, err
@@ -29,51 +38,87 @@ func GetDomains() (dbDomains []entity.DBDomain, err error) {
 }
 
 /*
-同一のハッシュ値を持つページが存在するか確認する関数
-  - hash			確認するページのハッシュ値
-  - return) exists	同一のハッシュ値を持つページが存在するかどうか
+ドメイン内の保存済みページの取得状態を取得する関数（Markdown などの本文は取得しない）
+  - ctx				コンテキスト
+  - domainId		ドメインID
+  - return) pages	ページ情報のスライス（ID, パス, ハッシュ値, 取得状態, ページ内のリンクのみ）
+  - return) err		エラー
+*/
+func GetPageFetchStates(ctx context.Context, domainId int64) (pages []entity.DBPage, err error) {
+	err = db.NewSelect().
+		Model(&pages).
+		Column("id", "path", "hash", "etag", "last_modified", "status_code", "last_fetched_at", "links").
+		Where("domain_id = ?", domainId).
+		Scan(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return pages, nil
+}
+
+/*
+ページの取得状態のみを更新する関数（304 Not Modified やコンテンツに変更がない場合に使用）
+  - ctx				コンテキスト
+  - domainId		ドメインID
+  - path			パス
+  - fetchInfo		取得状態
   - return) err		エラー
 */
-func CheckHashExists(hash string) (exists bool, err error) {
-	// ハッシュ値を持つページが存在するか確認
-	page := &entity.DBPage{PageInfo: model.PageInfo{Hash: hash}}
-	exists, err = db.NewSelect().
-		Model(page).
-		Where("hash = ?", hash).
-		Exists(context.Background())
+func UpdatePageFetchInfo(ctx context.Context, domainId int64, path string, fetchInfo model.FetchInfo) (err error) {
+	query := db.NewUpdate().
+		Model((*entity.DBPage)(nil)).
+		Set("status_code = ?", fetchInfo.StatusCode).
+		Set("last_fetched_at = ?", fetchInfo.LastFetchedAt).
+		Where("domain_id = ?", domainId).
+		Where("path = ?", truncateRunes(path, 255))
+
+	// 304 の場合はヘッダーが省略されることがあるため、値がある場合のみ更新
+	if fetchInfo.ETag != "" {
+		query = query.Set("etag = ?", truncateRunes(fetchInfo.ETag, 255))
+	}
+	if fetchInfo.LastModified != "" {
+		query = query.Set("last_modified = ?", truncateRunes(fetchInfo.LastModified, 100))
+	}
+	// 304 の場合はページを解析できないため、リンクを取得した場合のみ更新
+	if fetchInfo.Links != nil {
+		query = query.Set("links = ?", pgdialect.Array(fetchInfo.Links))
+	}
+
+	_, err = query.Exec(ctx)
 	if err != nil {
 		log.Error(err)
-		return false, err
+		return err
 	}
 
-	return exists, nil
+	return nil
 }
 
 /*
 クロールしたページデータを保存する関数
-  - pageInfo		保存するページ情報
-  - convertResult	nlp サーバーからの変換結果
-  - return) err	エラー
+  - ctx					コンテキスト
+  - pageInfo			保存するページ情報
+  - fetchInfo			ページの取得状態
+  - convertResults		nlp サーバーからのモデルごとの変換結果（NLP設定ごとにチャンクとベクトルを保存する）
+  - return) changeType	変更の種類（created, updated, restored、内容に変更がない場合は空文字）
+  - return) err			エラー
 */
-func SaveCrawledData(page model.PageInfo, convertResult nlp.ConvertResponse) (err error) {
+func SaveCrawledData(ctx context.Context, page model.PageInfo, fetchInfo model.FetchInfo, convertResults []nlp.ConvertResponse) (changeType string, err error) {
 	// ページ情報
 	// 文字列の長さが制限を超えている場合は UTF-8 安全に切り詰める
 	page.Path = truncateRunes(page.Path, 255)
 	page.Title = truncateRunes(page.Title, 100)
 	page.Description = truncateRunes(page.Description, 255)
 	page.Keywords = truncateRunes(page.Keywords, 255)
-
-	// チャンク情報、ベクトル情報、NLP設定情報
-	chunks := convertResult.Chunks
-	vectors := convertResult.Vectors
-	nlpConfigInfo := convertResult.NlpConfigInfo
+	fetchInfo.ETag = truncateRunes(fetchInfo.ETag, 255)
+	fetchInfo.LastModified = truncateRunes(fetchInfo.LastModified, 100)
 
 	// トランザクション開始
-	ctx := context.Background()
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Error(err)
-		return err
+		return "", err
 	}
 	defer func() {
 		if err != nil {
@@ -81,20 +126,25 @@ func SaveCrawledData(page model.PageInfo, convertResult nlp.ConvertResponse) (er
 		}
 	}()
 
-	// NLP設定を保存（UPDATE 文以下はコンフリクト時に既存のレコードのIDを取得するためのもの）
-	nlpConfig := &entity.DBNlpConfig{NlpConfigInfo: nlpConfigInfo}
-	_, err = tx.NewInsert().
-		Model(nlpConfig).
-		On("CONFLICT (max_token_length,overlap_token_length,model_name,model_vector_length) DO UPDATE SET max_token_length = EXCLUDED.max_token_length").
-		Returning("id").
-		Exec(ctx)
-	if err != nil {
+	// 変更履歴のために保存済みのハッシュ値を取得（削除済みのページも含む）
+	previousPage := &entity.DBPage{}
+	err = tx.NewSelect().
+		Model(previousPage).
+		Column("hash", "deleted_at").
+		Where("domain_id = ?", page.DomainID).
+		Where("path = ?", page.Path).
+		WhereAllWithDeleted().
+		Scan(ctx)
+	isNewPage := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !isNewPage {
 		log.Error(err)
-		return err
+		return "", err
 	}
+	previousHash := previousPage.Hash
+	isRestored := !isNewPage && !previousPage.DeletedAt.IsZero()
 
 	// ページを保存（存在しない場合は挿入、存在する場合は更新）
-	dbPage := &entity.DBPage{PageInfo: page}
+	dbPage := &entity.DBPage{PageInfo: page, FetchInfo: fetchInfo}
 	_, err = tx.NewInsert().
 		Model(dbPage).
 		On("CONFLICT (domain_id, path) DO UPDATE").
@@ -103,36 +153,205 @@ func SaveCrawledData(page model.PageInfo, convertResult nlp.ConvertResponse) (er
 		Set("keywords = EXCLUDED.keywords").
 		Set("markdown = EXCLUDED.markdown").
 		Set("hash = EXCLUDED.hash").
+		Set("content_type = EXCLUDED.content_type").
+		Set("etag = EXCLUDED.etag").
+		Set("last_modified = EXCLUDED.last_modified").
+		Set("status_code = EXCLUDED.status_code").
+		Set("last_fetched_at = EXCLUDED.last_fetched_at").
+		Set("links = EXCLUDED.links").
+		Set("deleted_at = EXCLUDED.deleted_at").
 		Set("updated_at = CURRENT_TIMESTAMP").
 		Returning("id").
 		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return "", err
+	}
+
+	// コンテンツが新規作成・変更・復活した場合は変更履歴を保存
+	if isNewPage || isRestored || previousHash != page.Hash {
+		changeType = "updated"
+		if isNewPage {
+			changeType = "created"
+		} else if isRestored {
+			changeType = "restored"
+		}
+		history := &entity.DBPageHistory{PageHistoryInfo: model.PageHistoryInfo{
+			PageID:       dbPage.ID,
+			ChangeType:   changeType,
+			PreviousHash: previousHash,
+			Hash:         page.Hash,
+		}}
+		_, err = tx.NewInsert().
+			Model(history).
+			Exec(ctx)
+		if err != nil {
+			log.Error(err)
+			return "", err
+		}
+	}
+
+	// NLP設定ごとにチャンクとベクトルを保存
+	nlpConfigs := make([]entity.DBNlpConfig, 0, len(convertResults))
+	for _, convertResult := range convertResults {
+		nlpConfig, err := saveChunks(ctx, tx, dbPage.ID, convertResult)
+		if err != nil {
+			log.Error(err)
+			return "", err
+		}
+		nlpConfigs = append(nlpConfigs, nlpConfig)
+	}
+
+	// トランザクションコミット
+	if err = tx.Commit(); err != nil {
+		log.Error(err)
+		return "", err
+	}
+
+	// 新しい NLP設定の場合は HNSW インデックスを作成（失敗しても保存は完了しているため、検索が全件走査になるだけでエラーにはしない）
+	for _, nlpConfig := range nlpConfigs {
+		if err := ensureVectorIndex(ctx, nlpConfig); err != nil {
+			log.Error(err)
+		}
+	}
+
+	return changeType, nil
+}
+
+/*
+クロール実行情報を「実行中」として作成する関数
+  - ctx				コンテキスト
+  - domainId		ドメインID
+  - return) run		作成したクロール実行情報
+  - return) err		エラー
+*/
+func CreateCrawlRun(ctx context.Context, domainId int64) (run *entity.DBCrawlRun, err error) {
+	run = &entity.DBCrawlRun{CrawlRunInfo: model.CrawlRunInfo{
+		DomainID:    domainId,
+		Status:      "running",
+		StartedAt:   time.Now(),
+		ErrorCounts: map[string]int{},
+	}}
+	_, err = db.NewInsert().
+		Model(run).
+		Returning("id").
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return run, nil
+}
+
+/*
+クロール実行情報の結果（状態、終了日時、件数）を更新する関数
+  - ctx			コンテキスト
+  - run			更新するクロール実行情報
+  - return) err	エラー
+*/
+func FinishCrawlRun(ctx context.Context, run *entity.DBCrawlRun) (err error) {
+	run.UpdatedAt = time.Now()
+	_, err = db.NewUpdate().
+		Model(run).
+		Column("status", "finished_at", "pages_visited", "pages_new", "pages_changed", "pages_unchanged", "pages_deleted", "pages_failed", "error_counts", "error_message", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+/*
+クロール中に失敗した URL の情報を保存する関数
+  - ctx			コンテキスト
+  - failure		クロール失敗情報
+  - return) err	エラー
+*/
+func SaveCrawlFailure(ctx context.Context, failure model.CrawlFailureInfo) (err error) {
+	failure.URL = truncateRunes(failure.URL, 2000)
+	_, err = db.NewInsert().
+		Model(&entity.DBCrawlFailure{CrawlFailureInfo: failure}).
+		Exec(ctx)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 
+	return nil
+}
+
+/*
+ページの 1 つの NLP 設定の変換結果（NLP設定、チャンク、ベクトル）を保存する関数
+  - ctx				コンテキスト
+  - tx				トランザクション
+  - pageId			ページID
+  - convertResult	nlp サーバーからの変換結果
+  - return) nlpConfig	保存した NLP設定
+  - return) err		エラー
+*/
+func saveChunks(ctx context.Context, tx bun.Tx, pageId int64, convertResult nlp.ConvertResponse) (nlpConfig entity.DBNlpConfig, err error) {
+	// チャンク情報、ベクトル情報、NLP設定情報
+	chunks := convertResult.Chunks
+	vectors := convertResult.Vectors
+	nlpConfigInfo := convertResult.NlpConfigInfo
+
+	// vector 列は次元数を固定していないため、NLP設定の次元数と一致することを保存前に確認する
+	if len(chunks) != len(vectors) {
+		return nlpConfig, fmt.Errorf("チャンク数とベクトル数が一致しません: %d, %d", len(chunks), len(vectors))
+	}
+	for _, vector := range vectors {
+		if int64(len(vector)) != nlpConfigInfo.ModelVectorLength {
+			return nlpConfig, fmt.Errorf("ベクトルの次元数が NLP設定と一致しません: %d 次元、%s は %d 次元", len(vector), nlpConfigInfo.ModelName, nlpConfigInfo.ModelVectorLength)
+		}
+	}
+
+	// NLP設定を保存（UPDATE 文以下はコンフリクト時に既存のレコードのIDを取得するためのもの）
+	nlpConfig = entity.DBNlpConfig{NlpConfigInfo: nlpConfigInfo}
+	_, err = tx.NewInsert().
+		Model(&nlpConfig).
+		On("CONFLICT (max_token_length,overlap_token_length,model_name,model_vector_length,pooling,normalized) DO UPDATE SET max_token_length = EXCLUDED.max_token_length").
+		Returning("id").
+		Exec(ctx)
+	if err != nil {
+		return nlpConfig, err
+	}
+
 	// チャンクとベクトルを一括保存
+	keepChunkIds := make([]int64, 0, len(chunks))
 	for i, chunk := range chunks {
-		// チャンク情報を保存（UPDATE 文以下はコンフリクト時に既存のレコードのIDを取得するためのもの）
+		// キーワード検索用の語を抽出（検索クエリと同じ規則で正規化・形態素解析する）
+		var terms []string
+		terms, err = keyword.Terms(chunk)
+		if err != nil {
+			return nlpConfig, err
+		}
+
+		// チャンク情報を保存（UPDATE 文以下はコンフリクト時に既存のレコードのIDを取得し、削除済みであれば復活させるためのもの）
 		chunkData := model.ChunkInfo{
 			NlpConfigID: nlpConfig.ID,
-			PageID:      dbPage.ID,
+			PageID:      pageId,
 			Chunk:       chunk,
+			Terms:       terms,
+			Position:    i,
 		}
 		chunkInfo := &entity.DBChunk{
 			ChunkInfo: chunkData,
 		}
 		_, err = tx.NewInsert().
 			Model(chunkInfo).
-			On("CONFLICT (nlp_config_id, page_id, chunk) DO UPDATE SET chunk = EXCLUDED.chunk").
+			On("CONFLICT (nlp_config_id, page_id, chunk) DO UPDATE SET chunk = EXCLUDED.chunk, terms = EXCLUDED.terms, position = EXCLUDED.position, deleted_at = EXCLUDED.deleted_at, updated_at = CURRENT_TIMESTAMP").
 			Returning("id").
 			Exec(ctx)
 		if err != nil {
-			log.Error(err)
-			return err
+			return nlpConfig, err
 		}
+		keepChunkIds = append(keepChunkIds, chunkInfo.ID)
 
-		// ベクトル情報を保存
+		// ベクトル情報を保存（削除済みであれば復活させる）
 		vectorData := model.VectorInfo{
 			NlpConfigID: nlpConfig.ID,
 			ChunkID:     chunkInfo.ID,
@@ -143,21 +362,324 @@ func SaveCrawledData(page model.PageInfo, convertResult nlp.ConvertResponse) (er
 		}
 		_, err = tx.NewInsert().
 			Model(vectorInfo).
-			On("CONFLICT (chunk_id) DO NOTHING").
+			On("CONFLICT (chunk_id) DO UPDATE SET vector = EXCLUDED.vector, deleted_at = EXCLUDED.deleted_at").
 			Exec(ctx)
 		if err != nil {
-			log.Error(err)
-			return err
+			return nlpConfig, err
+		}
+	}
+
+	// 今回生成されなかった古いチャンクとベクトルを削除（ページの内容が変わった場合に古いチャンクが検索に残らないようにする）
+	err = deleteStaleChunks(ctx, tx, pageId, nlpConfig.ID, keepChunkIds)
+	if err != nil {
+		return nlpConfig, err
+	}
+
+	return nlpConfig, nil
+}
+
+/*
+ページの NLP 設定ごとのチャンクのうち、残すもの以外をベクトルとともに物理削除する関数
+  - ctx				コンテキスト
+  - tx				トランザクション
+  - pageId			ページID
+  - nlpConfigId		NLP設定ID
+  - keepChunkIds	残すチャンクの ID のリスト
+  - return) err		エラー
+*/
+func deleteStaleChunks(ctx context.Context, tx bun.Tx, pageId int64, nlpConfigId int64, keepChunkIds []int64) (err error) {
+	staleChunkIds := tx.NewSelect().
+		Model((*entity.DBChunk)(nil)).
+		Column("id").
+		Where("page_id = ?", pageId).
+		Where("nlp_config_id = ?", nlpConfigId).
+		WhereAllWithDeleted()
+	if len(keepChunkIds) > 0 {
+		staleChunkIds = staleChunkIds.Where("id NOT IN (?)", bun.In(keepChunkIds))
+	}
+
+	_, err = tx.NewDelete().
+		Model((*entity.DBVector)(nil)).
+		Where("chunk_id IN (?)", staleChunkIds).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*entity.DBChunk)(nil)).
+		Where("id IN (?)", staleChunkIds).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+ID 順にページを取得する関数（全ページを少しずつ処理するために使用）
+  - afterId			この ID より大きいページを取得する
+  - limit			取得する件数
+  - return) pages	ページ情報のスライス
+  - return) err		エラー
+*/
+func GetPagesAfter(ctx context.Context, afterId int64, limit int) (pages []entity.DBPage, err error) {
+	err = db.NewSelect().
+		Model(&pages).
+		Where("id > ?", afterId).
+		Order("id").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return pages, nil
+}
+
+/*
+親が存在しないベクトルとチャンクを物理削除する関数（過去のデータの修復用）
+  - ctx						コンテキスト
+  - return) deletedVectors	削除したベクトル数
+  - return) deletedChunks	削除したチャンク数
+  - return) err				エラー
+*/
+func DeleteOrphanChunks(ctx context.Context) (deletedVectors int64, deletedChunks int64, err error) {
+	// ページが存在しないチャンクに紐づくベクトルとチャンク
+	orphanChunkIds := db.NewSelect().
+		Model((*entity.DBChunk)(nil)).
+		Column("id").
+		Where("page_id NOT IN (SELECT id FROM pages)").
+		WhereAllWithDeleted()
+
+	result, err := db.NewDelete().
+		Model((*entity.DBVector)(nil)).
+		WhereOr("chunk_id NOT IN (SELECT id FROM chunks)").
+		WhereOr("chunk_id IN (?)", orphanChunkIds).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, 0, err
+	}
+	deletedVectors, _ = result.RowsAffected()
+
+	result, err = db.NewDelete().
+		Model((*entity.DBChunk)(nil)).
+		Where("id IN (?)", orphanChunkIds).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, 0, err
+	}
+	deletedChunks, _ = result.RowsAffected()
+
+	return deletedVectors, deletedChunks, nil
+}
+
+/*
+ページを論理削除する関数（404 / 410 が返されたページに使用）
+  - ctx				コンテキスト
+  - domainId		ドメインID
+  - path			パス
+  - return) deleted	論理削除したページ数（保存されていないか削除済みの場合は 0）
+  - return) err		エラー
+*/
+func DeletePage(ctx context.Context, domainId int64, path string) (deleted int64, err error) {
+	pageIds := db.NewSelect().
+		Model((*entity.DBPage)(nil)).
+		Column("id").
+		Where("domain_id = ?", domainId).
+		Where("path = ?", truncateRunes(path, 255))
+
+	deleted, err = softDeletePages(ctx, pageIds)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+/*
+今回のクロールでレスポンスがなかったページを論理削除する関数（クロール完了後に、サイトから消えたページを削除するために使用）
+取得日時ではなくパスで判定するため、解析・ベクトル化・保存に失敗したページは削除されない
+  - ctx					コンテキスト
+  - domainId			ドメインID
+  - seenPaths			今回のクロールでレスポンスがあったパス
+  - return) deleted		論理削除したページ数
+  - return) err			エラー
+*/
+func DeleteUnseenPages(ctx context.Context, domainId int64, seenPaths []string) (deleted int64, err error) {
+	paths := make([]string, 0, len(seenPaths))
+	for _, path := range seenPaths {
+		paths = append(paths, truncateRunes(path, 255))
+	}
+	pageIds := db.NewSelect().
+		Model((*entity.DBPage)(nil)).
+		Column("id").
+		Where("domain_id = ?", domainId).
+		Where("NOT (path = ANY(?))", pgdialect.Array(paths))
+
+	deleted, err = softDeletePages(ctx, pageIds)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+/*
+論理削除されてから猶予日数を過ぎたページを、チャンク・ベクトル・変更履歴とともに物理削除する関数
+  - ctx					コンテキスト
+  - domainId			ドメインID
+  - graceDays			論理削除から物理削除までの猶予日数
+  - return) purged		物理削除したページ数
+  - return) err			エラー
+*/
+func PurgeDeletedPages(ctx context.Context, domainId int64, graceDays int) (purged int64, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
 		}
+	}()
+
+	// 猶予日数を過ぎた論理削除済みのページ
+	pageIds := tx.NewSelect().
+		Model((*entity.DBPage)(nil)).
+		Column("id").
+		Where("domain_id = ?", domainId).
+		Where("deleted_at < ?", time.Now().AddDate(0, 0, -graceDays)).
+		WhereDeleted()
+
+	_, err = tx.NewDelete().
+		Model((*entity.DBVector)(nil)).
+		Where("chunk_id IN (SELECT id FROM chunks WHERE page_id IN (?))", pageIds).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*entity.DBChunk)(nil)).
+		Where("page_id IN (?)", pageIds).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*entity.DBPageHistory)(nil)).
+		Where("page_id IN (?)", pageIds).
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+
+	result, err := tx.NewDelete().
+		Model((*entity.DBPage)(nil)).
+		Where("id IN (?)", pageIds).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, err
 	}
 
 	// トランザクションコミット
 	if err = tx.Commit(); err != nil {
 		log.Error(err)
-		return err
+		return 0, err
 	}
 
-	return nil
+	purged, _ = result.RowsAffected()
+	return purged, nil
+}
+
+/*
+ページとそのチャンク・ベクトルをまとめて論理削除する関数（検索対象から除外される）
+  - ctx				コンテキスト
+  - pageIds			論理削除するページの ID を返すサブクエリ
+  - return) deleted	論理削除したページ数
+  - return) err		エラー
+*/
+func softDeletePages(ctx context.Context, pageIds *bun.SelectQuery) (deleted int64, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// ページの ID を先に確定させてから、ベクトル・チャンク・ページの順に論理削除
+	var ids []int64
+	err = tx.NewSelect().
+		TableExpr("(?) AS target", pageIds).
+		Column("target.id").
+		Scan(ctx, &ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, tx.Commit()
+	}
+
+	_, err = tx.NewDelete().
+		Model((*entity.DBVector)(nil)).
+		Where("chunk_id IN (SELECT id FROM chunks WHERE page_id IN (?))", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*entity.DBChunk)(nil)).
+		Where("page_id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.NewDelete().
+		Model((*entity.DBPage)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	deleted, _ = result.RowsAffected()
+	return deleted, nil
 }
 
 // UTF-8 安全に文字数で切り詰めるヘルパー関数
@@ -0,0 +1,268 @@
+package postgres
+
+import (
+	"app/domain/model"
+	"app/usecase/entity"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// クロール結果の保存・削除のテスト（DB に接続する関数のテスト）を定義
+// ローカルの PostgreSQL（pgvector）が必要なため、TEST_POSTGRES_DSN が未設定の場合はスキップする
+// `docker compose exec app sh -c 'TEST_POSTGRES_DSN="postgres://$POSTGRES_USER:$POSTGRES_PASSWORD@$POSTGRES_HOST:5432/$POSTGRES_DB?sslmode=disable" go test ./controller/postgres -v'`
+
+/*
+テスト用の DB に接続してマイグレーションを適用し、テスト用のドメインを作成する関数（終了時にドメインのデータを削除する）
+  - t			テスト
+  - return)		テスト用のドメインID
+*/
+func setupTestDomain(t *testing.T) (domainId int64) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN が未設定のためスキップします")
+	}
+	ctx := context.Background()
+	previousDb := db
+	db = bun.NewDB(sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn))), pgdialect.New())
+
+	if err := Migrate(ctx); err != nil {
+		t.Fatalf("マイグレーションに失敗しました: %v", err)
+	}
+
+	domain := &entity.DBDomain{DomainInfo: model.DomainInfo{Domain: fmt.Sprintf("test-%d.example.com", time.Now().UnixNano())}}
+	_, err := db.NewInsert().Model(domain).Returning("id").Exec(ctx)
+	if err != nil {
+		t.Fatalf("ドメインの作成に失敗しました: %v", err)
+	}
+
+	t.Cleanup(func() {
+		pageIds := db.NewSelect().Model((*entity.DBPage)(nil)).Column("id").Where("domain_id = ?", domain.ID).WhereAllWithDeleted()
+		db.NewDelete().Model((*entity.DBPageHistory)(nil)).Where("page_id IN (?)", pageIds).Exec(ctx)
+		db.NewDelete().Model((*entity.DBChunk)(nil)).Where("page_id IN (?)", pageIds).WhereAllWithDeleted().ForceDelete().Exec(ctx)
+		db.NewDelete().Model((*entity.DBPage)(nil)).Where("domain_id = ?", domain.ID).WhereAllWithDeleted().ForceDelete().Exec(ctx)
+		db.NewDelete().Model(domain).WherePK().WhereAllWithDeleted().ForceDelete().Exec(ctx)
+		db.Close()
+		db = previousDb
+	})
+
+	return domain.ID
+}
+
+/*
+テスト用のページ情報を作成する関数（クローラーと同じく SHA-1 のハッシュ値を設定する）
+  - domainId	ドメインID
+  - path		パス
+  - markdown	Markdown コンテンツ
+  - return)		ページ情報
+*/
+func newTestPageInfo(domainId int64, path string, markdown string) model.PageInfo {
+	hashBin := sha1.Sum([]byte(markdown))
+	return model.PageInfo{
+		DomainID: domainId,
+		Path:     path,
+		Title:    "テスト",
+		Markdown: markdown,
+		Hash:     hex.EncodeToString(hashBin[:]),
+	}
+}
+
+func TestSaveCrawledDataUnchanged(t *testing.T) {
+	domainId := setupTestDomain(t)
+	ctx := context.Background()
+	page := newTestPageInfo(domainId, "/unchanged.html", "# 変更のないページ")
+
+	changeType, err := SaveCrawledData(ctx, page, model.FetchInfo{StatusCode: 200, LastFetchedAt: time.Now()}, nil)
+	if err != nil {
+		t.Fatalf("ページの保存に失敗しました: %v", err)
+	}
+	if changeType != "created" {
+		t.Errorf("期待される変更の種類 'created' ですが、実際は '%s' でした", changeType)
+	}
+
+	// 保存したハッシュ値がクローラーの計算した値とそのまま一致すること（固定長の型で空白が埋められないこと）
+	fetchStates, err := GetPageFetchStates(ctx, domainId)
+	if err != nil {
+		t.Fatalf("取得状態の取得に失敗しました: %v", err)
+	}
+	if len(fetchStates) != 1 || fetchStates[0].Hash != page.Hash {
+		t.Fatalf("期待されるハッシュ値 '%s' ですが、実際は %v でした", page.Hash, fetchStates)
+	}
+
+	// 同じ内容で保存し直した場合は変更として扱わない
+	changeType, err = SaveCrawledData(ctx, page, model.FetchInfo{StatusCode: 200, LastFetchedAt: time.Now()}, nil)
+	if err != nil {
+		t.Fatalf("ページの保存に失敗しました: %v", err)
+	}
+	if changeType != "" {
+		t.Errorf("期待される変更の種類は空文字ですが、実際は '%s' でした", changeType)
+	}
+
+	histories, err := db.NewSelect().
+		Model((*entity.DBPageHistory)(nil)).
+		Where("page_id = ?", fetchStates[0].ID).
+		Count(ctx)
+	if err != nil {
+		t.Fatalf("変更履歴の取得に失敗しました: %v", err)
+	}
+	if histories != 1 {
+		t.Errorf("期待される変更履歴の件数は 1 ですが、実際は %d でした", histories)
+	}
+}
+
+func TestDeleteUnseenPages(t *testing.T) {
+	domainId := setupTestDomain(t)
+	ctx := context.Background()
+
+	// 取得日時が古いページでも、今回のクロールでレスポンスがあれば削除しない（保存に失敗したページなど）
+	oldFetchInfo := model.FetchInfo{StatusCode: 200, LastFetchedAt: time.Now().AddDate(0, 0, -7)}
+	for _, path := range []string{"/seen.html", "/unseen.html"} {
+		if _, err := SaveCrawledData(ctx, newTestPageInfo(domainId, path, "# "+path), oldFetchInfo, nil); err != nil {
+			t.Fatalf("ページの保存に失敗しました: %v", err)
+		}
+	}
+
+	deleted, err := DeleteUnseenPages(ctx, domainId, []string{"/seen.html", "/new.html"})
+	if err != nil {
+		t.Fatalf("ページの削除に失敗しました: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("期待される削除件数は 1 ですが、実際は %d でした", deleted)
+	}
+
+	fetchStates, err := GetPageFetchStates(ctx, domainId)
+	if err != nil {
+		t.Fatalf("取得状態の取得に失敗しました: %v", err)
+	}
+	if len(fetchStates) != 1 || fetchStates[0].Path != "/seen.html" {
+		t.Errorf("期待される残りのページは '/seen.html' のみですが、実際は %v でした", fetchStates)
+	}
+
+	// 削除済みのページは数えない
+	deleted, err = DeleteUnseenPages(ctx, domainId, []string{"/seen.html"})
+	if err != nil {
+		t.Fatalf("ページの削除に失敗しました: %v", err)
+	}
+	if deleted != 0 {
+		t.Errorf("期待される削除件数は 0 ですが、実際は %d でした", deleted)
+	}
+}
+
+func TestDeletePage(t *testing.T) {
+	domainId := setupTestDomain(t)
+	ctx := context.Background()
+	if _, err := SaveCrawledData(ctx, newTestPageInfo(domainId, "/gone.html", "# 削除されたページ"), model.FetchInfo{StatusCode: 200, LastFetchedAt: time.Now()}, nil); err != nil {
+		t.Fatalf("ページの保存に失敗しました: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected int64
+	}{
+		{"保存済みのページは削除する", "/gone.html", 1},
+		{"削除済みのページは数えない", "/gone.html", 0},
+		{"保存されていないページは数えない", "/never-saved.html", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deleted, err := DeletePage(ctx, domainId, tc.path)
+			if err != nil {
+				t.Fatalf("ページの削除に失敗しました: %v", err)
+			}
+			if deleted != tc.expected {
+				t.Errorf("期待される削除件数は %d ですが、実際は %d でした", tc.expected, deleted)
+			}
+		})
+	}
+}
+
+func TestPurgeDeletedPages(t *testing.T) {
+	domainId := setupTestDomain(t)
+	ctx := context.Background()
+
+	// 猶予日数（30 日）を過ぎたページと過ぎていないページを論理削除済みにする
+	deletedDaysAgo := map[string]int{"/expired.html": 31, "/recent.html": 29}
+	for path, days := range deletedDaysAgo {
+		if _, err := SaveCrawledData(ctx, newTestPageInfo(domainId, path, "# "+path), model.FetchInfo{StatusCode: 200, LastFetchedAt: time.Now()}, nil); err != nil {
+			t.Fatalf("ページの保存に失敗しました: %v", err)
+		}
+		_, err := db.NewUpdate().
+			Model((*entity.DBPage)(nil)).
+			Set("deleted_at = ?", time.Now().AddDate(0, 0, -days)).
+			Where("domain_id = ?", domainId).
+			Where("path = ?", path).
+			Exec(ctx)
+		if err != nil {
+			t.Fatalf("削除日時の更新に失敗しました: %v", err)
+		}
+	}
+
+	purged, err := PurgeDeletedPages(ctx, domainId, 30)
+	if err != nil {
+		t.Fatalf("ページの完全な削除に失敗しました: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("期待される完全な削除件数は 1 ですが、実際は %d でした", purged)
+	}
+
+	var paths []string
+	err = db.NewSelect().
+		Model((*entity.DBPage)(nil)).
+		Column("path").
+		Where("domain_id = ?", domainId).
+		WhereAllWithDeleted().
+		Scan(ctx, &paths)
+	if err != nil {
+		t.Fatalf("ページの取得に失敗しました: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/recent.html" {
+		t.Errorf("期待される残りのページは '/recent.html' のみですが、実際は %v でした", paths)
+	}
+}
+
+func TestUpdatePageFetchInfoLinks(t *testing.T) {
+	domainId := setupTestDomain(t)
+	ctx := context.Background()
+	page := newTestPageInfo(domainId, "/links.html", "# リンクのあるページ")
+	if _, err := SaveCrawledData(ctx, page, model.FetchInfo{StatusCode: 200, LastFetchedAt: time.Now(), Links: []string{"/a.html"}}, nil); err != nil {
+		t.Fatalf("ページの保存に失敗しました: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		links    []string
+		expected []string
+	}{
+		{"304 の場合（リンクが nil）は保存済みのリンクを残す", nil, []string{"/a.html"}},
+		{"取得したリンクで更新する", []string{"/b.html", "/c.html"}, []string{"/b.html", "/c.html"}},
+		{"リンクがないページは空の配列にする", []string{}, []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := UpdatePageFetchInfo(ctx, domainId, page.Path, model.FetchInfo{StatusCode: 304, LastFetchedAt: time.Now(), Links: tc.links})
+			if err != nil {
+				t.Fatalf("取得状態の更新に失敗しました: %v", err)
+			}
+			fetchStates, err := GetPageFetchStates(ctx, domainId)
+			if err != nil {
+				t.Fatalf("取得状態の取得に失敗しました: %v", err)
+			}
+			if len(fetchStates) != 1 || !reflect.DeepEqual(fetchStates[0].Links, tc.expected) {
+				t.Errorf("期待されるリンク '%v' ですが、実際は %v でした", tc.expected, fetchStates)
+			}
+		})
+	}
+}
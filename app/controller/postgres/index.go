@@ -0,0 +1,210 @@
+// PostgreSQL を利用するための関数をまとめたパッケージ
+package postgres
+
+import (
+	"app/controller/log"
+	"app/usecase/entity"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/uptrace/bun"
+)
+
+// HNSW インデックスのパラメーター（環境変数で指定し、未指定の場合は pgvector のデフォルト値）
+type hnswParams struct {
+	M              int // グラフの各ノードの最大接続数（大きいほど再現率が上がり、インデックスが大きくなる）
+	EfConstruction int // 構築時の候補リストのサイズ（大きいほど再現率が上がり、構築が遅くなる）
+	EfSearch       int // 検索時の候補リストのサイズ（大きいほど再現率が上がり、検索が遅くなる）
+}
+
+// pgvector の HNSW インデックスが対応する vector 型の最大次元数
+const maxHnswDimensions = 2000
+
+// インデックスを作成済みの NLP設定ID（同じプロセスで同じ NLP設定のインデックス作成を繰り返さないため）
+var ensuredIndexes sync.Map
+
+/*
+環境変数から HNSW インデックスのパラメーターを取得する関数
+  - return)	HNSW_M, HNSW_EF_CONSTRUCTION, HNSW_EF_SEARCH の値（未指定・無効な値の場合はデフォルト値）
+*/
+func getHnswParams() hnswParams {
+	return hnswParams{
+		M:              getEnvInt("HNSW_M", 16),
+		EfConstruction: getEnvInt("HNSW_EF_CONSTRUCTION", 64),
+		EfSearch:       getEnvInt("HNSW_EF_SEARCH", 40),
+	}
+}
+
+// 環境変数を正の整数として取得する関数（未指定・無効な値の場合はデフォルト値）
+func getEnvInt(name string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// NLP設定ごとの HNSW インデックス名（構築時のパラメーターを変更した場合は別のインデックスとして作り直すため、名前に含める）
+func vectorIndexName(nlpConfigId int64, params hnswParams) string {
+	return fmt.Sprintf("vectors_hnsw_nlp_config_%d_m%d_efc%d", nlpConfigId, params.M, params.EfConstruction)
+}
+
+/*
+すべての NLP設定について、ベクトルの HNSW インデックスを作成する関数（作成済みの場合は何もしない）
+  - ctx			コンテキスト
+  - return) err	エラー
+*/
+func EnsureVectorIndexes(ctx context.Context) (err error) {
+	var configs []entity.DBNlpConfig
+	err = db.NewSelect().
+		Model(&configs).
+		Scan(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	for _, config := range configs {
+		err = ensureVectorIndex(ctx, config)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+NLP設定のベクトルの HNSW インデックスを作成する関数（同じプロセスで作成済みの場合は何もしない）
+作成後に、同じ NLP設定の異なるパラメーター（HNSW_M, HNSW_EF_CONSTRUCTION）で作成したインデックスを削除する
+  - ctx			コンテキスト
+  - config		NLP設定
+  - return) err	エラー
+*/
+func ensureVectorIndex(ctx context.Context, config entity.DBNlpConfig) (err error) {
+	if _, ok := ensuredIndexes.Load(config.ID); ok {
+		return nil
+	}
+
+	// pgvector の HNSW インデックスは 2000 次元までのため、それを超える場合は全件検索のままとする
+	if config.ModelVectorLength > maxHnswDimensions {
+		log.Info(fmt.Sprintf("次元数が %d を超えるため HNSW インデックスを作成しません: NLP設定 %d（%d 次元）", maxHnswDimensions, config.ID, config.ModelVectorLength))
+		ensuredIndexes.Store(config.ID, true)
+		return nil
+	}
+
+	indexName := vectorIndexName(config.ID, getHnswParams())
+	err = createVectorIndex(ctx, "vectors", indexName, config.ModelVectorLength, config.ID, getHnswParams())
+	if err != nil {
+		return err
+	}
+	err = dropOldVectorIndexes(ctx, config.ID, indexName)
+	if err != nil {
+		return err
+	}
+
+	ensuredIndexes.Store(config.ID, true)
+	return nil
+}
+
+/*
+ベクトルの HNSW インデックス（コサイン距離）を作成する関数（作成済みの場合は何もしない）
+vector 列は次元数を固定していないため、NLP設定の次元数にキャストした式に対する部分インデックスとする（GetSimilarChunks の ORDER BY と同じ式）
+保存済みのベクトルが多い場合も書き込みを止めないよう CONCURRENTLY で作成する（トランザクション内では実行できない）
+同時作成に失敗して無効なまま残ったインデックスは、削除してから作り直す
+  - ctx			コンテキスト
+  - table		テーブル名（ベンチマークでは別のテーブルを使用する）
+  - indexName	インデックス名
+  - dimensions	NLP設定の次元数
+  - nlpConfigId	NLP設定ID
+  - params		HNSW インデックスのパラメーター
+  - return) err	エラー
+*/
+func createVectorIndex(ctx context.Context, table string, indexName string, dimensions int64, nlpConfigId int64, params hnswParams) (err error) {
+	var isValid bool
+	err = db.NewRaw("SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass(?)", indexName).Scan(ctx, &isValid)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		if isValid {
+			return nil
+		}
+		log.Info("無効なインデックスを作り直します: " + indexName)
+		_, err = db.NewRaw("DROP INDEX CONCURRENTLY IF EXISTS ?", bun.Ident(indexName)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("HNSW インデックスを作成します: " + indexName)
+	_, err = db.NewRaw(
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS ? ON ? USING hnsw ((vector::vector(?)) vector_cosine_ops) WITH (m = ?, ef_construction = ?) WHERE nlp_config_id = ?",
+		bun.Ident(indexName),
+		bun.Ident(table),
+		dimensions,
+		params.M,
+		params.EfConstruction,
+		nlpConfigId,
+	).Exec(ctx)
+	return err
+}
+
+/*
+NLP設定の HNSW インデックスのうち、指定のもの以外（パラメーターを変更する前に作成したもの）を削除する関数
+  - ctx			コンテキスト
+  - nlpConfigId	NLP設定ID
+  - keepName	残すインデックス名
+  - return) err	エラー
+*/
+func dropOldVectorIndexes(ctx context.Context, nlpConfigId int64, keepName string) (err error) {
+	// パラメーターを名前に含める前のインデックス名（vectors_hnsw_nlp_config_<ID>）も対象にする
+	legacyName := fmt.Sprintf("vectors_hnsw_nlp_config_%d", nlpConfigId)
+	var indexNames []string
+	err = db.NewSelect().
+		Table("pg_indexes").
+		Column("indexname").
+		Where("tablename = ?", "vectors").
+		Where("indexname != ?", keepName).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				WhereOr("indexname = ?", legacyName).
+				WhereOr("indexname LIKE ?", escapeLike(legacyName+"_m")+"%")
+		}).
+		Scan(ctx, &indexNames)
+	if err != nil {
+		return err
+	}
+
+	for _, indexName := range indexNames {
+		log.Info("パラメーターを変更する前の HNSW インデックスを削除します: " + indexName)
+		_, err = db.NewRaw("DROP INDEX CONCURRENTLY IF EXISTS ?", bun.Ident(indexName)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgvector の hnsw.ef_search に設定できる最大値
+const maxEfSearch = 1000
+
+/*
+トランザクション内の検索で使用する HNSW の候補リストのサイズを設定する関数
+候補リストより多くの件数は返せないため、返却する件数の方が大きい場合はその値を使用する（maxEfSearch まで）
+  - ctx			コンテキスト
+  - tx			トランザクション（SET LOCAL のためトランザクションの終了まで有効）
+  - efSearch	候補リストのサイズ
+  - resultLimit	返却する件数
+  - return) err	エラー
+*/
+func setEfSearch(ctx context.Context, tx bun.Tx, efSearch int, resultLimit int) (err error) {
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", min(max(efSearch, resultLimit), maxEfSearch)))
+	return err
+}
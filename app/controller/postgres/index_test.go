@@ -0,0 +1,263 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// HNSW インデックスの再現率と検索速度を全件検索（厳密な検索）と比較するテストを定義
+// ローカルの PostgreSQL（pgvector）が必要なため、TEST_POSTGRES_DSN が未設定の場合はスキップする
+// `docker compose exec app sh -c 'TEST_POSTGRES_DSN="postgres://$POSTGRES_USER:$POSTGRES_PASSWORD@$POSTGRES_HOST:5432/$POSTGRES_DB?sslmode=disable" go test ./controller/postgres -run HNSW -bench HNSW -v'`
+
+const (
+	benchmarkTable      = "hnsw_benchmark_vectors" // ベンチマーク用のテーブル（終了時に削除する）
+	benchmarkDimensions = 384                      // ベクトルの次元数
+	benchmarkRows       = 5000                     // 保存するベクトル数
+	benchmarkClusters   = 50                       // ベクトルを生成するクラスター数（実際の文書のように偏りを持たせる）
+	benchmarkQueries    = 50                       // 再現率を計算するクエリ数
+	benchmarkLimit      = 10                       // 1 回の検索で返却する件数
+	benchmarkConfigId   = 1                        // ベンチマーク用の NLP設定ID
+)
+
+// ベンチマーク用のベクトル
+type benchmarkVector struct {
+	bun.BaseModel `bun:"table:hnsw_benchmark_vectors"`
+
+	ID          int64     `bun:"id,pk,autoincrement"`
+	NlpConfigID int64     `bun:"nlp_config_id,notnull"`
+	Vector      []float32 `bun:"vector,notnull,type:vector"`
+}
+
+/*
+ベンチマーク用のテーブルにベクトルを保存し、HNSW インデックスを作成する関数
+  - tb			テストまたはベンチマーク
+  - return)		検索に使用するクエリベクトル
+*/
+func setupBenchmarkTable(tb testing.TB) (queries [][]float32) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		tb.Skip("TEST_POSTGRES_DSN が未設定のためスキップします")
+	}
+	ctx := context.Background()
+	previousDb := db
+	db = bun.NewDB(sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn))), pgdialect.New())
+	tb.Cleanup(func() {
+		db.NewDropTable().Model((*benchmarkVector)(nil)).IfExists().Exec(ctx)
+		db.Close()
+		db = previousDb
+	})
+
+	_, err := db.NewDropTable().Model((*benchmarkVector)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		tb.Fatalf("テーブルの削除に失敗しました: %v", err)
+	}
+	_, err = db.NewCreateTable().Model((*benchmarkVector)(nil)).Exec(ctx)
+	if err != nil {
+		tb.Fatalf("テーブルの作成に失敗しました: %v", err)
+	}
+
+	// クラスターの中心の周りにベクトルを生成（乱数のシードを固定して毎回同じデータにする）
+	random := rand.New(rand.NewSource(1))
+	centers := make([][]float32, benchmarkClusters)
+	for i := range centers {
+		centers[i] = randomVector(random, nil, 1)
+	}
+	rows := make([]benchmarkVector, 0, benchmarkRows)
+	for i := 0; i < benchmarkRows; i++ {
+		rows = append(rows, benchmarkVector{
+			NlpConfigID: benchmarkConfigId,
+			Vector:      randomVector(random, centers[random.Intn(benchmarkClusters)], 0.3),
+		})
+	}
+	for start := 0; start < len(rows); start += 500 {
+		batch := rows[start:min(start+500, len(rows))]
+		_, err = db.NewInsert().Model(&batch).Exec(ctx)
+		if err != nil {
+			tb.Fatalf("ベクトルの保存に失敗しました: %v", err)
+		}
+	}
+
+	// 本番と同じ式・パラメーターで HNSW インデックスを作成
+	err = createVectorIndex(ctx, benchmarkTable, benchmarkTable+"_hnsw", benchmarkDimensions, benchmarkConfigId, getHnswParams())
+	if err != nil {
+		tb.Fatalf("インデックスの作成に失敗しました: %v", err)
+	}
+	_, err = db.ExecContext(ctx, "ANALYZE "+benchmarkTable)
+	if err != nil {
+		tb.Fatalf("ANALYZE に失敗しました: %v", err)
+	}
+
+	queries = make([][]float32, benchmarkQueries)
+	for i := range queries {
+		queries[i] = randomVector(random, centers[random.Intn(benchmarkClusters)], 0.3)
+	}
+	return queries
+}
+
+/*
+乱数のベクトルを生成する関数
+  - random		乱数生成器
+  - center		中心のベクトル（nil の場合は原点）
+  - scale		中心からのばらつきの大きさ
+  - return)		生成したベクトル
+*/
+func randomVector(random *rand.Rand, center []float32, scale float32) []float32 {
+	vector := make([]float32, benchmarkDimensions)
+	for i := range vector {
+		vector[i] = float32(random.NormFloat64()) * scale
+		if center != nil {
+			vector[i] += center[i]
+		}
+	}
+	return vector
+}
+
+/*
+ベンチマーク用のテーブルで類似ベクトルを検索する関数（GetSimilarChunks と同じ式で並べ替える）
+  - ctx			コンテキスト
+  - vector		クエリベクトル
+  - efSearch	HNSW の候補リストのサイズ（0 の場合はインデックスを使わずに全件検索する）
+  - return) ids	類似度順の ID
+  - return) err	エラー
+*/
+func searchBenchmarkTable(ctx context.Context, vector []float32, efSearch int) (ids []int64, err error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if efSearch > 0 {
+		err = setEfSearch(ctx, tx, efSearch, benchmarkLimit)
+	} else {
+		_, err = tx.ExecContext(ctx, "SET LOCAL enable_indexscan = off")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vectorStr := vectorToString(vector)
+	err = tx.NewSelect().
+		Model((*benchmarkVector)(nil)).
+		Column("id").
+		Where("nlp_config_id = ?", benchmarkConfigId).
+		OrderExpr("vector::vector(?) <=> ?", benchmarkDimensions, vectorStr).
+		Limit(benchmarkLimit).
+		Scan(ctx, &ids)
+	return ids, err
+}
+
+/*
+全件検索の結果に対する HNSW インデックスでの検索結果の再現率（平均）を計算する関数
+  - tb			テストまたはベンチマーク
+  - queries		クエリベクトル
+  - efSearch	HNSW の候補リストのサイズ
+  - return)		再現率（0〜1）
+*/
+func measureRecall(tb testing.TB, queries [][]float32, efSearch int) float64 {
+	ctx := context.Background()
+	totalRecall := 0.0
+	for _, query := range queries {
+		exactIds, err := searchBenchmarkTable(ctx, query, 0)
+		if err != nil {
+			tb.Fatalf("全件検索に失敗しました: %v", err)
+		}
+		approximateIds, err := searchBenchmarkTable(ctx, query, efSearch)
+		if err != nil {
+			tb.Fatalf("HNSW インデックスでの検索に失敗しました: %v", err)
+		}
+
+		exactSet := make(map[int64]bool, len(exactIds))
+		for _, id := range exactIds {
+			exactSet[id] = true
+		}
+		hits := 0
+		for _, id := range approximateIds {
+			if exactSet[id] {
+				hits++
+			}
+		}
+		totalRecall += float64(hits) / float64(len(exactIds))
+	}
+	return totalRecall / float64(len(queries))
+}
+
+func TestHNSWRecall(t *testing.T) {
+	queries := setupBenchmarkTable(t)
+
+	// HNSW インデックスが使われていることを確認
+	var plan []string
+	err := db.NewRaw("EXPLAIN SELECT id FROM ? WHERE nlp_config_id = ? ORDER BY vector::vector(?) <=> ? LIMIT ?",
+		bun.Ident(benchmarkTable), benchmarkConfigId, benchmarkDimensions, vectorToString(queries[0]), benchmarkLimit).
+		Scan(context.Background(), &plan)
+	if err != nil {
+		t.Fatalf("実行計画の取得に失敗しました: %v", err)
+	}
+	usesIndex := false
+	for _, line := range plan {
+		if strings.Contains(line, benchmarkTable+"_hnsw") {
+			usesIndex = true
+		}
+	}
+	if !usesIndex {
+		t.Errorf("HNSW インデックスが使われていません: %v", plan)
+	}
+
+	testCases := []struct {
+		efSearch          int
+		expectedMinRecall float64
+	}{
+		{10, 0.6},
+		{40, 0.8},
+		{100, 0.9},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("ef_search=%d", tc.efSearch), func(t *testing.T) {
+			recall := measureRecall(t, queries, tc.efSearch)
+			t.Logf("ef_search=%d の再現率: %.3f", tc.efSearch, recall)
+			if recall < tc.expectedMinRecall {
+				t.Errorf("期待される再現率 '%.2f' 以上ですが、実際は '%.3f' でした", tc.expectedMinRecall, recall)
+			}
+		})
+	}
+}
+
+func BenchmarkHNSWSearch(b *testing.B) {
+	queries := setupBenchmarkTable(b)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name     string
+		efSearch int
+	}{
+		{"exact", 0},
+		{"hnsw_ef_search_40", 40},
+		{"hnsw_ef_search_100", 100},
+	}
+
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := searchBenchmarkTable(ctx, queries[i%len(queries)], tc.efSearch); err != nil {
+					b.Fatalf("検索に失敗しました: %v", err)
+				}
+			}
+
+			// 全件検索に対する再現率を結果に併記（計測時間には含めない）
+			if tc.efSearch > 0 {
+				b.StopTimer()
+				b.ReportMetric(measureRecall(b, queries, tc.efSearch), "recall")
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// PostgreSQL を利用するための関数をまとめたパッケージ
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"app/controller/log"
+	"app/controller/postgres/migrations"
+
+	"github.com/uptrace/bun/migrate"
+)
+
+/*
+マイグレーションを実行する Migrator を作成する関数（適用履歴は bun_migrations テーブルに記録される）
+  - return)	Migrator
+*/
+func newMigrator() *migrate.Migrator {
+	// 失敗したマイグレーションを適用済みとして記録しないよう、成功後に記録する
+	return migrate.NewMigrator(db, migrations.Migrations, migrate.WithMarkAppliedOnSuccess(true))
+}
+
+/*
+未適用のマイグレーションをバージョン順にすべて適用する関数（-mode=migrate で使用）
+  - ctx			コンテキスト
+  - return) err	エラー
+*/
+func Migrate(ctx context.Context) (err error) {
+	migrator := newMigrator()
+	err = migrator.Init(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// 複数のプロセスから同時に実行されないようにロックする
+	err = migrator.Lock(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer migrator.Unlock(ctx)
+
+	group, err := migrator.Migrate(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	if group.IsZero() {
+		log.Info("適用するマイグレーションはありません")
+		return nil
+	}
+	log.Info(fmt.Sprintf("マイグレーションを適用しました: %s", group))
+
+	return nil
+}
+
+/*
+最後に適用したマイグレーションのグループ（1 回の Migrate で適用したもの）を取り消す関数（-mode=rollback で使用）
+  - ctx			コンテキスト
+  - return) err	エラー
+*/
+func Rollback(ctx context.Context) (err error) {
+	migrator := newMigrator()
+	err = migrator.Init(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	err = migrator.Lock(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer migrator.Unlock(ctx)
+
+	group, err := migrator.Rollback(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	if group.IsZero() {
+		log.Info("取り消すマイグレーションはありません")
+		return nil
+	}
+	log.Info(fmt.Sprintf("マイグレーションを取り消しました: %s", group))
+
+	return nil
+}
+
+/*
+DB のスキーマが最新のマイグレーションまで適用されていることを確認する関数（起動時に使用）
+  - ctx			コンテキスト
+  - return) err	エラー（未適用のマイグレーションがある場合）
+*/
+func CheckSchemaVersion(ctx context.Context) (err error) {
+	migrator := newMigrator()
+	err = migrator.Init(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	unapplied := ms.Unapplied()
+	if len(unapplied) > 0 {
+		err = fmt.Errorf("スキーマのバージョンが古いため起動できません、-mode=migrate を実行してください（未適用のマイグレーション: %s）", unapplied)
+		log.Error(err)
+		return err
+	}
+
+	// アプリケーションより新しいマイグレーションが適用されている場合（古いバージョンへの切り戻しなど）は警告のみ
+	missing, err := migrator.MissingMigrations(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	if len(missing) > 0 {
+		log.Info(fmt.Sprintf("アプリケーションにないマイグレーションが適用されています: %s", missing))
+	}
+
+	return nil
+}
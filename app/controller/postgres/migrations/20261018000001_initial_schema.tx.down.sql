@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "nlp_configs";
+--bun:split
+DROP TABLE IF EXISTS "vectors";
+--bun:split
+DROP TABLE IF EXISTS "chunks";
+--bun:split
+DROP TABLE IF EXISTS "pages";
+--bun:split
+DROP TABLE IF EXISTS "domains";
@@ -0,0 +1,10 @@
+-- マイグレーション導入前の初期スキーマ（InitTable で作成済みのデータベースでは何もしない）
+CREATE TABLE IF NOT EXISTS "domains" ("id" BIGSERIAL NOT NULL, "domain" varchar(100) NOT NULL, "created_at" timestamptz NOT NULL DEFAULT current_timestamp, "updated_at" timestamptz NOT NULL DEFAULT current_timestamp, "deleted_at" timestamptz, PRIMARY KEY ("id"), UNIQUE ("domain"));
+--bun:split
+CREATE TABLE IF NOT EXISTS "pages" ("id" BIGSERIAL NOT NULL, "domain_id" BIGINT NOT NULL, "path" varchar(255) NOT NULL, "title" varchar(100) NOT NULL, "description" varchar(255) NOT NULL, "keywords" varchar(255) NOT NULL, "markdown" text NOT NULL, "hash" char(64) NOT NULL, "created_at" timestamptz NOT NULL DEFAULT current_timestamp, "updated_at" timestamptz NOT NULL DEFAULT current_timestamp, "deleted_at" timestamptz, PRIMARY KEY ("id"), CONSTRAINT "page_unique" UNIQUE ("domain_id", "path"));
+--bun:split
+CREATE TABLE IF NOT EXISTS "chunks" ("id" BIGSERIAL NOT NULL, "nlp_config_id" BIGINT NOT NULL, "page_id" BIGINT NOT NULL, "chunk" text NOT NULL, "created_at" timestamptz NOT NULL DEFAULT current_timestamp, "updated_at" timestamptz NOT NULL DEFAULT current_timestamp, "deleted_at" timestamptz, PRIMARY KEY ("id"), CONSTRAINT "chunk_unique" UNIQUE ("nlp_config_id", "page_id", "chunk"));
+--bun:split
+CREATE TABLE IF NOT EXISTS "vectors" ("id" BIGSERIAL NOT NULL, "nlp_config_id" BIGINT NOT NULL, "chunk_id" BIGINT NOT NULL, "vector" vector(384) NOT NULL, "created_at" timestamptz NOT NULL DEFAULT current_timestamp, "updated_at" timestamptz NOT NULL DEFAULT current_timestamp, "deleted_at" timestamptz, PRIMARY KEY ("id"), UNIQUE ("chunk_id"));
+--bun:split
+CREATE TABLE IF NOT EXISTS "nlp_configs" ("id" BIGSERIAL NOT NULL, "max_token_length" BIGINT NOT NULL, "overlap_token_length" BIGINT NOT NULL, "model_name" varchar(100) NOT NULL, "model_vector_length" BIGINT NOT NULL, "created_at" timestamptz NOT NULL DEFAULT current_timestamp, "updated_at" timestamptz NOT NULL DEFAULT current_timestamp, "deleted_at" timestamptz, PRIMARY KEY ("id"), CONSTRAINT "config_unique" UNIQUE ("max_token_length", "overlap_token_length", "model_name", "model_vector_length"));
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS "crawl_failures";
+--bun:split
+DROP TABLE IF EXISTS "crawl_runs";
+--bun:split
+DROP TABLE IF EXISTS "page_histories";
+--bun:split
+ALTER TABLE "pages"
+  DROP COLUMN IF EXISTS "content_type",
+  DROP COLUMN IF EXISTS "etag",
+  DROP COLUMN IF EXISTS "last_modified",
+  DROP COLUMN IF EXISTS "status_code",
+  DROP COLUMN IF EXISTS "last_fetched_at";
+--bun:split
+ALTER TABLE "domains"
+  DROP COLUMN IF EXISTS "start_paths",
+  DROP COLUMN IF EXISTS "allowed_paths",
+  DROP COLUMN IF EXISTS "denied_paths",
+  DROP COLUMN IF EXISTS "max_depth",
+  DROP COLUMN IF EXISTS "delay_ms",
+  DROP COLUMN IF EXISTS "is_active",
+  DROP COLUMN IF EXISTS "purge_grace_days";
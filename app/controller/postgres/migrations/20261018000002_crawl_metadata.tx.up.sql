@@ -0,0 +1,22 @@
+-- ドメインごとのクロール設定、ページの取得状態、変更履歴・クロール実行履歴のテーブルを追加
+ALTER TABLE "domains"
+  ADD COLUMN IF NOT EXISTS "start_paths" text[] NOT NULL DEFAULT '{/}',
+  ADD COLUMN IF NOT EXISTS "allowed_paths" text[] NOT NULL DEFAULT '{/}',
+  ADD COLUMN IF NOT EXISTS "denied_paths" text[] NOT NULL DEFAULT '{}',
+  ADD COLUMN IF NOT EXISTS "max_depth" BIGINT NOT NULL DEFAULT 15,
+  ADD COLUMN IF NOT EXISTS "delay_ms" BIGINT NOT NULL DEFAULT 1000,
+  ADD COLUMN IF NOT EXISTS "is_active" BOOLEAN NOT NULL DEFAULT true,
+  ADD COLUMN IF NOT EXISTS "purge_grace_days" BIGINT NOT NULL DEFAULT 30;
+--bun:split
+ALTER TABLE "pages"
+  ADD COLUMN IF NOT EXISTS "content_type" varchar(100) NOT NULL DEFAULT 'text/html',
+  ADD COLUMN IF NOT EXISTS "etag" varchar(255) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS "last_modified" varchar(100) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS "status_code" BIGINT NOT NULL DEFAULT 200,
+  ADD COLUMN IF NOT EXISTS "last_fetched_at" timestamptz NOT NULL DEFAULT current_timestamp;
+--bun:split
+CREATE TABLE IF NOT EXISTS "page_histories" ("id" BIGSERIAL NOT NULL, "page_id" BIGINT NOT NULL, "change_type" varchar(20) NOT NULL, "previous_hash" char(64) NOT NULL, "hash" char(64) NOT NULL, "created_at" timestamptz NOT NULL DEFAULT current_timestamp, PRIMARY KEY ("id"));
+--bun:split
+CREATE TABLE IF NOT EXISTS "crawl_runs" ("id" BIGSERIAL NOT NULL, "domain_id" BIGINT NOT NULL, "status" varchar(20) NOT NULL, "started_at" timestamptz NOT NULL, "finished_at" timestamptz, "pages_visited" BIGINT NOT NULL DEFAULT 0, "pages_new" BIGINT NOT NULL DEFAULT 0, "pages_changed" BIGINT NOT NULL DEFAULT 0, "pages_unchanged" BIGINT NOT NULL DEFAULT 0, "pages_deleted" BIGINT NOT NULL DEFAULT 0, "pages_failed" BIGINT NOT NULL DEFAULT 0, "error_counts" jsonb NOT NULL DEFAULT '{}', "error_message" text NOT NULL DEFAULT '', "created_at" timestamptz NOT NULL DEFAULT current_timestamp, "updated_at" timestamptz NOT NULL DEFAULT current_timestamp, PRIMARY KEY ("id"));
+--bun:split
+CREATE TABLE IF NOT EXISTS "crawl_failures" ("id" BIGSERIAL NOT NULL, "crawl_run_id" BIGINT NOT NULL, "url" text NOT NULL, "error_type" varchar(20) NOT NULL, "status_code" BIGINT NOT NULL DEFAULT 0, "message" text NOT NULL, "created_at" timestamptz NOT NULL DEFAULT current_timestamp, PRIMARY KEY ("id"));
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS "nlp_configs_active_unique";
+--bun:split
+ALTER TABLE "nlp_configs" DROP CONSTRAINT IF EXISTS "config_unique";
+--bun:split
+ALTER TABLE "nlp_configs"
+  DROP COLUMN IF EXISTS "pooling",
+  DROP COLUMN IF EXISTS "normalized",
+  DROP COLUMN IF EXISTS "is_active";
+--bun:split
+ALTER TABLE "nlp_configs" ADD CONSTRAINT "config_unique" UNIQUE ("max_token_length", "overlap_token_length", "model_name", "model_vector_length");
+--bun:split
+-- 384 次元以外の NLP設定のベクトルが保存されている場合は失敗するため、先に削除しておく必要がある
+ALTER TABLE "vectors" ALTER COLUMN "vector" TYPE vector(384);
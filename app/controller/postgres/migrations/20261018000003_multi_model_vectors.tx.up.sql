@@ -0,0 +1,19 @@
+-- NLP設定ごとに次元数の異なるモデルのベクトルを保存できるよう、vector 列の次元数の固定をやめる
+ALTER TABLE "vectors" ALTER COLUMN "vector" TYPE vector;
+--bun:split
+-- プーリング戦略と L2 正規化を NLP設定に追加（既存の NLP設定は正規化なしの平均プーリングで作成されたもの）
+ALTER TABLE "nlp_configs"
+  ADD COLUMN IF NOT EXISTS "pooling" varchar(20) NOT NULL DEFAULT 'mean',
+  ADD COLUMN IF NOT EXISTS "normalized" BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS "is_active" BOOLEAN NOT NULL DEFAULT false;
+--bun:split
+ALTER TABLE "nlp_configs"
+  ALTER COLUMN "pooling" DROP DEFAULT,
+  ALTER COLUMN "normalized" DROP DEFAULT;
+--bun:split
+ALTER TABLE "nlp_configs" DROP CONSTRAINT IF EXISTS "config_unique";
+--bun:split
+ALTER TABLE "nlp_configs" ADD CONSTRAINT "config_unique" UNIQUE ("max_token_length", "overlap_token_length", "model_name", "model_vector_length", "pooling", "normalized");
+--bun:split
+-- 検索に使用する NLP設定（is_active = true）は 1 件のみとする
+CREATE UNIQUE INDEX IF NOT EXISTS "nlp_configs_active_unique" ON "nlp_configs" ("is_active") WHERE (is_active);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "chunks_chunk_trgm";
@@ -0,0 +1,4 @@
+-- キーワード検索（ハイブリッド検索）用に、チャンクの本文にトライグラムの GIN インデックスを作成
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+--bun:split
+CREATE INDEX IF NOT EXISTS "chunks_chunk_trgm" ON "chunks" USING gin ("chunk" gin_trgm_ops);
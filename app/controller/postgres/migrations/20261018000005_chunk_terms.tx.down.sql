@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS "chunks_chunk_trgm" ON "chunks" USING gin ("chunk" gin_trgm_ops);
+--bun:split
+DROP INDEX IF EXISTS "chunks_terms";
+--bun:split
+ALTER TABLE "chunks" DROP COLUMN IF EXISTS "terms";
@@ -0,0 +1,8 @@
+-- キーワード検索用に、チャンクを形態素解析した語の列と GIN インデックスを追加
+-- 既存のチャンクは空のため、-mode=repair で保存し直すと検索対象になる
+ALTER TABLE "chunks" ADD COLUMN IF NOT EXISTS "terms" text[] NOT NULL DEFAULT '{}';
+--bun:split
+CREATE INDEX IF NOT EXISTS "chunks_terms" ON "chunks" USING gin (array_to_tsvector("terms"));
+--bun:split
+-- トライグラムでの照合は語の列での照合に置き換える
+DROP INDEX IF EXISTS "chunks_chunk_trgm";
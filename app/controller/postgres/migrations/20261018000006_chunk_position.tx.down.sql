@@ -0,0 +1 @@
+ALTER TABLE "chunks" DROP COLUMN IF EXISTS "position";
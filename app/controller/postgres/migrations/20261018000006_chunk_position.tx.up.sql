@@ -0,0 +1,3 @@
+-- 検索結果にチャンクのページ内での位置を返すため、チャンクの順番の列を追加
+-- 既存のチャンクは 0 のため、-mode=repair で保存し直すと位置が記録される
+ALTER TABLE "chunks" ADD COLUMN IF NOT EXISTS "position" integer NOT NULL DEFAULT 0;
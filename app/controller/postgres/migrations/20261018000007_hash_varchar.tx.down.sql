@@ -0,0 +1,5 @@
+ALTER TABLE "page_histories"
+  ALTER COLUMN "previous_hash" TYPE char(64),
+  ALTER COLUMN "hash" TYPE char(64);
+--bun:split
+ALTER TABLE "pages" ALTER COLUMN "hash" TYPE char(64);
@@ -0,0 +1,7 @@
+-- ハッシュ値は SHA-1（40 文字）のため、char(64) では末尾が空白で埋められ Go 側の比較で一致しなくなる
+-- 可変長の型に変更し、既存の値の末尾の空白を取り除く
+ALTER TABLE "pages" ALTER COLUMN "hash" TYPE varchar(64) USING rtrim("hash");
+--bun:split
+ALTER TABLE "page_histories"
+  ALTER COLUMN "previous_hash" TYPE varchar(64) USING rtrim("previous_hash"),
+  ALTER COLUMN "hash" TYPE varchar(64) USING rtrim("hash");
@@ -0,0 +1,3 @@
+-- 304 Not Modified の場合もページ内のリンクをたどれるよう、前回取得したページ内のリンク先のパスを保存する
+-- NULL はリンクを記録していないページ（次回のクロールで条件なしで取得して記録する）
+ALTER TABLE "pages" ADD COLUMN "links" text[];
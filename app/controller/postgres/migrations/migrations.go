@@ -0,0 +1,28 @@
+// DB のスキーマのマイグレーション（バージョン管理された SQL ファイル）をまとめたパッケージ
+package migrations
+
+import (
+	"embed"
+
+	"github.com/uptrace/bun/migrate"
+)
+
+/*
+マイグレーションの SQL ファイル
+  - ファイル名は `<バージョン>_<名前>.tx.up.sql`（適用）と `<バージョン>_<名前>.tx.down.sql`（取り消し）
+  - バージョンは作成日時（YYYYMMDDhhmmss）で、小さい順に適用される
+  - .tx. を含むファイルはトランザクション内で実行され、途中で失敗した場合は全体が取り消される
+  - 複数の SQL 文は `--bun:split` の行で区切る
+  - 適用済みのファイルは変更せず、スキーマを変更する場合は新しいバージョンのファイルを追加する
+*/
+//go:embed *.sql
+var sqlFiles embed.FS
+
+// アプリケーションのマイグレーションの一覧
+var Migrations = migrate.NewMigrations()
+
+func init() {
+	if err := Migrations.Discover(sqlFiles); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	sorted := Migrations.Sorted()
+	if len(sorted) == 0 {
+		t.Fatalf("期待されるマイグレーション数は 1 以上ですが、実際は 0 でした")
+	}
+
+	for i, migration := range sorted {
+		// 取り消せるよう、すべてのマイグレーションに適用（up）と取り消し（down）の両方が必要
+		if migration.Up == nil {
+			t.Errorf("マイグレーション '%s' の up.sql がありません", migration)
+		}
+		if migration.Down == nil {
+			t.Errorf("マイグレーション '%s' の down.sql がありません", migration)
+		}
+
+		// バージョンは重複せず、桁数をそろえて文字列の順番が適用順と一致するようにする
+		if len(migration.Name) != len(sorted[0].Name) {
+			t.Errorf("期待されるバージョンの桁数は '%d' ですが、実際は '%d' でした: %s", len(sorted[0].Name), len(migration.Name), migration)
+		}
+		if i > 0 && migration.Name <= sorted[i-1].Name {
+			t.Errorf("マイグレーション '%s' のバージョンが '%s' と重複しているか、順番が正しくありません", migration, sorted[i-1])
+		}
+	}
+}
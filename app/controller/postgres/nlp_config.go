@@ -0,0 +1,179 @@
+// PostgreSQL を利用するための関数をまとめたパッケージ
+package postgres
+
+import (
+	"app/controller/log"
+	"app/domain/model"
+	"app/usecase/entity"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+/*
+NLP設定の内容（モデル名、トークン長、次元数、プーリングなど）から保存済みの NLP設定を取得する関数
+  - ctx				コンテキスト
+  - info			NLP設定の内容（nlp サーバーのレスポンスに含まれるもの）
+  - return) config	NLP設定
+  - return) err		エラー（存在しない場合は sql.ErrNoRows）
+*/
+func GetNlpConfig(ctx context.Context, info model.NlpConfigInfo) (config entity.DBNlpConfig, err error) {
+	err = db.NewSelect().
+		Model(&config).
+		Where("max_token_length = ?", info.MaxTokenLength).
+		Where("overlap_token_length = ?", info.OverlapTokenLength).
+		Where("model_name = ?", info.ModelName).
+		Where("model_vector_length = ?", info.ModelVectorLength).
+		Where("pooling = ?", info.Pooling).
+		Where("normalized = ?", info.Normalized).
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+	}
+	return config, err
+}
+
+/*
+NLP設定の一覧を取得する関数
+  - ctx				コンテキスト
+  - return) configs	NLP設定のスライス（ID 順）
+  - return) err		エラー
+*/
+func GetNlpConfigs(ctx context.Context) (configs []entity.DBNlpConfig, err error) {
+	err = db.NewSelect().
+		Model(&configs).
+		OrderExpr("id ASC").
+		Scan(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+/*
+検索に使用する（有効な）NLP設定を取得する関数
+  - ctx				コンテキスト
+  - return) config	NLP設定
+  - return) err		エラー（有効な NLP設定がない場合は sql.ErrNoRows）
+*/
+func GetActiveNlpConfig(ctx context.Context) (config entity.DBNlpConfig, err error) {
+	err = db.NewSelect().
+		Model(&config).
+		Where("is_active").
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+	}
+	return config, err
+}
+
+/*
+指定の NLP設定を検索に使用する設定にする関数（他の NLP設定は無効になる）
+  - ctx				コンテキスト
+  - nlpConfigId		NLP設定ID
+  - return) config	有効にした NLP設定
+  - return) err		エラー（存在しない場合は sql.ErrNoRows）
+*/
+func ActivateNlpConfig(ctx context.Context, nlpConfigId int64) (config entity.DBNlpConfig, err error) {
+	// トランザクション開始
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error(err)
+		return config, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = tx.NewSelect().
+		Model(&config).
+		Where("id = ?", nlpConfigId).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Error(err)
+		}
+		return config, err
+	}
+
+	// 部分ユニークインデックスに違反しないよう、先に有効な NLP設定を無効にする
+	_, err = tx.NewUpdate().
+		Model((*entity.DBNlpConfig)(nil)).
+		Set("is_active = false").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Where("is_active").
+		Where("id != ?", nlpConfigId).
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return config, err
+	}
+
+	config.IsActive = true
+	config.UpdatedAt = time.Now()
+	_, err = tx.NewUpdate().
+		Model(&config).
+		Column("is_active", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return config, err
+	}
+
+	// トランザクションコミット
+	if err = tx.Commit(); err != nil {
+		log.Error(err)
+		return config, err
+	}
+
+	return config, nil
+}
+
+/*
+指定の NLP設定以外のチャンクとベクトルを物理削除する関数（NLP_INDEX_MODELS から外したモデルのデータの削除用）
+  - ctx						コンテキスト
+  - keepNlpConfigIds		チャンクとベクトルを残す NLP設定ID のリスト（空の場合は何も削除しない）
+  - return) deletedVectors	削除したベクトル数
+  - return) deletedChunks	削除したチャンク数
+  - return) err				エラー
+*/
+func DeleteUnindexedChunks(ctx context.Context, keepNlpConfigIds []int64) (deletedVectors int64, deletedChunks int64, err error) {
+	if len(keepNlpConfigIds) == 0 {
+		return 0, 0, nil
+	}
+
+	result, err := db.NewDelete().
+		Model((*entity.DBVector)(nil)).
+		Where("nlp_config_id NOT IN (?)", bun.In(keepNlpConfigIds)).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, 0, err
+	}
+	deletedVectors, _ = result.RowsAffected()
+
+	result, err = db.NewDelete().
+		Model((*entity.DBChunk)(nil)).
+		Where("nlp_config_id NOT IN (?)", bun.In(keepNlpConfigIds)).
+		WhereAllWithDeleted().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, 0, err
+	}
+	deletedChunks, _ = result.RowsAffected()
+
+	return deletedVectors, deletedChunks, nil
+}
@@ -4,10 +4,10 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"os"
 
 	"app/controller/log"
-	"app/usecase/entity"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
@@ -37,53 +37,45 @@ func Connect() (err error) {
 }
 
 /*
-DB の初期化をする関数
+DB の接続を閉じる関数（シャットダウン時に使用）
   - return) err	エラー
 */
-func InitTable() (err error) {
-	_, err = db.NewCreateTable().
-		Model((*entity.DBDomain)(nil)).
-		IfNotExists().
-		Exec(context.Background())
-	if err != nil {
-		log.Error(err)
-		return
+func Close() (err error) {
+	if db == nil {
+		return nil
 	}
-
-	_, err = db.NewCreateTable().
-		Model((*entity.DBPage)(nil)).
-		IfNotExists().
-		Exec(context.Background())
+	err = db.Close()
 	if err != nil {
 		log.Error(err)
-		return
+		return err
 	}
 
-	_, err = db.NewCreateTable().
-		Model((*entity.DBChunk)(nil)).
-		IfNotExists().
-		Exec(context.Background())
-	if err != nil {
-		log.Error(err)
-		return
-	}
+	return nil
+}
 
-	_, err = db.NewCreateTable().
-		Model((*entity.DBVector)(nil)).
-		IfNotExists().
-		Exec(context.Background())
+/*
+DB に接続でき、pgvector 拡張機能が有効であることを確認する関数（readiness チェック用）
+  - ctx				コンテキスト
+  - return) err		エラー
+*/
+func Ping(ctx context.Context) (err error) {
+	if db == nil {
+		return errors.New("データベースに接続されていません")
+	}
+	err = db.PingContext(ctx)
 	if err != nil {
-		log.Error(err)
-		return
+		return err
 	}
 
-	_, err = db.NewCreateTable().
-		Model((*entity.DBNlpConfig)(nil)).
-		IfNotExists().
-		Exec(context.Background())
+	exists, err := db.NewSelect().
+		Table("pg_extension").
+		Where("extname = ?", "vector").
+		Exists(ctx)
 	if err != nil {
-		log.Error(err)
-		return
+		return err
+	}
+	if !exists {
+		return errors.New("pgvector 拡張機能が有効になっていません")
 	}
 
 	return nil
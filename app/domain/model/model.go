@@ -1,6 +1,8 @@
 // ドメイン（業務知識）のデータ構造を定義するパッケージ
 package model
 
+import "time"
+
 /*
 ## ドメイン分離の考え方
 データ構造のうち、ドメイン領域のものを domain/model、そうでないものを usecase/entity に分離する構成をとっている。
@@ -19,29 +21,85 @@ type DomainInfo struct {
 	Domain string `bun:"domain,notnull,unique,type:varchar(100)" json:"domain"` // ドメイン
 }
 
+// ドメインごとのクロール設定情報
+type CrawlConfig struct {
+	StartPaths     []string `bun:"start_paths,array,notnull,default:'{/}',type:text[]" json:"start_paths"`     // クロールを開始するパスのリスト
+	AllowedPaths   []string `bun:"allowed_paths,array,notnull,default:'{/}',type:text[]" json:"allowed_paths"` // パスに必ず含まれなければならない文字列のリスト
+	DeniedPaths    []string `bun:"denied_paths,array,notnull,default:'{}',type:text[]" json:"denied_paths"`    // パスに含まれていたら除外する文字列のリスト
+	MaxDepth       int      `bun:"max_depth,notnull,default:15" json:"max_depth"`                              // 最大スクレイピング深度
+	DelayMs        int      `bun:"delay_ms,notnull,default:1000" json:"delay_ms"`                              // リクエスト間の最小遅延（ミリ秒）
+	IsActive       bool     `bun:"is_active,notnull,default:true" json:"is_active"`                            // クロール対象かどうか
+	PurgeGraceDays int      `bun:"purge_grace_days,notnull,default:30" json:"purge_grace_days"`                // 削除されたページを完全に削除するまでの猶予日数
+}
+
 // ページコンテンツ情報
 type PageInfo struct {
-	DomainID    int64  `bun:"domain_id,notnull,unique:page_unique"`                          // ドメインID
-	Path        string `bun:"path,notnull,unique:page_unique,type:varchar(255)" json:"path"` // パス
-	Title       string `bun:"title,notnull,type:varchar(100)" json:"title"`                  // ページタイトル
-	Description string `bun:"description,notnull,type:varchar(255)" json:"description"`      // ディスクリプション
-	Keywords    string `bun:"keywords,notnull,type:varchar(255)" json:"keywords"`            // キーワード
-	Markdown    string `bun:"markdown,notnull,type:text" json:"markdown"`                    // Markdown コンテンツ
-	Hash        string `bun:"hash,notnull,type:char(64)" json:"-"`                           // コンテンツのハッシュ値
+	DomainID    int64  `bun:"domain_id,notnull,unique:page_unique"`                                           // ドメインID
+	Path        string `bun:"path,notnull,unique:page_unique,type:varchar(255)" json:"path"`                  // パス
+	Title       string `bun:"title,notnull,type:varchar(100)" json:"title"`                                   // ページタイトル
+	Description string `bun:"description,notnull,type:varchar(255)" json:"description"`                       // ディスクリプション
+	Keywords    string `bun:"keywords,notnull,type:varchar(255)" json:"keywords"`                             // キーワード
+	Markdown    string `bun:"markdown,notnull,type:text" json:"markdown"`                                     // Markdown コンテンツ
+	Hash        string `bun:"hash,notnull,type:varchar(64)" json:"-"`                                         // コンテンツのハッシュ値
+	ContentType string `bun:"content_type,notnull,default:'text/html',type:varchar(100)" json:"content_type"` // コンテンツの種類（text/html, application/pdf）
+}
+
+// ページの取得状態情報（条件付きリクエストと変更検知に使用）
+type FetchInfo struct {
+	ETag          string    `bun:"etag,notnull,default:'',type:varchar(255)" json:"-"`                                        // ETag ヘッダー
+	LastModified  string    `bun:"last_modified,notnull,default:'',type:varchar(100)" json:"-"`                               // Last-Modified ヘッダー
+	StatusCode    int       `bun:"status_code,notnull,default:200" json:"-"`                                                  // 最終取得時の HTTP ステータスコード
+	LastFetchedAt time.Time `bun:"last_fetched_at,notnull,default:current_timestamp,type:timestamptz" json:"last_fetched_at"` // 最終取得日時
+	Links         []string  `bun:"links,array,type:text[]" json:"-"`                                                          // ページ内のリンク先のパス（304 の場合にリンクをたどるために使用、nil は未記録）
+}
+
+// ページの変更履歴情報
+type PageHistoryInfo struct {
+	PageID       int64  `bun:"page_id,notnull" json:"page_id"`                              // ページID
+	ChangeType   string `bun:"change_type,notnull,type:varchar(20)" json:"change_type"`     // 変更の種類（created, updated, restored）
+	PreviousHash string `bun:"previous_hash,notnull,type:varchar(64)" json:"previous_hash"` // 変更前のコンテンツのハッシュ値
+	Hash         string `bun:"hash,notnull,type:varchar(64)" json:"hash"`                   // 変更後のコンテンツのハッシュ値
+}
+
+// クロール実行情報（ドメイン単位のクロール 1 回分の結果）
+type CrawlRunInfo struct {
+	DomainID       int64          `bun:"domain_id,notnull" json:"domain_id"`                                // ドメインID
+	Status         string         `bun:"status,notnull,type:varchar(20)" json:"status"`                     // 実行状態（running, succeeded, failed, canceled）
+	StartedAt      time.Time      `bun:"started_at,notnull,type:timestamptz" json:"started_at"`             // 開始日時
+	FinishedAt     time.Time      `bun:"finished_at,nullzero,type:timestamptz" json:"finished_at,omitzero"` // 終了日時（実行中はゼロ値）
+	PagesVisited   int            `bun:"pages_visited,notnull,default:0" json:"pages_visited"`              // レスポンスを受け取ったページ数（304, 404 などを含む）
+	PagesNew       int            `bun:"pages_new,notnull,default:0" json:"pages_new"`                      // 新規作成（削除済みからの復活を含む）されたページ数
+	PagesChanged   int            `bun:"pages_changed,notnull,default:0" json:"pages_changed"`              // 内容が変更されたページ数
+	PagesUnchanged int            `bun:"pages_unchanged,notnull,default:0" json:"pages_unchanged"`          // 内容に変更がなかったページ数
+	PagesDeleted   int            `bun:"pages_deleted,notnull,default:0" json:"pages_deleted"`              // 論理削除したページ数
+	PagesFailed    int            `bun:"pages_failed,notnull,default:0" json:"pages_failed"`                // 処理に失敗したページ数
+	ErrorCounts    map[string]int `bun:"error_counts,notnull,default:'{}',type:jsonb" json:"error_counts"`  // 失敗の種類ごとの件数（fetch, parse, nlp, db）
+	ErrorMessage   string         `bun:"error_message,notnull,default:'',type:text" json:"error_message"`   // クロール全体が失敗した場合のエラーメッセージ
+}
+
+// クロール失敗情報（URL 単位）
+type CrawlFailureInfo struct {
+	CrawlRunID int64  `bun:"crawl_run_id,notnull" json:"crawl_run_id"`              // クロール実行ID
+	URL        string `bun:"url,notnull,type:text" json:"url"`                      // 失敗した URL
+	ErrorType  string `bun:"error_type,notnull,type:varchar(20)" json:"error_type"` // 失敗の種類（fetch, parse, nlp, db）
+	StatusCode int    `bun:"status_code,notnull,default:0" json:"status_code"`      // HTTP ステータスコード（レスポンスがない場合は 0）
+	Message    string `bun:"message,notnull,type:text" json:"message"`              // エラーメッセージ
 }
 
 // チャンク情報
 type ChunkInfo struct {
-	NlpConfigID int64  `bun:"nlp_config_id,notnull,unique:chunk_unique"`   // NLP設定ID
-	PageID      int64  `bun:"page_id,notnull,unique:chunk_unique"`         // ページID
-	Chunk       string `bun:"chunk,notnull,unique:chunk_unique,type:text"` // チャンク
+	NlpConfigID int64    `bun:"nlp_config_id,notnull,unique:chunk_unique"`    // NLP設定ID
+	PageID      int64    `bun:"page_id,notnull,unique:chunk_unique"`          // ページID
+	Chunk       string   `bun:"chunk,notnull,unique:chunk_unique,type:text"`  // チャンク
+	Terms       []string `bun:"terms,array,notnull,default:'{}',type:text[]"` // キーワード検索用の語（チャンクを形態素解析して正規化したもの）
+	Position    int      `bun:"position,notnull,default:0"`                   // ページ内のチャンクの順番（0 始まり）
 }
 
 // ベクトル情報
 type VectorInfo struct {
-	NlpConfigID int64     `bun:"nlp_config_id,notnull"`           // NLP設定ID
-	ChunkID     int64     `bun:"chunk_id,notnull,unique"`         // チャンクID
-	Vector      []float32 `bun:"vector,notnull,type:vector(384)"` // ベクトルデータ（モデルの次元数に合わせて変更）
+	NlpConfigID int64     `bun:"nlp_config_id,notnull"`      // NLP設定ID
+	ChunkID     int64     `bun:"chunk_id,notnull,unique"`    // チャンクID
+	Vector      []float32 `bun:"vector,notnull,type:vector"` // ベクトルデータ（次元数は NLP設定ごとに異なるため型では固定せず、ModelVectorLength と一致させる）
 }
 
 // NLP設定情報
@@ -50,6 +108,16 @@ type NlpConfigInfo struct {
 	OverlapTokenLength int64  `bun:"overlap_token_length,unique:config_unique,notnull" json:"overlap_token_length"`
 	ModelName          string `bun:"model_name,unique:config_unique,notnull,type:varchar(100)" json:"model_name"`
 	ModelVectorLength  int64  `bun:"model_vector_length,unique:config_unique,notnull" json:"model_vector_length"`
+	Pooling            string `bun:"pooling,unique:config_unique,notnull,type:varchar(20)" json:"pooling"` // プーリング戦略（mean, cls, max, last）
+	Normalized         bool   `bun:"normalized,unique:config_unique,notnull" json:"normalized"`            // ベクトルが L2 正規化済みかどうか
+}
+
+// 検索結果の絞り込み条件（空の項目では絞り込まない）
+type SearchFilter struct {
+	Domain       string    // ドメイン（完全一致）
+	PathPrefix   string    // パスの前方一致（"/kurashi/" など）
+	UpdatedAfter time.Time // ページの更新日時がこの日時より後（ゼロ値の場合は絞り込まない）
+	MinScore     *float32  // チャンクのスコアの下限（検索モードによってスコアの範囲が異なる、nil の場合は絞り込まない）
 }
 
 // 検索履歴情報
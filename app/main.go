@@ -2,57 +2,162 @@ package main
 
 import (
 	"app/controller/api"
+	"app/controller/crawler"
 	"app/controller/log"
 	"app/controller/postgres"
 	"app/test"
 	"app/usecase/scheduler"
+	"app/usecase/usecase"
+	"context"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
+// シャットダウン時に実行中のジョブとクロールの終了（中断結果の記録）を待つ最大時間
+const jobStopTimeout = 10 * time.Second
+
 func main() {
 	// flag パッケージを使ってモードを指定できるようにする
-	mode := flag.String("mode", "normal", "execution mode: normal or test")
+	mode := flag.String("mode", "normal", "execution mode: normal, test, repair, migrate or rollback")
 	flag.Parse()
 
+	// SIGTERM（コンテナ停止時）と SIGINT（Ctrl+C）でキャンセルされるコンテキスト
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	switch *mode {
 	case "test":
 		// -mode=test を指定した場合の処理
-		runTestMode()
+		runTestMode(ctx)
+	case "repair":
+		// -mode=repair を指定した場合の処理
+		runRepairMode(ctx)
+	case "migrate":
+		// -mode=migrate を指定した場合の処理
+		runMigrateMode(ctx)
+	case "rollback":
+		// -mode=rollback を指定した場合の処理
+		runRollbackMode(ctx)
 	default:
-		run()
+		run(ctx)
 	}
 }
 
-func runTestMode() {
+func runTestMode(ctx context.Context) {
 	log.Info("テストモード起動")
-	test.Start()
+	test.Start(ctx)
 }
 
-func run() {
+func runRepairMode(ctx context.Context) {
+	log.Info("修復モード起動")
+
+	err := postgres.Connect()
+	if err != nil {
+		return
+	}
+	err = postgres.CheckSchemaVersion(ctx)
+	if err != nil {
+		return
+	}
+
+	// 古いチャンクとベクトルを削除
+	err = usecase.RepairStaleChunks(ctx)
+	if err != nil {
+		return
+	}
+	log.Info("修復完了")
+}
+
+func runMigrateMode(ctx context.Context) {
+	log.Info("マイグレーションモード起動")
+
+	err := postgres.Connect()
+	if err != nil {
+		return
+	}
+	defer postgres.Close()
+
+	// 未適用のマイグレーションをすべて適用
+	err = postgres.Migrate(ctx)
+	if err != nil {
+		return
+	}
+	log.Info("マイグレーション完了")
+}
+
+func runRollbackMode(ctx context.Context) {
+	log.Info("ロールバックモード起動")
+
+	err := postgres.Connect()
+	if err != nil {
+		return
+	}
+	defer postgres.Close()
+
+	// 最後に適用したマイグレーションを取り消す
+	err = postgres.Rollback(ctx)
+	if err != nil {
+		return
+	}
+	log.Info("ロールバック完了")
+}
+
+func run(ctx context.Context) {
 	// =======================================================================
-	// データベース接続とテーブル初期化
+	// データベース接続とスキーマのバージョン確認（マイグレーションが未適用の場合は起動しない）
 	// =======================================================================
 	err := postgres.Connect()
 	if err != nil {
 		return
 	}
-	err = postgres.InitTable()
+	err = postgres.CheckSchemaVersion(ctx)
+	if err != nil {
+		return
+	}
+
+	// 保存済みの NLP設定ごとにベクトルの HNSW インデックスを作成
+	err = postgres.EnsureVectorIndexes(ctx)
 	if err != nil {
 		return
 	}
-	log.Info("データベース接続とテーブル初期化完了")
+	log.Info("データベース接続とスキーマのバージョン確認完了")
 
 	// =======================================================================
-	// スケジューラーを起動
+	// スケジューラーを起動（API サーバーの起動に失敗した場合も停止できるよう、キャンセルできるコンテキストを渡す）
 	// =======================================================================
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	log.Info("スケジューラー起動")
-	scheduler.SchedulerStart()
+	scheduler.SchedulerStart(ctx)
 
 	// =======================================================================
 	// API サーバーを起動
 	// =======================================================================
 	log.Info("API サーバー起動")
-	api.StartServer()
+	err = api.StartServer(ctx)
+	if err != nil {
+		// 起動の失敗や停止のタイムアウト（ストリーミング中の /rag_search など）でも、ジョブとクロールを止めて終了を待ってから DB の接続を閉じる
+		log.Error(err)
+		cancel()
+	}
+	log.Info("API サーバー停止")
+
+	// =======================================================================
+	// 実行中のジョブとクロールの終了を待って DB の接続を閉じる
+	// =======================================================================
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), jobStopTimeout)
+	defer waitCancel()
+	if err := scheduler.SchedulerWait(waitCtx); err != nil {
+		log.Error(err)
+	}
+	if err := crawler.WaitRunningCrawls(waitCtx); err != nil {
+		log.Error(err)
+	}
+	postgres.Close()
+	log.Info("シャットダウン完了")
 }
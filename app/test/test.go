@@ -4,11 +4,14 @@ import (
 	"app/controller/crawler"
 	"app/controller/log"
 	"app/controller/postgres"
+	"app/domain/model"
+	"app/usecase/entity"
+	"context"
 
 	_ "github.com/lib/pq"
 )
 
-func Start() {
+func Start(ctx context.Context) {
 	log.Info("テストモード起動")
 
 	// =======================================================================
@@ -18,7 +21,7 @@ func Start() {
 	if err != nil {
 		return
 	}
-	err = postgres.InitTable()
+	err = postgres.Migrate(ctx)
 	if err != nil {
 		return
 	}
@@ -28,17 +31,21 @@ func Start() {
 	// テスト内容
 	// =======================================================================
 	// 初期設定・定数
-	targetDomainId := 1
-	targetDomain := "www.city.hamura.tokyo.jp"
-	startPath := "/prsite/0000000440.html"
-	allowedPaths := []string{
-		"/prsite/0000000440.html",
+	targetDomain := entity.DBDomain{
+		ID:         1,
+		DomainInfo: model.DomainInfo{Domain: "www.city.hamura.tokyo.jp"},
+		CrawlConfig: model.CrawlConfig{
+			StartPaths:   []string{"/prsite/0000000440.html"},
+			AllowedPaths: []string{"/prsite/0000000440.html"},
+			MaxDepth:     7,
+			DelayMs:      1000,
+			IsActive:     true,
+		},
 	}
-	maxScrapeDepth := 7
 	isTest := true
 
 	// クロールを開始
-	err = crawler.CrawlDomain(int64(targetDomainId), targetDomain, startPath, allowedPaths, maxScrapeDepth, isTest)
+	err = crawler.CrawlDomain(ctx, targetDomain, isTest)
 	if err != nil {
 		log.Error(err)
 		return
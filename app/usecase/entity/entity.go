@@ -16,11 +16,12 @@ json: は API のレスポンスを生成時に使用されるタグ
 type DBDomain struct {
 	bun.BaseModel `bun:"table:domains"`
 
-	ID        int64     `bun:"id,pk,autoincrement"`                                 // ID
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`                                         // ID
 	model.DomainInfo
-	CreatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz"` // 作成日時
-	UpdatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz"` // 更新日時
-	DeletedAt time.Time `bun:",soft_delete,type:timestamptz"`                       // 削除日時
+	model.CrawlConfig
+	CreatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"-"`          // 作成日時
+	UpdatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"updated_at"` // 更新日時
+	DeletedAt time.Time `bun:",soft_delete,type:timestamptz" json:"-"`                                // 削除日時
 }
 
 // DB 用ページコンテンツ情報
@@ -29,12 +30,23 @@ type DBPage struct {
 
 	ID        int64     `bun:"id,pk,autoincrement" json:"-"`                                          // ID
 	model.PageInfo
+	model.FetchInfo
 	Domain    *DBDomain `bun:"rel:belongs-to,join:domain_id=id" json:"domain_info"`                   // ドメイン情報
 	CreatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"-"`          // 作成日時
 	UpdatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"updated_at"` // 更新日時
 	DeletedAt time.Time `bun:",soft_delete,type:timestamptz" json:"-"`                                // 削除日時
 }
 
+// DB 用ページ変更履歴情報
+type DBPageHistory struct {
+	bun.BaseModel `bun:"table:page_histories"`
+
+	ID        int64     `bun:"id,pk,autoincrement" json:"-"`                                          // ID
+	model.PageHistoryInfo
+	Page      *DBPage   `bun:"rel:belongs-to,join:page_id=id" json:"-"`                               // ページ情報
+	CreatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"created_at"` // 変更日時
+}
+
 // DB 用チャンク情報
 type DBChunk struct {
 	bun.BaseModel `bun:"table:chunks"`
@@ -64,9 +76,31 @@ type DBVector struct {
 type DBNlpConfig struct {
 	bun.BaseModel `bun:"table:nlp_configs"`
 
-	ID        int64     `bun:"id,pk,autoincrement"`                                 // ID
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`                                         // ID
 	model.NlpConfigInfo
-	CreatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz"` // 作成日時
-	UpdatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz"` // 更新日時
-	DeletedAt time.Time `bun:",soft_delete,type:timestamptz"`                       // 削除日時
+	IsActive  bool      `bun:"is_active,notnull,default:false" json:"is_active"`                      // 検索に使用する NLP設定かどうか（有効なものは 1 件のみ）
+	CreatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"created_at"` // 作成日時
+	UpdatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"updated_at"` // 更新日時
+	DeletedAt time.Time `bun:",soft_delete,type:timestamptz" json:"-"`                                // 削除日時
+}
+
+// DB 用クロール実行情報
+type DBCrawlRun struct {
+	bun.BaseModel `bun:"table:crawl_runs"`
+
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`                                         // ID
+	model.CrawlRunInfo
+	Domain    *DBDomain `bun:"rel:belongs-to,join:domain_id=id" json:"-"`                             // ドメイン情報
+	CreatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"-"`          // 作成日時
+	UpdatedAt time.Time `bun:",notnull,default:current_timestamp,type:timestamptz" json:"updated_at"` // 更新日時
+}
+
+// DB 用クロール失敗情報
+type DBCrawlFailure struct {
+	bun.BaseModel `bun:"table:crawl_failures"`
+
+	ID        int64       `bun:"id,pk,autoincrement" json:"id"`                                         // ID
+	model.CrawlFailureInfo
+	CrawlRun  *DBCrawlRun `bun:"rel:belongs-to,join:crawl_run_id=id" json:"-"`                          // クロール実行情報
+	CreatedAt time.Time   `bun:",notnull,default:current_timestamp,type:timestamptz" json:"created_at"` // 発生日時
 }
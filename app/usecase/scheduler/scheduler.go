@@ -3,6 +3,8 @@ package scheduler
 
 import (
 	"app/controller/log"
+	"context"
+	"sync"
 	"time"
 )
 
@@ -10,31 +12,67 @@ import (
 type Job struct {
 	Name        string
 	Duration    time.Duration
-	Function    func() error
+	Function    func(ctx context.Context) error
 	ExecuteFlag bool
 }
 type Jobs []Job
 
-// 定期実行を行う関数
-func schedulerExec(jobs Jobs) {
+// 実行中のジョブのゴルーチン（停止時に終了を待つため）
+var wg sync.WaitGroup
+
+// 定期実行を行う関数（ctx がキャンセルされると実行中のジョブを中断し、以降の実行を停止する）
+func schedulerExec(ctx context.Context, jobs Jobs) {
 	for _, job := range jobs {
 
 		// ExecuteFlag が true の場合のみ実行
 		if job.ExecuteFlag {
+			wg.Add(1)
 			go func(job Job) {
+				defer wg.Done()
 				for {
 					log.Info("  └─ " + job.Name)
-					job.Function()
-					time.Sleep(job.Duration)
+					job.Function(ctx)
+
+					// 次の実行まで待機（キャンセルされた場合は終了）
+					select {
+					case <-ctx.Done():
+						log.Info("  └─ 停止: " + job.Name)
+						return
+					case <-time.After(job.Duration):
+					}
 				}
 			}(job)
 		}
 		// Jobs を確実に上から実行するために1秒待機
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(1 * time.Second):
+		}
 	}
 }
 
 // 定期実行を開始する関数
-func SchedulerStart() {
-	schedulerExec(jobs)
+func SchedulerStart(ctx context.Context) {
+	schedulerExec(ctx, jobs)
+}
+
+/*
+定期実行のジョブがすべて停止するまで待機する関数（SchedulerStart に渡した ctx をキャンセルしてから呼び出す）
+  - ctx			待機を打ち切るためのコンテキスト（タイムアウトを指定する）
+  - return) err	待機を打ち切った場合は ctx のエラー
+*/
+func SchedulerWait(ctx context.Context) (err error) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
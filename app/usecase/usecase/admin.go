@@ -0,0 +1,296 @@
+// 各コントローラーへの処理をまとめ、動作単位にまとめた関数を定義するパッケージ
+package usecase
+
+import (
+	"app/controller/crawler"
+	"app/controller/log"
+	"app/controller/postgres"
+	"app/usecase/entity"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 入力値が不正な場合のエラー
+var ErrInvalidInput = errors.New("入力値が不正です")
+
+// 対象が存在しない場合のエラー
+var ErrNotFound = errors.New("対象が存在しません")
+
+// 登録済みのドメインや実行中のクロールと競合する場合のエラー
+var ErrConflict = errors.New("競合しています")
+
+// 管理 API からのドメイン作成・更新の入力（未指定の項目は nil、更新時は指定された項目のみ変更する）
+type DomainInput struct {
+	Domain         *string   `json:"domain"`
+	StartPaths     *[]string `json:"start_paths"`
+	AllowedPaths   *[]string `json:"allowed_paths"`
+	DeniedPaths    *[]string `json:"denied_paths"`
+	MaxDepth       *int      `json:"max_depth"`
+	DelayMs        *int      `json:"delay_ms"`
+	IsActive       *bool     `json:"is_active"`
+	PurgeGraceDays *int      `json:"purge_grace_days"`
+}
+
+/*
+入力された項目をドメイン情報に反映する関数
+  - domain			反映先のドメイン情報
+  - return) columns	反映したカラム名のリスト
+  - return) err		入力値が不正な場合は ErrInvalidInput を含むエラー
+*/
+func (input DomainInput) applyTo(domain *entity.DBDomain) (columns []string, err error) {
+	if input.Domain != nil {
+		domainStr := strings.TrimSpace(*input.Domain)
+		if domainStr == "" || strings.ContainsAny(domainStr, "/:?# ") {
+			return nil, fmt.Errorf("%w: domain はスキームやパスを含まないホスト名で指定してください", ErrInvalidInput)
+		}
+		domain.Domain = domainStr
+		columns = append(columns, "domain")
+	}
+	for _, paths := range []struct {
+		column string
+		input  *[]string
+		target *[]string
+	}{
+		{"start_paths", input.StartPaths, &domain.StartPaths},
+		{"allowed_paths", input.AllowedPaths, &domain.AllowedPaths},
+		{"denied_paths", input.DeniedPaths, &domain.DeniedPaths},
+	} {
+		if paths.input == nil {
+			continue
+		}
+		for _, path := range *paths.input {
+			if paths.column == "start_paths" && !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("%w: start_paths は / から始まるパスで指定してください", ErrInvalidInput)
+			}
+		}
+		*paths.target = *paths.input
+		columns = append(columns, paths.column)
+	}
+	for _, value := range []struct {
+		column string
+		input  *int
+		target *int
+	}{
+		{"max_depth", input.MaxDepth, &domain.MaxDepth},
+		{"delay_ms", input.DelayMs, &domain.DelayMs},
+		{"purge_grace_days", input.PurgeGraceDays, &domain.PurgeGraceDays},
+	} {
+		if value.input == nil {
+			continue
+		}
+		if *value.input < 0 {
+			return nil, fmt.Errorf("%w: %s は 0 以上で指定してください", ErrInvalidInput, value.column)
+		}
+		*value.target = *value.input
+		columns = append(columns, value.column)
+	}
+	if input.IsActive != nil {
+		domain.IsActive = *input.IsActive
+		columns = append(columns, "is_active")
+	}
+
+	return columns, nil
+}
+
+/*
+ドメイン情報の一覧を取得する関数
+  - return) domains	ドメイン情報のスライス
+  - return) err		エラー
+*/
+func GetDomains(ctx context.Context) (domains []entity.DBDomain, err error) {
+	domains, err = postgres.GetDomains(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return domains, nil
+}
+
+/*
+ドメインをクロール設定とともに作成する関数（未指定のクロール設定はデフォルト値、is_active は未指定なら true）
+  - ctx				コンテキスト
+  - input			作成するドメインの入力
+  - return) domain	作成したドメイン情報
+  - return) err		エラー
+*/
+func CreateDomain(ctx context.Context, input DomainInput) (domain entity.DBDomain, err error) {
+	if input.Domain == nil {
+		return domain, fmt.Errorf("%w: domain は必須です", ErrInvalidInput)
+	}
+
+	domain.IsActive = true
+	if _, err = input.applyTo(&domain); err != nil {
+		return domain, err
+	}
+
+	err = postgres.CreateDomain(ctx, &domain)
+	if errors.Is(err, postgres.ErrDomainExists) {
+		return domain, fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	if err != nil {
+		log.Error(err)
+		return domain, err
+	}
+
+	return domain, nil
+}
+
+/*
+ドメイン情報とクロール設定のうち、指定された項目を更新する関数
+  - ctx				コンテキスト
+  - domainId		ドメインID
+  - input			更新する項目の入力
+  - return) domain	更新後のドメイン情報
+  - return) err		エラー
+*/
+func UpdateDomain(ctx context.Context, domainId int64, input DomainInput) (domain entity.DBDomain, err error) {
+	domain, err = getDomain(ctx, domainId)
+	if err != nil {
+		return domain, err
+	}
+
+	columns, err := input.applyTo(&domain)
+	if err != nil {
+		return domain, err
+	}
+	if len(columns) == 0 {
+		return domain, fmt.Errorf("%w: 更新する項目が指定されていません", ErrInvalidInput)
+	}
+
+	err = postgres.UpdateDomain(ctx, &domain, columns)
+	if errors.Is(err, postgres.ErrDomainExists) {
+		return domain, fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	if err != nil {
+		log.Error(err)
+		return domain, err
+	}
+
+	return domain, nil
+}
+
+/*
+ドメインをクロール対象外にする関数（保存済みのページは検索対象のまま残す）
+  - ctx				コンテキスト
+  - domainId		ドメインID
+  - return) domain	更新後のドメイン情報
+  - return) err		エラー
+*/
+func DisableDomain(ctx context.Context, domainId int64) (domain entity.DBDomain, err error) {
+	isActive := false
+	return UpdateDomain(ctx, domainId, DomainInput{IsActive: &isActive})
+}
+
+/*
+ドメイン全体のクロールをバックグラウンドで開始する関数
+  - ctx				コンテキスト（クロールはリクエストの終了後も続くため、アプリケーションのコンテキストを渡す）
+  - domainId		ドメインID
+  - return) err		エラー（実行中の場合は ErrConflict）
+*/
+func StartCrawl(ctx context.Context, domainId int64) (err error) {
+	domain, err := getDomain(ctx, domainId)
+	if err != nil {
+		return err
+	}
+
+	err = crawler.StartCrawlDomain(ctx, domain)
+	if errors.Is(err, crawler.ErrCrawlAlreadyRunning) {
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	return err
+}
+
+/*
+ドメイン内の単一 URL のクロールをバックグラウンドで開始する関数
+  - ctx				コンテキスト（クロールはリクエストの終了後も続くため、アプリケーションのコンテキストを渡す）
+  - domainId		ドメインID
+  - targetUrl		クロールする URL
+  - return) err		エラー（実行中の場合は ErrConflict、URL が対象ドメインのものでない場合は ErrInvalidInput）
+*/
+func StartCrawlURL(ctx context.Context, domainId int64, targetUrl string) (err error) {
+	domain, err := getDomain(ctx, domainId)
+	if err != nil {
+		return err
+	}
+
+	err = crawler.StartCrawlURL(ctx, domain, targetUrl)
+	if errors.Is(err, crawler.ErrCrawlAlreadyRunning) {
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	return nil
+}
+
+/*
+実行中のクロールを中断する関数
+  - domainId		ドメインID
+  - return) err		実行中のクロールがない場合は ErrNotFound
+*/
+func CancelCrawl(domainId int64) (err error) {
+	if !crawler.CancelCrawl(domainId) {
+		return fmt.Errorf("%w: 実行中のクロールがありません", ErrNotFound)
+	}
+	return nil
+}
+
+/*
+実行中のクロールの一覧を取得する関数
+  - return)		実行中のクロール情報のスライス
+*/
+func GetRunningCrawls() []crawler.RunningCrawl {
+	return crawler.GetRunningCrawls()
+}
+
+/*
+NLP設定の一覧を取得する関数
+  - ctx				コンテキスト
+  - return) configs	NLP設定のスライス（is_active が検索に使用する設定）
+  - return) err		エラー
+*/
+func GetNlpConfigs(ctx context.Context) (configs []entity.DBNlpConfig, err error) {
+	configs, err = postgres.GetNlpConfigs(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return configs, nil
+}
+
+/*
+指定の NLP設定を検索に使用する設定にする関数（検索時はこの設定のモデルでクエリをベクトル化し、この設定のベクトルのみと比較する）
+  - ctx				コンテキスト
+  - nlpConfigId		NLP設定ID
+  - return) config	有効にした NLP設定
+  - return) err		エラー（存在しない場合は ErrNotFound）
+*/
+func ActivateNlpConfig(ctx context.Context, nlpConfigId int64) (config entity.DBNlpConfig, err error) {
+	config, err = postgres.ActivateNlpConfig(ctx, nlpConfigId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return config, fmt.Errorf("%w: NLP設定ID %d", ErrNotFound, nlpConfigId)
+	}
+	if err != nil {
+		log.Error(err)
+		return config, err
+	}
+	return config, nil
+}
+
+// ID を指定してドメイン情報を取得する関数（存在しない場合は ErrNotFound を返す）
+func getDomain(ctx context.Context, domainId int64) (domain entity.DBDomain, err error) {
+	domain, err = postgres.GetDomain(ctx, domainId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain, fmt.Errorf("%w: ドメインID %d", ErrNotFound, domainId)
+	}
+	if err != nil {
+		log.Error(err)
+		return domain, err
+	}
+
+	return domain, nil
+}
@@ -0,0 +1,41 @@
+// 各コントローラーへの処理をまとめ、動作単位にまとめた関数を定義するパッケージ
+package usecase
+
+import (
+	"app/controller/log"
+	"app/controller/nlp"
+	"app/controller/postgres"
+	"context"
+	"time"
+)
+
+// readiness チェック 1 回あたりの最大時間
+const readinessTimeout = 5 * time.Second
+
+/*
+アプリケーションがリクエストを処理できる状態か確認する関数（DB 接続、pgvector 拡張機能、NLP サーバー）
+  - ctx				コンテキスト
+  - return) checks	チェック項目ごとの結果（"ok" またはエラーメッセージ）
+  - return) isReady	すべてのチェックに成功した場合は true
+*/
+func CheckReadiness(ctx context.Context) (checks map[string]string, isReady bool) {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	checks = map[string]string{}
+	isReady = true
+	for name, check := range map[string]func(context.Context) error{
+		"database": postgres.Ping,
+		"nlp":      nlp.CheckReady,
+	} {
+		if err := check(ctx); err != nil {
+			log.Error(err)
+			checks[name] = err.Error()
+			isReady = false
+			continue
+		}
+		checks[name] = "ok"
+	}
+
+	return checks, isReady
+}
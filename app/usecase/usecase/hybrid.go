@@ -0,0 +1,125 @@
+// 各コントローラーへの処理をまとめ、動作単位にまとめた関数を定義するパッケージ
+package usecase
+
+import (
+	"app/usecase/entity"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// 検索モード（/search の mode パラメーター）
+const (
+	SearchModeVector  = "vector"  // ベクトル検索のみ（意味の近いチャンク）
+	SearchModeKeyword = "keyword" // キーワード検索のみ（クエリの文字列を含むチャンク、番号や地名など）
+	SearchModeHybrid  = "hybrid"  // ベクトル検索とキーワード検索の順位を RRF で統合
+)
+
+/*
+検索モードを検証する関数
+  - mode		検索モード（空文字の場合はベクトル検索）
+  - return)		検索モード
+  - return) err	エラー（不明な検索モード）
+*/
+func ParseSearchMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return SearchModeVector, nil
+	case SearchModeVector, SearchModeKeyword, SearchModeHybrid:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("mode は %s, %s, %s のいずれかで指定してください: %s", SearchModeHybrid, SearchModeVector, SearchModeKeyword, mode)
+	}
+}
+
+// ハイブリッド検索の RRF（Reciprocal Rank Fusion）のパラメーター
+type hybridParams struct {
+	VectorWeight  float64 // ベクトル検索の順位の重み
+	KeywordWeight float64 // キーワード検索の順位の重み
+	RrfK          float64 // 順位に加える定数（大きいほど上位と下位のスコア差が小さくなる）
+}
+
+// ハイブリッド検索で、返却する件数に対して各検索から取得する候補の倍率
+const hybridCandidateFactor = 3
+
+/*
+環境変数からハイブリッド検索のパラメーターを取得する関数
+  - return)	HYBRID_VECTOR_WEIGHT, HYBRID_KEYWORD_WEIGHT, HYBRID_RRF_K の値（未指定・無効な値の場合はデフォルト値）
+*/
+func getHybridParams() hybridParams {
+	return hybridParams{
+		VectorWeight:  getEnvFloat("HYBRID_VECTOR_WEIGHT", 1),
+		KeywordWeight: getEnvFloat("HYBRID_KEYWORD_WEIGHT", 1),
+		RrfK:          getEnvFloat("HYBRID_RRF_K", 60),
+	}
+}
+
+// 環境変数を 0 以上の小数として取得する関数（未指定・無効な値の場合はデフォルト値）
+func getEnvFloat(name string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+/*
+ベクトル検索とキーワード検索の結果（それぞれスコア順）を RRF で 1 つの順位に統合する関数
+各チャンクのスコアは、各検索での順位 rank（1 始まり）について weight / (k + rank) の合計とする
+  - vectorChunks	ベクトル検索の結果（類似度順）
+  - keywordChunks	キーワード検索の結果（類似度順）
+  - params			RRF のパラメーター
+  - resultLimit		返却する件数
+  - return) chunks	統合したスコア順のチャンク
+  - return) scores	統合したスコア
+*/
+func fuseRankings(vectorChunks []entity.DBChunk, keywordChunks []entity.DBChunk, params hybridParams, resultLimit int) (chunks []entity.DBChunk, scores []float32) {
+	type fusedChunk struct {
+		chunk    entity.DBChunk
+		score    float64
+		bestRank int
+	}
+	fused := make(map[int64]*fusedChunk)
+	var order []int64
+
+	addRanking := func(rankedChunks []entity.DBChunk, weight float64) {
+		for i, chunk := range rankedChunks {
+			rank := i + 1
+			f, exists := fused[chunk.ID]
+			if !exists {
+				f = &fusedChunk{chunk: chunk, bestRank: rank}
+				fused[chunk.ID] = f
+				order = append(order, chunk.ID)
+			}
+			f.score += weight / (params.RrfK + float64(rank))
+			f.bestRank = min(f.bestRank, rank)
+		}
+	}
+	addRanking(vectorChunks, params.VectorWeight)
+	addRanking(keywordChunks, params.KeywordWeight)
+
+	// スコア順、同点の場合は各検索での最高順位、チャンクID の順に並べる（結果の順番を一定にする）
+	sort.Slice(order, func(a, b int) bool {
+		fa, fb := fused[order[a]], fused[order[b]]
+		if fa.score != fb.score {
+			return fa.score > fb.score
+		}
+		if fa.bestRank != fb.bestRank {
+			return fa.bestRank < fb.bestRank
+		}
+		return order[a] < order[b]
+	})
+
+	if len(order) > resultLimit {
+		order = order[:resultLimit]
+	}
+	chunks = make([]entity.DBChunk, 0, len(order))
+	scores = make([]float32, 0, len(order))
+	for _, id := range order {
+		chunks = append(chunks, fused[id].chunk)
+		scores = append(scores, float32(fused[id].score))
+	}
+
+	return chunks, scores
+}
@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"app/usecase/entity"
+	"reflect"
+	"testing"
+)
+
+// 単体テスト（外部依存がない関数のテスト）を定義
+// `docker compose exec app go test ./usecase/usecase`
+
+// ID のみを設定したチャンクの配列を作成する
+func chunksWithIds(ids ...int64) []entity.DBChunk {
+	chunks := make([]entity.DBChunk, 0, len(ids))
+	for _, id := range ids {
+		chunks = append(chunks, entity.DBChunk{ID: id})
+	}
+	return chunks
+}
+
+func TestFuseRankings(t *testing.T) {
+	testCases := []struct {
+		name          string
+		vectorIds     []int64
+		keywordIds    []int64
+		params        hybridParams
+		resultLimit   int
+		expectedIds   []int64
+		expectedFirst float32 // 先頭のチャンクのスコア
+	}{
+		{
+			name:          "両方の検索で上位のチャンクが先頭になる",
+			vectorIds:     []int64{1, 2, 3},
+			keywordIds:    []int64{3, 4},
+			params:        hybridParams{VectorWeight: 1, KeywordWeight: 1, RrfK: 60},
+			resultLimit:   10,
+			expectedIds:   []int64{3, 1, 2, 4},
+			expectedFirst: float32(1.0/63 + 1.0/61),
+		},
+		{
+			name:          "同点の場合は最高順位、チャンクIDの順",
+			vectorIds:     []int64{5, 6},
+			keywordIds:    []int64{6, 5},
+			params:        hybridParams{VectorWeight: 1, KeywordWeight: 1, RrfK: 60},
+			resultLimit:   10,
+			expectedIds:   []int64{5, 6},
+			expectedFirst: float32(1.0/61 + 1.0/62),
+		},
+		{
+			name:          "キーワード検索の重みを大きくする",
+			vectorIds:     []int64{1, 2},
+			keywordIds:    []int64{2, 3},
+			params:        hybridParams{VectorWeight: 1, KeywordWeight: 3, RrfK: 60},
+			resultLimit:   10,
+			expectedIds:   []int64{2, 3, 1},
+			expectedFirst: float32(1.0/62 + 3.0/61),
+		},
+		{
+			name:          "返却する件数に絞る",
+			vectorIds:     []int64{1, 2, 3},
+			keywordIds:    []int64{},
+			params:        hybridParams{VectorWeight: 1, KeywordWeight: 1, RrfK: 60},
+			resultLimit:   2,
+			expectedIds:   []int64{1, 2},
+			expectedFirst: float32(1.0 / 61),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks, scores := fuseRankings(chunksWithIds(tc.vectorIds...), chunksWithIds(tc.keywordIds...), tc.params, tc.resultLimit)
+
+			ids := make([]int64, 0, len(chunks))
+			for _, chunk := range chunks {
+				ids = append(ids, chunk.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.expectedIds) {
+				t.Errorf("期待されるチャンクID '%v' ですが、実際は '%v' でした", tc.expectedIds, ids)
+			}
+			if len(scores) != len(chunks) {
+				t.Fatalf("期待されるスコア数 '%d' ですが、実際は '%d' でした", len(chunks), len(scores))
+			}
+			if scores[0] != tc.expectedFirst {
+				t.Errorf("期待される先頭のスコア '%v' ですが、実際は '%v' でした", tc.expectedFirst, scores[0])
+			}
+		})
+	}
+}
+
+func TestParseSearchMode(t *testing.T) {
+	testCases := []struct {
+		mode        string
+		expected    string
+		expectedErr bool
+	}{
+		{"", SearchModeVector, false},
+		{"vector", SearchModeVector, false},
+		{"keyword", SearchModeKeyword, false},
+		{"hybrid", SearchModeHybrid, false},
+		{"fulltext", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			mode, err := ParseSearchMode(tc.mode)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("期待されるエラーの有無 '%v' ですが、実際のエラーは '%v' でした", tc.expectedErr, err)
+			}
+			if mode != tc.expected {
+				t.Errorf("期待される検索モード '%s' ですが、実際は '%s' でした", tc.expected, mode)
+			}
+		})
+	}
+}
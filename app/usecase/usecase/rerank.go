@@ -0,0 +1,75 @@
+// 各コントローラーへの処理をまとめ、動作単位にまとめた関数を定義するパッケージ
+package usecase
+
+import (
+	"app/controller/log"
+	"app/controller/nlp"
+	"app/usecase/entity"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+/*
+候補のチャンクをクロスエンコーダーでクエリとの関連度を計算し、関連度順に並べ替える関数
+  - ctx				コンテキスト
+  - query			検索クエリ
+  - candidates		候補のチャンク（1 段目の検索のスコア順、nlp.MaxRerankPassages 件以下）
+  - return) chunks	関連度順のチャンク
+  - return) scores	クロスエンコーダーの関連度
+  - return) err		エラー（nlp サーバーにリランカーが設定されていない場合は ErrInvalidInput を含むエラー）
+*/
+func rerankChunks(ctx context.Context, query string, candidates []entity.DBChunk) (chunks []entity.DBChunk, scores []float32, err error) {
+	if len(candidates) == 0 {
+		return []entity.DBChunk{}, []float32{}, nil
+	}
+
+	passages := make([]string, 0, len(candidates))
+	for _, chunk := range candidates {
+		passages = append(passages, chunk.Chunk)
+	}
+	rerankScores, err := nlp.Rerank(ctx, query, passages)
+	if errors.Is(err, nlp.ErrRerankUnavailable) {
+		// リランカーがない nlp サーバーでは rerank=true を指定できない（リクエストの誤りとして扱う）
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, nil, err
+	}
+
+	chunks, scores = sortByScores(candidates, rerankScores, len(candidates))
+	return chunks, scores, nil
+}
+
+/*
+チャンクをスコア順に並べ替えて返却する件数に絞る関数
+同点の場合は元の順位（1 段目の検索のスコア順）を保つ
+  - candidates		チャンク
+  - candidateScores	チャンクごとのスコア（candidates と同じ順番）
+  - resultLimit		返却する件数
+  - return) chunks	スコア順のチャンク
+  - return) scores	スコア
+*/
+func sortByScores(candidates []entity.DBChunk, candidateScores []float32, resultLimit int) (chunks []entity.DBChunk, scores []float32) {
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return candidateScores[order[a]] > candidateScores[order[b]]
+	})
+
+	if len(order) > resultLimit {
+		order = order[:resultLimit]
+	}
+	chunks = make([]entity.DBChunk, 0, len(order))
+	scores = make([]float32, 0, len(order))
+	for _, i := range order {
+		chunks = append(chunks, candidates[i])
+		scores = append(scores, candidateScores[i])
+	}
+
+	return chunks, scores
+}
@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"app/controller/nlp"
+	"app/domain/model"
+	"app/usecase/entity"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// 単体テスト（外部依存がない関数のテスト）を定義
+// `docker compose exec app go test ./usecase/usecase`
+
+func TestSortByScores(t *testing.T) {
+	testCases := []struct {
+		name           string
+		ids            []int64
+		scores         []float32
+		resultLimit    int
+		expectedIds    []int64
+		expectedScores []float32
+	}{
+		{
+			name:           "スコア順に並べ替える",
+			ids:            []int64{1, 2, 3},
+			scores:         []float32{0.2, 0.9, 0.5},
+			resultLimit:    10,
+			expectedIds:    []int64{2, 3, 1},
+			expectedScores: []float32{0.9, 0.5, 0.2},
+		},
+		{
+			name:           "同点の場合は元の順位を保つ",
+			ids:            []int64{4, 3, 2},
+			scores:         []float32{0.5, 0.5, 0.7},
+			resultLimit:    10,
+			expectedIds:    []int64{2, 4, 3},
+			expectedScores: []float32{0.7, 0.5, 0.5},
+		},
+		{
+			name:           "返却する件数に絞る",
+			ids:            []int64{1, 2, 3, 4},
+			scores:         []float32{0.1, 0.4, 0.3, 0.2},
+			resultLimit:    2,
+			expectedIds:    []int64{2, 3},
+			expectedScores: []float32{0.4, 0.3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks, scores := sortByScores(chunksWithIds(tc.ids...), tc.scores, tc.resultLimit)
+
+			ids := make([]int64, 0, len(chunks))
+			for _, chunk := range chunks {
+				ids = append(ids, chunk.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.expectedIds) {
+				t.Errorf("期待されるチャンクID '%v' ですが、実際は '%v' でした", tc.expectedIds, ids)
+			}
+			if !reflect.DeepEqual(scores, tc.expectedScores) {
+				t.Errorf("期待されるスコア '%v' ですが、実際は '%v' でした", tc.expectedScores, scores)
+			}
+		})
+	}
+}
+
+func TestRerankChunksUnavailable(t *testing.T) {
+	// リランカーが設定されていない nlp サーバーは /rerank に 503 を返す
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "リランカーが設定されていません（RERANKER_MODEL_PATH 環境変数）", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	serverUrl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NLP_HOST", serverUrl.Hostname())
+	t.Setenv("NLP_PORT", serverUrl.Port())
+
+	_, _, err = rerankChunks(context.Background(), "住民票", []entity.DBChunk{{ChunkInfo: model.ChunkInfo{Chunk: "住民票の写しの交付"}}})
+	if !errors.Is(err, ErrInvalidInput) || !errors.Is(err, nlp.ErrRerankUnavailable) {
+		t.Errorf("期待されるエラーは ErrInvalidInput と nlp.ErrRerankUnavailable を含むエラーですが、実際は '%v' でした", err)
+	}
+}
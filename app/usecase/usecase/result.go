@@ -0,0 +1,361 @@
+// 各コントローラーへの処理をまとめ、動作単位にまとめた関数を定義するパッケージ
+package usecase
+
+import (
+	"app/controller/keyword"
+	"app/controller/log"
+	"app/domain/model"
+	"app/usecase/entity"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 検索結果のページごとの情報
+type SearchResult struct {
+	PageWithDomain
+	Score   float32    `json:"score"`   // ページのスコア（集計方法によって、最もスコアが高いチャンクのスコアか上位のチャンクのスコアの合計）
+	Snippet string     `json:"snippet"` // 最もスコアが高いチャンクの、クエリの語をハイライトした抜粋（HTML エスケープ済み、語は <mark> タグで囲む）
+	Chunks  []ChunkHit `json:"chunks"`  // 一致したチャンク（スコア順）
+}
+
+// 検索結果で一致したチャンクの情報
+type ChunkHit struct {
+	Text     string  `json:"text"`
+	Position int     `json:"position"` // ページ内のチャンクの順番（0 始まり）
+	Score    float32 `json:"score"`
+}
+
+// 検索 API のレスポンス
+type SearchResponse struct {
+	Results    []SearchResult `json:"results"`
+	Facets     SearchFacets   `json:"facets"`
+	NextCursor string         `json:"next_cursor,omitempty"` // 次のページの検索結果を取得するカーソル（続きがない場合は省略）
+}
+
+// 検索結果の絞り込み用の件数（検索結果の候補のページの件数、各次元の件数はその次元の絞り込み条件を外して数える）
+type SearchFacets struct {
+	Domains  []FacetCount `json:"domains"`  // ドメインごとのページ数
+	Sections []FacetCount `json:"sections"` // パスの最初の階層（"/kurashi/" など）ごとのページ数
+}
+
+// 絞り込み用の値ごとの件数
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// スニペットの最大文字数
+const snippetLength = 120
+
+// 検索結果のページのスコアの集計方法（/search の aggregation パラメーター）
+const (
+	AggregationMax = "max" // ページ内で最もスコアが高いチャンクのスコア
+	AggregationSum = "sum" // ページ内でスコアが上位 n 件のチャンクのスコアの合計（複数の箇所が一致するページを上位にする）
+)
+
+// ページごとにまとめるため、返却する件数に対して取得するチャンク数の倍率（異なるページが足りない場合は倍にして検索し直す）
+const collapseCandidateFactor = 5
+
+// ページごとにまとめるために取得するチャンク数の上限
+const maxCollapseCandidates = 1000
+
+/*
+スコアの集計方法を検証する関数
+  - aggregation		集計方法（空文字の場合は max）
+  - return)			集計方法
+  - return) err		エラー（不明な集計方法）
+*/
+func ParseAggregation(aggregation string) (string, error) {
+	switch aggregation {
+	case "":
+		return AggregationMax, nil
+	case AggregationMax, AggregationSum:
+		return aggregation, nil
+	default:
+		return "", fmt.Errorf("aggregation は %s, %s のいずれかで指定してください: %s", AggregationMax, AggregationSum, aggregation)
+	}
+}
+
+/*
+環境変数から、集計方法が sum の場合に合計するチャンク数を取得する関数
+  - return)	SEARCH_SUM_TOP_N の値（未指定・無効な値の場合は 3）
+*/
+func getSumTopN() int {
+	value, err := strconv.Atoi(os.Getenv("SEARCH_SUM_TOP_N"))
+	if err != nil || value < 1 {
+		return 3
+	}
+	return value
+}
+
+/*
+スコア順のチャンクをページごとにまとめ、ページのスコア順に並べる関数
+  - chunks			スコア順のチャンク（ページ・ドメイン情報を含む）
+  - scores			チャンクごとのスコア（chunks と同じ順番）
+  - aggregation		ページのスコアの集計方法（AggregationMax, AggregationSum）
+  - sumTopN			集計方法が sum の場合に合計するチャンク数
+  - return) results	ページのスコア順の検索結果（同点の場合は最もスコアが高いチャンクの順番、Snippet は空）
+*/
+func collapseChunks(chunks []entity.DBChunk, scores []float32, aggregation string, sumTopN int) (results []SearchResult) {
+	results = groupChunksByPage(chunks, scores)
+
+	if aggregation == AggregationSum {
+		for i := range results {
+			var sum float32
+			for _, hit := range results[i].Chunks[:min(sumTopN, len(results[i].Chunks))] {
+				sum += hit.Score
+			}
+			results[i].Score = sum
+		}
+		// groupChunksByPage の結果は最もスコアが高いチャンクの順番のため、安定ソートで同点の順番を保つ
+		sort.SliceStable(results, func(a, b int) bool {
+			return results[a].Score > results[b].Score
+		})
+	}
+
+	return results
+}
+
+/*
+スコアが下限未満のチャンクを除外する関数
+  - candidates		チャンク
+  - candidateScores	チャンクごとのスコア（candidates と同じ順番）
+  - minScore		スコアの下限
+  - return) chunks	スコアが下限以上のチャンク（順番は変えない）
+  - return) scores	スコア
+*/
+func filterByMinScore(candidates []entity.DBChunk, candidateScores []float32, minScore float32) (chunks []entity.DBChunk, scores []float32) {
+	chunks = make([]entity.DBChunk, 0, len(candidates))
+	scores = make([]float32, 0, len(candidates))
+	for i, chunk := range candidates {
+		if candidateScores[i] >= minScore {
+			chunks = append(chunks, chunk)
+			scores = append(scores, candidateScores[i])
+		}
+	}
+	return chunks, scores
+}
+
+/*
+スコア順のチャンクをページごとにまとめる関数（ページの順番は最もスコアが高いチャンクの順番）
+  - chunks			スコア順のチャンク（ページ・ドメイン情報を含む）
+  - scores			チャンクごとのスコア（chunks と同じ順番）
+  - return) results	ページごとの検索結果（Snippet は空）
+*/
+func groupChunksByPage(chunks []entity.DBChunk, scores []float32) (results []SearchResult) {
+	results = []SearchResult{}
+	pageIndexes := make(map[int64]int)
+	for i, chunk := range chunks {
+		hit := ChunkHit{
+			Text:     chunk.Chunk,
+			Position: chunk.Position,
+			Score:    scores[i],
+		}
+		if index, exists := pageIndexes[chunk.PageID]; exists {
+			results[index].Chunks = append(results[index].Chunks, hit)
+			continue
+		}
+		pageIndexes[chunk.PageID] = len(results)
+		results = append(results, SearchResult{
+			PageWithDomain: newPageWithDomain(chunk.Page),
+			Score:          scores[i],
+			Chunks:         []ChunkHit{hit},
+		})
+	}
+	return results
+}
+
+/*
+ページ情報を検索結果用のページ情報に変換する関数
+  - page		ページ情報（ドメイン情報を含む）
+  - return)		検索結果用のページ情報
+*/
+func newPageWithDomain(page *entity.DBPage) PageWithDomain {
+	if page == nil {
+		return PageWithDomain{}
+	}
+	domainStr := ""
+	if page.Domain != nil {
+		domainStr = page.Domain.Domain
+	}
+	return PageWithDomain{
+		Domain:      domainStr,
+		Path:        page.Path,
+		Title:       page.Title,
+		Description: page.Description,
+		Keywords:    page.Keywords,
+		Markdown:    page.Markdown,
+		ContentType: page.ContentType,
+	}
+}
+
+/*
+検索結果に最もスコアが高いチャンクのスニペットを設定し、必要がなければ Markdown を除外する関数
+  - results			ページごとの検索結果（書き換える）
+  - queryTerms		検索クエリを形態素解析した語
+  - includeMarkdown	ページ全体の Markdown を含めるかどうか
+  - return) err		エラー
+*/
+func completeSearchResults(results []SearchResult, queryTerms []string, includeMarkdown bool) (err error) {
+	for i := range results {
+		results[i].Snippet, err = keyword.Snippet(results[i].Chunks[0].Text, queryTerms, snippetLength)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		if !includeMarkdown {
+			results[i].Markdown = ""
+		}
+	}
+	return nil
+}
+
+/*
+ページのスコア順の検索結果から、指定の範囲の検索結果と次のページのカーソルをまとめる関数
+候補のチャンク数の上限に達した場合は、取得できたページのみ返し、次のページのカーソルは返さない
+  - pages				ページのスコア順の検索結果（取得した候補のページ全体）
+  - offset				先頭から飛ばすページ数
+  - limit				返却するページ数
+  - capped				候補のチャンク数の上限に達したかどうか
+  - return) response	検索 API のレスポンス（Snippet、Facets は空）
+  - return) err			上限に達して offset より後のページを取得できなかった場合は ErrInvalidInput を含むエラー
+*/
+func paginateResults(pages []SearchResult, offset int, limit int, capped bool) (response SearchResponse, err error) {
+	if capped && offset > 0 && offset >= len(pages) {
+		return response, fmt.Errorf("%w: offset %d は候補のチャンク数の上限までに取得できたページ数 %d を超えています", ErrInvalidInput, offset, len(pages))
+	}
+
+	start := min(offset, len(pages))
+	end := min(offset+limit, len(pages))
+	response.Results = pages[start:end]
+	if len(pages) > end && !capped {
+		response.NextCursor = encodeCursor(end)
+	}
+	return response, nil
+}
+
+/*
+検索結果の候補のページを、ドメインとパスの最初の階層ごとに数える関数
+ドメインの件数はドメインの絞り込み条件を、階層の件数はパスの前方一致の絞り込み条件を外した候補から数える（ほかの絞り込み条件は適用する）
+  - pages				すべての絞り込み条件を適用した候補のページ
+  - filter				絞り込み条件
+  - searchPages			絞り込み条件を変えて同じ件数の候補のページを取得する関数
+  - return) facets		絞り込み用の件数
+  - return) err			エラー
+*/
+func countSearchFacets(pages []SearchResult, filter model.SearchFilter, searchPages func(filter model.SearchFilter) ([]SearchResult, error)) (facets SearchFacets, err error) {
+	domainPages, sectionPages := pages, pages
+	if filter.Domain != "" {
+		domainFilter := filter
+		domainFilter.Domain = ""
+		domainPages, err = searchPages(domainFilter)
+		if err != nil {
+			log.Error(err)
+			return SearchFacets{}, err
+		}
+	}
+	if filter.PathPrefix != "" {
+		sectionFilter := filter
+		sectionFilter.PathPrefix = ""
+		sectionPages, err = searchPages(sectionFilter)
+		if err != nil {
+			log.Error(err)
+			return SearchFacets{}, err
+		}
+	}
+
+	domainCounts := countPagesBy(domainPages, func(page SearchResult) string { return page.Domain })
+	sectionCounts := countPagesBy(sectionPages, func(page SearchResult) string { return topLevelSection(page.Path) })
+	return newSearchFacets(domainCounts, sectionCounts), nil
+}
+
+/*
+ドメインとパスの最初の階層ごとのページ数から、絞り込み用の件数をまとめる関数
+  - domainCounts	ドメインごとのページ数
+  - sectionCounts	パスの最初の階層ごとのページ数
+  - return)			絞り込み用の件数（件数の多い順、同数の場合は値の順）
+*/
+func newSearchFacets(domainCounts map[string]int, sectionCounts map[string]int) SearchFacets {
+	return SearchFacets{
+		Domains:  sortFacetCounts(domainCounts),
+		Sections: sortFacetCounts(sectionCounts),
+	}
+}
+
+/*
+ページを値ごとに数える関数
+  - pages		ページごとの検索結果
+  - valueOf		ページから数える値を求める関数
+  - return)		値ごとのページ数
+*/
+func countPagesBy(pages []SearchResult, valueOf func(page SearchResult) string) map[string]int {
+	counts := make(map[string]int)
+	for _, page := range pages {
+		counts[valueOf(page)]++
+	}
+	return counts
+}
+
+/*
+パスの最初の階層を取得する関数（path_prefix の絞り込みにそのまま使用できる形式）
+  - path		パス（"/kurashi/gomi/index.html" など）
+  - return)		最初の階層（"/kurashi/"、最初の階層のファイルの場合は "/"）
+*/
+func topLevelSection(path string) string {
+	trimmed := strings.TrimPrefix(path, "/")
+	index := strings.Index(trimmed, "/")
+	if index < 0 {
+		return "/"
+	}
+	return "/" + trimmed[:index+1]
+}
+
+// 値ごとの件数を件数の多い順（同数の場合は値の順）に並べる関数
+func sortFacetCounts(counts map[string]int) []FacetCount {
+	facetCounts := make([]FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facetCounts = append(facetCounts, FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facetCounts, func(a, b int) bool {
+		if facetCounts[a].Count != facetCounts[b].Count {
+			return facetCounts[a].Count > facetCounts[b].Count
+		}
+		return facetCounts[a].Value < facetCounts[b].Value
+	})
+	return facetCounts
+}
+
+// 検索結果のカーソルの内容（クライアントからは中身を意識しない文字列として扱う）
+type searchCursor struct {
+	Offset int `json:"offset"`
+}
+
+// 先頭から飛ばすページ数をカーソルの文字列に変換する関数
+func encodeCursor(offset int) string {
+	cursorJson, _ := json.Marshal(searchCursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(cursorJson)
+}
+
+/*
+カーソルの文字列を先頭から飛ばすページ数に変換する関数
+  - cursor		検索 API のレスポンスの next_cursor
+  - return)		先頭から飛ばすページ数
+  - return) err	エラー（無効なカーソル）
+*/
+func ParseCursor(cursor string) (offset int, err error) {
+	cursorJson, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("cursor が無効です: %s", cursor)
+	}
+	var decoded searchCursor
+	err = json.Unmarshal(cursorJson, &decoded)
+	if err != nil || decoded.Offset < 0 {
+		return 0, fmt.Errorf("cursor が無効です: %s", cursor)
+	}
+	return decoded.Offset, nil
+}
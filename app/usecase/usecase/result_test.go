@@ -0,0 +1,341 @@
+package usecase
+
+import (
+	"app/domain/model"
+	"app/usecase/entity"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 単体テスト（外部依存がない関数のテスト）を定義
+// `docker compose exec app go test ./usecase/usecase`
+
+// ページID・位置・テキストを設定したチャンクを作成する（ページのパスは "/ページID"）
+func chunkOnPage(id int64, pageId int64, position int) entity.DBChunk {
+	chunk := entity.DBChunk{ID: id, Page: &entity.DBPage{ID: pageId}}
+	chunk.Page.Path = fmt.Sprintf("/%d", pageId)
+	chunk.PageID = pageId
+	chunk.Position = position
+	chunk.Chunk = "chunk"
+	return chunk
+}
+
+func TestGroupChunksByPage(t *testing.T) {
+	chunks := []entity.DBChunk{
+		chunkOnPage(1, 10, 2),
+		chunkOnPage(2, 20, 0),
+		chunkOnPage(3, 10, 0),
+		chunkOnPage(4, 30, 5),
+	}
+	scores := []float32{0.9, 0.8, 0.7, 0.6}
+
+	results := groupChunksByPage(chunks, scores)
+
+	expectedScores := []float32{0.9, 0.8, 0.6}
+	actualScores := make([]float32, 0, len(results))
+	for _, result := range results {
+		actualScores = append(actualScores, result.Score)
+	}
+	if !reflect.DeepEqual(actualScores, expectedScores) {
+		t.Fatalf("期待されるページのスコア '%v' ですが、実際は '%v' でした", expectedScores, actualScores)
+	}
+
+	// 同じページのチャンクはスコア順にまとめる
+	expectedHits := []ChunkHit{
+		{Text: "chunk", Position: 2, Score: 0.9},
+		{Text: "chunk", Position: 0, Score: 0.7},
+	}
+	if !reflect.DeepEqual(results[0].Chunks, expectedHits) {
+		t.Errorf("期待されるチャンク '%v' ですが、実際は '%v' でした", expectedHits, results[0].Chunks)
+	}
+	if len(results[1].Chunks) != 1 || len(results[2].Chunks) != 1 {
+		t.Errorf("期待されるチャンク数 '1' ですが、実際は '%d', '%d' でした", len(results[1].Chunks), len(results[2].Chunks))
+	}
+}
+
+func TestCollapseChunks(t *testing.T) {
+	// ページ 10 は 2 位と 3 位、ページ 20 は 1 位、ページ 30 は 4 位、ページ 40 は 5 位のチャンク
+	chunks := []entity.DBChunk{
+		chunkOnPage(1, 20, 0),
+		chunkOnPage(2, 10, 0),
+		chunkOnPage(3, 10, 1),
+		chunkOnPage(4, 30, 0),
+		chunkOnPage(5, 40, 0),
+	}
+	scores := []float32{0.9, 0.8, 0.7, 0.5, 0.5}
+
+	testCases := []struct {
+		name           string
+		aggregation    string
+		sumTopN        int
+		expectedPaths  []string
+		expectedScores []float32
+	}{
+		{
+			name:           "max は最もスコアが高いチャンクの順番",
+			aggregation:    AggregationMax,
+			sumTopN:        3,
+			expectedPaths:  []string{"/20", "/10", "/30", "/40"},
+			expectedScores: []float32{0.9, 0.8, 0.5, 0.5},
+		},
+		{
+			name:           "sum は上位のチャンクのスコアの合計順",
+			aggregation:    AggregationSum,
+			sumTopN:        3,
+			expectedPaths:  []string{"/10", "/20", "/30", "/40"},
+			expectedScores: []float32{0.8 + 0.7, 0.9, 0.5, 0.5},
+		},
+		{
+			name:           "sum で合計するチャンク数を制限する",
+			aggregation:    AggregationSum,
+			sumTopN:        1,
+			expectedPaths:  []string{"/20", "/10", "/30", "/40"},
+			expectedScores: []float32{0.9, 0.8, 0.5, 0.5},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := collapseChunks(chunks, scores, tc.aggregation, tc.sumTopN)
+
+			paths := make([]string, 0, len(results))
+			resultScores := make([]float32, 0, len(results))
+			for _, result := range results {
+				paths = append(paths, result.Path)
+				resultScores = append(resultScores, result.Score)
+			}
+			if !reflect.DeepEqual(paths, tc.expectedPaths) {
+				t.Errorf("期待されるページのパス '%v' ですが、実際は '%v' でした", tc.expectedPaths, paths)
+			}
+			if !reflect.DeepEqual(resultScores, tc.expectedScores) {
+				t.Errorf("期待されるスコア '%v' ですが、実際は '%v' でした", tc.expectedScores, resultScores)
+			}
+		})
+	}
+}
+
+func TestParseAggregation(t *testing.T) {
+	testCases := []struct {
+		aggregation string
+		expected    string
+		expectedErr bool
+	}{
+		{"", AggregationMax, false},
+		{"max", AggregationMax, false},
+		{"sum", AggregationSum, false},
+		{"avg", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.aggregation, func(t *testing.T) {
+			aggregation, err := ParseAggregation(tc.aggregation)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("期待されるエラーの有無 '%v' ですが、実際のエラーは '%v' でした", tc.expectedErr, err)
+			}
+			if aggregation != tc.expected {
+				t.Errorf("期待される集計方法 '%s' ですが、実際は '%s' でした", tc.expected, aggregation)
+			}
+		})
+	}
+}
+
+// パスのみを設定した検索結果を作成する
+func resultsWithPaths(domain string, paths ...string) []SearchResult {
+	results := make([]SearchResult, 0, len(paths))
+	for _, path := range paths {
+		results = append(results, SearchResult{PageWithDomain: PageWithDomain{Domain: domain, Path: path}})
+	}
+	return results
+}
+
+func TestPaginateResults(t *testing.T) {
+	pages := append(resultsWithPaths("a.jp", "/kurashi/1.html", "/kurashi/2.html", "/index.html"), resultsWithPaths("b.jp", "/kenko/1.html")...)
+
+	testCases := []struct {
+		name          string
+		offset        int
+		limit         int
+		capped        bool
+		expectedPaths []string
+		hasNext       bool
+		expectedErr   bool
+	}{
+		{"先頭のページ", 0, 2, false, []string{"/kurashi/1.html", "/kurashi/2.html"}, true, false},
+		{"最後のページ", 2, 2, false, []string{"/index.html", "/kenko/1.html"}, false, false},
+		{"範囲外", 10, 2, false, []string{}, false, false},
+		{"上限に達して limit より少ないページ", 2, 5, true, []string{"/index.html", "/kenko/1.html"}, false, false},
+		{"上限に達して次のページがあってもカーソルを返さない", 0, 2, true, []string{"/kurashi/1.html", "/kurashi/2.html"}, false, false},
+		{"上限に達して offset が取得できたページ数以上", 4, 2, true, nil, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := paginateResults(pages, tc.offset, tc.limit, tc.capped)
+			if tc.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("期待されるエラーは ErrInvalidInput を含むエラーですが、実際は '%v' でした", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("期待されるエラーは nil ですが、実際は '%v' でした", err)
+			}
+
+			paths := make([]string, 0, len(response.Results))
+			for _, result := range response.Results {
+				paths = append(paths, result.Path)
+			}
+			if !reflect.DeepEqual(paths, tc.expectedPaths) {
+				t.Errorf("期待されるページのパス '%v' ですが、実際は '%v' でした", tc.expectedPaths, paths)
+			}
+			if (response.NextCursor != "") != tc.hasNext {
+				t.Fatalf("期待される次のページの有無 '%v' ですが、実際のカーソルは '%s' でした", tc.hasNext, response.NextCursor)
+			}
+			if tc.hasNext {
+				offset, err := ParseCursor(response.NextCursor)
+				if err != nil || offset != tc.offset+tc.limit {
+					t.Errorf("期待される次のページの offset '%d' ですが、実際は '%d' でした（エラー: %v）", tc.offset+tc.limit, offset, err)
+				}
+			}
+
+		})
+	}
+}
+
+func TestNewSearchFacets(t *testing.T) {
+	testCases := []struct {
+		name          string
+		domainCounts  map[string]int
+		sectionCounts map[string]int
+		expected      SearchFacets
+	}{
+		{
+			"件数の多い順、同数の場合は値の順",
+			map[string]int{"b.jp": 1, "a.jp": 3},
+			map[string]int{"/kenko/": 1, "/kurashi/": 2, "/": 1},
+			SearchFacets{
+				Domains:  []FacetCount{{Value: "a.jp", Count: 3}, {Value: "b.jp", Count: 1}},
+				Sections: []FacetCount{{Value: "/kurashi/", Count: 2}, {Value: "/", Count: 1}, {Value: "/kenko/", Count: 1}},
+			},
+		},
+		{
+			"一致するページがない場合は空の配列",
+			nil,
+			nil,
+			SearchFacets{Domains: []FacetCount{}, Sections: []FacetCount{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := newSearchFacets(tc.domainCounts, tc.sectionCounts)
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("期待される絞り込み用の件数 '%v' ですが、実際は '%v' でした", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	offset, err := ParseCursor(encodeCursor(40))
+	if err != nil || offset != 40 {
+		t.Errorf("期待される offset '40' ですが、実際は '%d' でした（エラー: %v）", offset, err)
+	}
+	for _, cursor := range []string{"invalid!", encodeCursor(-1)} {
+		if _, err := ParseCursor(cursor); err == nil {
+			t.Errorf("無効なカーソル '%s' でエラーになりませんでした", cursor)
+		}
+	}
+}
+
+func TestFilterByMinScore(t *testing.T) {
+	chunks, scores := filterByMinScore(chunksWithIds(1, 2, 3), []float32{0.9, 0.2, 0.5}, 0.5)
+
+	ids := make([]int64, 0, len(chunks))
+	for _, chunk := range chunks {
+		ids = append(ids, chunk.ID)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 3}) || !reflect.DeepEqual(scores, []float32{0.9, 0.5}) {
+		t.Errorf("期待されるチャンクID '[1 3]', スコア '[0.9 0.5]' ですが、実際は '%v', '%v' でした", ids, scores)
+	}
+}
+
+func TestCountSearchFacets(t *testing.T) {
+	pages := resultsWithPaths("a.jp", "/kurashi/1.html", "/kurashi/2.html")
+	unfilteredPages := append(resultsWithPaths("a.jp", "/kurashi/1.html", "/kurashi/2.html", "/index.html"), resultsWithPaths("b.jp", "/kenko/1.html")...)
+
+	testCases := []struct {
+		name             string
+		filter           model.SearchFilter
+		expectedFilters  []model.SearchFilter
+		expectedDomains  []FacetCount
+		expectedSections []FacetCount
+	}{
+		{
+			"絞り込み条件がない場合は候補のページを数える",
+			model.SearchFilter{},
+			nil,
+			[]FacetCount{{Value: "a.jp", Count: 2}},
+			[]FacetCount{{Value: "/kurashi/", Count: 2}},
+		},
+		{
+			"ドメインの件数はドメインの絞り込み条件を外して数える",
+			model.SearchFilter{Domain: "a.jp", UpdatedAfter: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			[]model.SearchFilter{{UpdatedAfter: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			[]FacetCount{{Value: "a.jp", Count: 3}, {Value: "b.jp", Count: 1}},
+			[]FacetCount{{Value: "/kurashi/", Count: 2}},
+		},
+		{
+			"階層の件数はパスの前方一致の絞り込み条件を外して数える",
+			model.SearchFilter{Domain: "a.jp", PathPrefix: "/kurashi/"},
+			[]model.SearchFilter{{PathPrefix: "/kurashi/"}, {Domain: "a.jp"}},
+			[]FacetCount{{Value: "a.jp", Count: 3}, {Value: "b.jp", Count: 1}},
+			[]FacetCount{{Value: "/kurashi/", Count: 2}, {Value: "/", Count: 1}, {Value: "/kenko/", Count: 1}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var filters []model.SearchFilter
+			searchPages := func(filter model.SearchFilter) ([]SearchResult, error) {
+				filters = append(filters, filter)
+				return unfilteredPages, nil
+			}
+
+			facets, err := countSearchFacets(pages, tc.filter, searchPages)
+			if err != nil {
+				t.Fatalf("期待されるエラーは nil ですが、実際は '%v' でした", err)
+			}
+			if !reflect.DeepEqual(filters, tc.expectedFilters) {
+				t.Errorf("期待される候補を取得し直す絞り込み条件 '%v' ですが、実際は '%v' でした", tc.expectedFilters, filters)
+			}
+			if !reflect.DeepEqual(facets.Domains, tc.expectedDomains) {
+				t.Errorf("期待されるドメインごとの件数 '%v' ですが、実際は '%v' でした", tc.expectedDomains, facets.Domains)
+			}
+			if !reflect.DeepEqual(facets.Sections, tc.expectedSections) {
+				t.Errorf("期待される階層ごとの件数 '%v' ですが、実際は '%v' でした", tc.expectedSections, facets.Sections)
+			}
+		})
+	}
+}
+
+func TestTopLevelSection(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"/kurashi/gomi/index.html", "/kurashi/"},
+		{"/kurashi/", "/kurashi/"},
+		{"/index.html", "/"},
+		{"/", "/"},
+	}
+
+	for _, tc := range testCases {
+		if actual := topLevelSection(tc.path); actual != tc.expected {
+			t.Errorf("期待される最初の階層 '%s' ですが、実際は '%s' でした（パス: %s）", tc.expected, actual, tc.path)
+		}
+	}
+}
@@ -2,9 +2,17 @@
 package usecase
 
 import (
+	"app/controller/keyword"
 	"app/controller/log"
 	"app/controller/nlp"
 	"app/controller/postgres"
+	"app/domain/model"
+	"app/usecase/entity"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
 )
 
 // 検索結果用のページ情報（ドメイン文字列を含む）
@@ -14,21 +22,243 @@ type PageWithDomain struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Keywords    string `json:"keywords"`
-	Markdown    string `json:"markdown"`
+	Markdown    string `json:"markdown,omitempty"` // 検索結果では include=markdown の場合のみ
+	ContentType string `json:"content_type"`
+}
+
+// 検索のオプション（/search, /rag_search のパラメーター）
+type SearchOptions struct {
+	Mode            string             // 検索モード（SearchModeVector, SearchModeKeyword, SearchModeHybrid）
+	Rerank          bool               // 検索結果をクロスエンコーダーでリランクするかどうか（nlp サーバーにリランカーが設定されている場合のみ）
+	Aggregation     string             // ページのスコアの集計方法（AggregationMax, AggregationSum）
+	IncludeMarkdown bool               // 検索結果にページ全体の Markdown を含めるかどうか
+	Limit           int                // 返却するページ数
+	Offset          int                // 先頭から飛ばすページ数
+	Filter          model.SearchFilter // 絞り込み条件（MinScore は検索結果のチャンクのスコアの下限）
 }
 
 /*
-ページデータのベクトル検索を行う関数
+ページデータの検索を行う関数（検索モードによってベクトル検索・キーワード検索・ハイブリッド検索を切り替える）
+返却する件数より多くのチャンクを取得し、ページごとにまとめてスコア順に返却する（リランクする場合はまとめる前にクロスエンコーダーで並べ替える）
+  - ctx						コンテキスト（クライアントが切断した場合は検索を中断する）
   - query					検索クエリ
-  - resultLimit				返却する件数
-  - return)	similarPages	コサイン類似度が上位のページデータ
+  - options					検索のオプション（検索モード、リランクの有無、スコアの集計方法、Markdown を含めるかどうか、件数、絞り込み条件）
+  - return) response		スコアが上位のページごとの検索結果（スコア、一致したチャンク、スニペットを含む）、絞り込み用の件数、次のページのカーソル
+  - return) err				エラー（候補のチャンク数の上限までに取得したページが offset 以下の場合は ErrInvalidInput を含むエラー）
+*/
+func VectorSearch(ctx context.Context, query string, options SearchOptions) (response SearchResponse, err error) {
+	emptyResponse := SearchResponse{Results: []SearchResult{}, Facets: newSearchFacets(nil, nil)}
+
+	// クエリをベクトル化し、検索対象の NLP設定を特定（キーワード検索のみの場合はベクトル化しない）
+	var nlpConfig entity.DBNlpConfig
+	var vector []float32
+	var found bool
+	if options.Mode == SearchModeKeyword {
+		nlpConfig, found, err = resolveKeywordNlpConfig(ctx, query)
+	} else {
+		nlpConfig, vector, found, err = embedQuery(ctx, query)
+	}
+	if err != nil {
+		log.Error(err)
+		return emptyResponse, err
+	}
+	if !found {
+		return emptyResponse, nil
+	}
+
+	// キーワード検索・スニペットのハイライト用にクエリを形態素解析（チャンクの保存時と同じ規則）
+	queryTerms, err := keyword.Terms(query)
+	if err != nil {
+		log.Error(err)
+		return emptyResponse, err
+	}
+
+	// スコアの下限は、RRF・リランクのスコアには SQL で絞り込めないため、スコアを計算した後に絞り込む
+	chunkFilter := options.Filter
+	filterScoresAfterSearch := options.Filter.MinScore != nil && (options.Mode == SearchModeHybrid || options.Rerank)
+	if filterScoresAfterSearch {
+		chunkFilter.MinScore = nil
+	}
+
+	// 1 つのページの複数のチャンクが上位に並ぶことがあるため、返却する件数より多くのチャンクを取得してページごとにまとめる
+	// 次のページの有無を判定するため、異なるページが offset + limit 件を超えるまで、取得するチャンク数を増やして検索し直す（検索対象のチャンクがなくなるか上限に達するまで）
+	// リランクはチャンクの順番を変えるだけで異なるページ数は変わらないため、候補のチャンクが決まってから一度だけ行う
+	pageLimit := options.Offset + options.Limit
+	maxChunkLimit := maxCollapseCandidates
+	if options.Rerank {
+		// リランクは nlp サーバーに一度に送信できる文書数が上限
+		maxChunkLimit = nlp.MaxRerankPassages
+	}
+	chunkLimit := min(pageLimit*collapseCandidateFactor, maxChunkLimit)
+	var chunks []entity.DBChunk
+	var scores []float32
+	capped := false
+	for {
+		chunks, scores, err = searchChunks(ctx, nlpConfig, vector, queryTerms, options.Mode, chunkFilter, chunkLimit)
+		if err != nil {
+			log.Error(err)
+			return emptyResponse, err
+		}
+		exhausted := len(chunks) < chunkLimit
+
+		// リランクしない場合は、スコアの下限を満たすチャンクのみでページ数を数える
+		if filterScoresAfterSearch && !options.Rerank {
+			chunks, scores = filterByMinScore(chunks, scores, *options.Filter.MinScore)
+		}
+
+		pageCount := len(groupChunksByPage(chunks, scores))
+		if pageCount > pageLimit || exhausted {
+			break
+		}
+		if chunkLimit >= maxChunkLimit {
+			// 上限までの候補で次のページの有無を判定できない場合は、取得できたページのみ返す（次のページのカーソルは返さない）
+			capped = true
+			log.Info(fmt.Sprintf("候補のチャンク数の上限 %d 件に達しました: %d ページ（offset と limit の合計は %d ページ）", maxChunkLimit, pageCount, pageLimit))
+			break
+		}
+		chunkLimit = min(chunkLimit*2, maxChunkLimit)
+	}
+
+	// 候補をクエリとの関連度で並べ替えてページごとにまとめる
+	pages, err := rankCandidatePages(ctx, query, chunks, scores, options, filterScoresAfterSearch)
+	if err != nil {
+		log.Error(err)
+		return emptyResponse, err
+	}
+
+	// 指定の範囲の検索結果に絞り、クエリの語をハイライトしたスニペットを付ける
+	response, err = paginateResults(pages, options.Offset, options.Limit, capped)
+	if err != nil {
+		log.Info(err.Error())
+		return emptyResponse, err
+	}
+
+	// 絞り込み用の件数は、絞り込んだ次元の件数が 1 つの値だけにならないよう、その次元の絞り込み条件を外した同じ件数の候補から数える
+	searchPages := func(filter model.SearchFilter) ([]SearchResult, error) {
+		chunks, scores, err := searchChunks(ctx, nlpConfig, vector, queryTerms, options.Mode, filter, chunkLimit)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return rankCandidatePages(ctx, query, chunks, scores, options, filterScoresAfterSearch)
+	}
+	response.Facets, err = countSearchFacets(pages, chunkFilter, searchPages)
+	if err != nil {
+		log.Error(err)
+		return emptyResponse, err
+	}
+	err = completeSearchResults(response.Results, queryTerms, options.IncludeMarkdown)
+	if err != nil {
+		log.Error(err)
+		return emptyResponse, err
+	}
+
+	return response, nil
+}
+
+/*
+検索モードに応じて、スコアが上位のチャンクを取得する関数
+  - ctx				コンテキスト
+  - nlpConfig		検索対象の NLP設定
+  - vector			クエリのベクトル
+  - queryTerms		クエリを形態素解析した語
+  - mode			検索モード（SearchModeVector, SearchModeKeyword, SearchModeHybrid）
+  - filter			絞り込み条件
+  - chunkLimit		取得するチャンク数
+  - return) chunks	スコア順のチャンク（ページ・ドメイン情報を含む）
+  - return) scores	スコア（検索モードによって異なる）
+  - return) err		エラー
+*/
+func searchChunks(ctx context.Context, nlpConfig entity.DBNlpConfig, vector []float32, queryTerms []string, mode string, filter model.SearchFilter, chunkLimit int) (chunks []entity.DBChunk, scores []float32, err error) {
+	switch mode {
+	case SearchModeKeyword:
+		chunks, scores, err = postgres.GetKeywordMatchedChunks(ctx, nlpConfig, queryTerms, filter, chunkLimit)
+		if err != nil {
+			log.Error(err)
+			return nil, nil, err
+		}
+
+	case SearchModeHybrid:
+		// それぞれの検索で多めに候補を取得し、順位を統合してから取得するチャンク数に絞る
+		hybridLimit := chunkLimit * hybridCandidateFactor
+		vectorChunks, _, err := postgres.GetSimilarChunks(ctx, nlpConfig, vector, filter, hybridLimit)
+		if err != nil {
+			log.Error(err)
+			return nil, nil, err
+		}
+		keywordChunks, _, err := postgres.GetKeywordMatchedChunks(ctx, nlpConfig, queryTerms, filter, hybridLimit)
+		if err != nil {
+			log.Error(err)
+			return nil, nil, err
+		}
+		chunks, scores = fuseRankings(vectorChunks, keywordChunks, getHybridParams(), chunkLimit)
+
+	default:
+		chunks, scores, err = postgres.GetSimilarChunks(ctx, nlpConfig, vector, filter, chunkLimit)
+		if err != nil {
+			log.Error(err)
+			return nil, nil, err
+		}
+	}
+
+	return chunks, scores, nil
+}
+
+/*
+候補のチャンクを必要に応じてリランク・スコアの下限で絞り込み、ページごとにまとめる関数
+  - ctx						コンテキスト
+  - query					検索クエリ
+  - chunks					1 段目の検索のスコア順のチャンク
+  - scores					1 段目の検索のスコア
+  - options					検索のオプション（リランクの有無、スコアの集計方法、スコアの下限）
+  - filterScoresAfterSearch	スコアの下限を検索後に適用するかどうか（RRF・リランクのスコアの場合）
+  - return) pages			ページのスコア順の検索結果
   - return) err				エラー
 */
-func VectorSearch(query string, resultLimit int) (similarPagesWithDomain []PageWithDomain, err error) {
-	resp, _ := nlp.ConvertToVector(query, false)
+func rankCandidatePages(ctx context.Context, query string, chunks []entity.DBChunk, scores []float32, options SearchOptions, filterScoresAfterSearch bool) (pages []SearchResult, err error) {
+	if options.Rerank {
+		chunks, scores, err = rerankChunks(ctx, query, chunks)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+	}
+	if filterScoresAfterSearch {
+		chunks, scores = filterByMinScore(chunks, scores, *options.Filter.MinScore)
+	}
+	return collapseChunks(chunks, scores, options.Aggregation, getSumTopN()), nil
+}
+
+/*
+検索クエリをベクトル化し、クエリのベクトルと比較できる（同じモデル・設定で保存された）NLP設定を特定する関数
+  - ctx					コンテキスト
+  - query				検索クエリ
+  - return) nlpConfig	検索対象の NLP設定
+  - return) vector		クエリのベクトル（チャンクが複数の場合は平均）
+  - return) found		検索対象の NLP設定が保存されているかどうか（未保存の場合は検索対象がない）
+  - return) err			エラー
+*/
+func embedQuery(ctx context.Context, query string) (nlpConfig entity.DBNlpConfig, vector []float32, found bool, err error) {
+	// 検索に使用する NLP設定が管理 API で指定されている場合はそのモデルでクエリをベクトル化（未指定の場合は nlp サーバーのデフォルトのモデル）
+	activeConfig, err := postgres.GetActiveNlpConfig(ctx)
+	hasActiveConfig := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nlpConfig, nil, false, err
+	}
+	modelName := ""
+	if hasActiveConfig {
+		modelName = activeConfig.ModelName
+	}
+
+	resp, err := nlp.ConvertToVector(ctx, query, true, modelName)
+	if err != nil {
+		log.Error(err)
+		return nlpConfig, nil, false, err
+	}
 
 	// 検索用にベクトルを一つにまとめる（平均を取る）
-	vector := resp.Vectors[0]
+	vector = resp.Vectors[0]
 	for i := 1; i < len(resp.Vectors); i++ {
 		for j := 0; j < len(resp.Vectors[i]); j++ {
 			vector[j] += resp.Vectors[i][j]
@@ -38,51 +268,211 @@ func VectorSearch(query string, resultLimit int) (similarPagesWithDomain []PageW
 		vector[i] /= float32(len(resp.Vectors))
 	}
 
-	similarPages, scores, err := postgres.GetSimilarPages(vector, resultLimit)
+	// クエリのベクトルを生成した NLP設定を特定し、同じ設定のベクトルのみと比較する
+	nlpConfig = activeConfig
+	if hasActiveConfig {
+		// nlp サーバーのモデル設定（トークン長、プーリングなど）が変わっている場合は、保存済みのベクトルと比較できない
+		if resp.NlpConfigInfo != activeConfig.NlpConfigInfo {
+			err = fmt.Errorf("nlp サーバーのモデル設定が検索に使用する NLP設定 %d と一致しません: %+v", activeConfig.ID, resp.NlpConfigInfo)
+			log.Error(err)
+			return nlpConfig, nil, false, err
+		}
+	} else {
+		// 未保存の場合は検索対象がない
+		nlpConfig, err = postgres.GetNlpConfig(ctx, resp.NlpConfigInfo)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Info("クエリのモデルでインデックスされたベクトルがありません: " + resp.ModelName)
+			return nlpConfig, nil, false, nil
+		}
+		if err != nil {
+			log.Error(err)
+			return nlpConfig, nil, false, err
+		}
+	}
+
+	return nlpConfig, vector, true, nil
+}
+
+/*
+キーワード検索のみの場合に、検索対象の NLP設定をクエリをベクトル化せずに特定する関数
+チャンクは NLP設定ごとに保存されているため、ベクトル検索と同じ NLP設定のチャンクを検索する
+（検索に使用する NLP設定が指定されておらず、複数の NLP設定が保存されている場合は、nlp サーバーのデフォルトのモデルの NLP設定を特定するためにクエリをベクトル化する）
+  - ctx					コンテキスト
+  - query				検索クエリ
+  - return) nlpConfig	検索対象の NLP設定
+  - return) found		検索対象の NLP設定が保存されているかどうか（未保存の場合は検索対象がない）
+  - return) err			エラー
+*/
+func resolveKeywordNlpConfig(ctx context.Context, query string) (nlpConfig entity.DBNlpConfig, found bool, err error) {
+	nlpConfig, err = postgres.GetActiveNlpConfig(ctx)
+	if err == nil {
+		return nlpConfig, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nlpConfig, false, err
+	}
+
+	configs, err := postgres.GetNlpConfigs(ctx)
 	if err != nil {
 		log.Error(err)
-		return nil, err
+		return nlpConfig, false, err
+	}
+	switch len(configs) {
+	case 0:
+		log.Info("インデックスされたチャンクがありません")
+		return nlpConfig, false, nil
+	case 1:
+		return configs[0], true, nil
 	}
 
-	// 検索結果を PageWithDomain に変換
-	similarPagesWithDomain = make([]PageWithDomain, 0, len(similarPages))
-	for _, page := range similarPages {
-		domainStr := ""
-		if page.Domain != nil {
-			domainStr = page.Domain.Domain
-		}
-		similarPagesWithDomain = append(similarPagesWithDomain, PageWithDomain{
-			Domain:      domainStr,
-			Path:        page.Path,
-			Title:       page.Title,
-			Description: page.Description,
-			Keywords:    page.Keywords,
-			Markdown:    page.Markdown,
-		})
-	}
-
-	// 同一パスを持つページが複数ある場合、スコアの高い方のみを残す
-	type PageWithScore struct {
-		Page  PageWithDomain
-		Score float32
-	}
-	pathToPage := make(map[string]PageWithScore)
-	for i, page := range similarPagesWithDomain {
-		key := page.Domain + page.Path
-		if existing, exists := pathToPage[key]; !exists || scores[i] > existing.Score {
-			pathToPage[key] = PageWithScore{
-				Page:  page,
-				Score: scores[i],
+	nlpConfig, _, found, err = embedQuery(ctx, query)
+	if err != nil {
+		log.Error(err)
+		return nlpConfig, false, err
+	}
+	return nlpConfig, found, nil
+}
+
+/*
+全ページを再ベクトル化して保存し直し、古いチャンクとベクトルを削除する関数（過去のデータの修復用）
+NLP_INDEX_MODELS から外したモデル（プーリングなどを変更する前の NLP設定を含む）のチャンクとベクトルも削除する
+  - ctx				コンテキスト
+  - return) err		エラー
+*/
+func RepairStaleChunks(ctx context.Context) (err error) {
+	// 親が存在しないベクトルとチャンクを削除
+	deletedVectors, deletedChunks, err := postgres.DeleteOrphanChunks(ctx)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	log.Info(fmt.Sprintf("親が存在しないベクトル %d 件、チャンク %d 件を削除しました", deletedVectors, deletedChunks))
+
+	// ページを少しずつ取得して保存し直す（保存時に今回生成されなかったチャンクが削除される）
+	const batchSize = 100
+	var lastId int64
+	repairedCount := 0
+	indexedConfigs := map[model.NlpConfigInfo]bool{} // 今回ベクトル化した NLP設定
+	for {
+		pages, err := postgres.GetPagesAfter(ctx, lastId, batchSize)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		if len(pages) == 0 {
+			break
+		}
+
+		for _, page := range pages {
+			lastId = page.ID
+
+			convertResults, err := nlp.ConvertToVectorForIndex(ctx, page.Markdown)
+			if err != nil {
+				log.Error(err)
+				return err
+			}
+			for _, convertResult := range convertResults {
+				indexedConfigs[convertResult.NlpConfigInfo] = true
 			}
+			_, err = postgres.SaveCrawledData(ctx, page.PageInfo, page.FetchInfo, convertResults)
+			if err != nil {
+				log.Error(err)
+				return err
+			}
+			repairedCount++
+		}
+		log.Info(fmt.Sprintf("%d ページを修復しました", repairedCount))
+	}
+
+	// 全ページを保存し直した後に、今回ベクトル化しなかった NLP設定のチャンクとベクトルを削除
+	err = deleteUnindexedChunks(ctx, indexedConfigs)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+/*
+インデックスに使用していない NLP設定のチャンクとベクトルを削除する関数（検索に使用する NLP設定は残す）
+  - ctx				コンテキスト
+  - indexedConfigs	インデックスに使用している NLP設定（空の場合はページがないため何も削除しない）
+  - return) err		エラー
+*/
+func deleteUnindexedChunks(ctx context.Context, indexedConfigs map[model.NlpConfigInfo]bool) (err error) {
+	if len(indexedConfigs) == 0 {
+		return nil
+	}
+
+	var keepIds []int64
+	for info := range indexedConfigs {
+		nlpConfig, err := postgres.GetNlpConfig(ctx, info)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue // チャンクが生成されなかった（保存されていない）NLP設定
+		}
+		if err != nil {
+			log.Error(err)
+			return err
 		}
+		keepIds = append(keepIds, nlpConfig.ID)
 	}
 
-	// マップから結果を抽出（スコア順にソート済みなので、最初に出てきたものが最高スコア）
-	filteredPages := make([]PageWithDomain, 0, len(pathToPage))
-	for _, pageWithScore := range pathToPage {
-		filteredPages = append(filteredPages, pageWithScore.Page)
+	// 検索に使用する NLP設定は、切り替え前に削除されないよう NLP_INDEX_MODELS に含まれなくても残す
+	activeConfig, err := postgres.GetActiveNlpConfig(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return err
+	}
+	if err == nil && !slices.Contains(keepIds, activeConfig.ID) {
+		log.Info(fmt.Sprintf("検索に使用する NLP設定 %d はインデックスに使用していませんが、チャンクとベクトルを残します", activeConfig.ID))
+		keepIds = append(keepIds, activeConfig.ID)
+	}
+
+	deletedVectors, deletedChunks, err := postgres.DeleteUnindexedChunks(ctx, keepIds)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	log.Info(fmt.Sprintf("インデックスに使用していない NLP設定のベクトル %d 件、チャンク %d 件を削除しました", deletedVectors, deletedChunks))
+
+	return nil
+}
+
+/*
+クロール実行情報を新しい順に取得する関数
+  - ctx				コンテキスト
+  - domainId		ドメインID（0 の場合は全ドメイン）
+  - limit			取得する件数
+  - return) runs	クロール実行情報のスライス
+  - return) err		エラー
+*/
+func GetCrawlRuns(ctx context.Context, domainId int64, limit int) (runs []entity.DBCrawlRun, err error) {
+	runs, err = postgres.GetCrawlRuns(ctx, domainId, limit)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+/*
+クロール実行中に失敗した URL の情報を取得する関数
+  - ctx					コンテキスト
+  - crawlRunId			クロール実行ID
+  - errorType			失敗の種類（空文字の場合は全種類）
+  - limit				取得する件数
+  - return) failures	クロール失敗情報のスライス
+  - return) err			エラー
+*/
+func GetCrawlFailures(ctx context.Context, crawlRunId int64, errorType string, limit int) (failures []entity.DBCrawlFailure, err error) {
+	failures, err = postgres.GetCrawlFailures(ctx, crawlRunId, errorType, limit)
+	if err != nil {
+		log.Error(err)
+		return nil, err
 	}
-	similarPagesWithDomain = filteredPages
 
-	return similarPagesWithDomain, nil
+	return failures, nil
 }
@@ -2,11 +2,13 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
+	"sync"
+	"time"
 
 	"nlp/vectorize"
 )
@@ -17,10 +19,27 @@ import (
 
 var port = "8000"
 
+// シャットダウン時に処理中のリクエストの完了を待つ最大時間
+const shutdownTimeout = 20 * time.Second
+
+// 起動時に読み込んだ埋め込みモデル（全リクエストで使い回す）
+var registry *vectorize.Registry
+
+// 起動時に読み込んだリランカー（設定されていない場合は nil）
+var reranker *vectorize.Reranker
+
+// 起動後のモデルごとの推論の確認（ウォームアップ）の状態（/readyz で使用する）
+var readiness = struct {
+	sync.RWMutex
+	done bool  // ウォームアップが終了したかどうか
+	err  error // ウォームアップで推論に失敗した場合のエラー
+}{}
+
 // リクエスト用の構造体
 type ConvertRequest struct {
 	Text    string `json:"text"`
 	IsQuery bool   `json:"is_query"`
+	Model   string `json:"model"` // 使用するモデル名（省略時はデフォルトのモデル）
 }
 
 // NLPサーバーからのレスポンス用の構造体
@@ -30,26 +49,137 @@ type ConvertResponse struct {
 	OverlapTokenLength int         `json:"overlap_token_length"`
 	ModelName          string      `json:"model_name"`
 	ModelVectorLength  int         `json:"model_vector_length"`
+	Pooling            string      `json:"pooling"`    // プーリング戦略（mean, cls, max, last）
+	Normalized         bool        `json:"normalized"` // ベクトルが L2 正規化済み（ノルムが 1）かどうか
 	Chunks             []string    `json:"chunks"`
 	Vectors            [][]float32 `json:"vectors"`
 }
 
+// /embed_batch で一度に受け付けるテキスト数の上限
+const maxBatchTexts = 256
+
+// バッチリクエスト用の構造体
+type EmbedBatchRequest struct {
+	Texts   []string `json:"texts"`
+	IsQuery bool     `json:"is_query"`
+	Model   string   `json:"model"` // 使用するモデル名（省略時はデフォルトのモデル）
+}
+
+// バッチリクエストのテキストごとの結果
+type EmbedBatchResult struct {
+	Chunks  []string    `json:"chunks"`
+	Vectors [][]float32 `json:"vectors"`
+}
+
+// NLPサーバーからのバッチレスポンス用の構造体（Results は Texts と同じ順番）
+// app/controller/nlp/nlp.go と同じ構造体
+type EmbedBatchResponse struct {
+	MaxTokenLength     int                `json:"max_token_length"`
+	OverlapTokenLength int                `json:"overlap_token_length"`
+	ModelName          string             `json:"model_name"`
+	ModelVectorLength  int                `json:"model_vector_length"`
+	Pooling            string             `json:"pooling"`    // プーリング戦略（mean, cls, max, last）
+	Normalized         bool               `json:"normalized"` // ベクトルが L2 正規化済み（ノルムが 1）かどうか
+	Results            []EmbedBatchResult `json:"results"`
+}
+
+// /rerank で一度に受け付ける文書数の上限
+const maxRerankPassages = 256
+
+// リランクリクエスト用の構造体
+type RerankRequest struct {
+	Query    string   `json:"query"`
+	Passages []string `json:"passages"`
+}
+
+// NLPサーバーからのリランクレスポンス用の構造体（Scores は Passages と同じ順番）
+// app/controller/nlp/nlp.go と同じ構造体
+type RerankResponse struct {
+	ModelName string    `json:"model_name"`
+	Scores    []float32 `json:"scores"` // クエリとの関連度（0〜1、1 に近いほど関連が強い）
+}
+
+// /models のレスポンスのモデルごとの情報
+type ModelInfo struct {
+	vectorize.EmbedderConfig
+	IsDefault bool `json:"is_default"` // モデルが指定されなかった場合に使用されるかどうか
+}
+
 // ====================================================================================
 // API関数
 // ====================================================================================
 
-// APIサーバーを起動する関数
-func StartServer() {
-	http.HandleFunc("/", handler)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+/*
+APIサーバーを起動する関数（ctx がキャンセルされるまでブロックし、キャンセル後は処理中のリクエストの完了を待って終了する）
+  - ctx		キャンセルされるとサーバーを停止するコンテキスト
+  - r		起動時に読み込んだ埋め込みモデル
+  - rr		起動時に読み込んだリランカー（設定されていない場合は nil）
+*/
+func StartServer(ctx context.Context, r *vectorize.Registry, rr *vectorize.Reranker) {
+	registry = r
+	reranker = rr
+
+	// 接続を受け付けながらモデルごとに一度推論し、成功するまで /readyz は 503 を返す
+	// 終了後にモデルが解放されるため、サーバーの停止後もウォームアップの終了を待ってから戻る
+	warmUpDone := make(chan struct{})
+	go func() {
+		defer close(warmUpDone)
+		warmUp()
+	}()
+	defer func() { <-warmUpDone }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: mux,
+	}
+
+	// ctx がキャンセルされたら新規の接続を止め、処理中のリクエストの完了を shutdownTimeout まで待つ
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		fmt.Println("サーバーを停止しています")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			fmt.Printf("サーバーの停止に失敗しました: %v\n", err)
+		}
+	}()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		fmt.Printf("サーバーの起動に失敗しました: %v\n", err)
+		return
 	}
+	<-shutdownDone
+	fmt.Println("サーバーを停止しました")
+}
+
+// 読み込んだすべてのモデル（リランカーを含む）で一度推論し、結果を readiness に記録する関数
+func warmUp() {
+	err := registry.WarmUp()
+	if err == nil && reranker != nil {
+		err = reranker.WarmUp()
+	}
+	if err != nil {
+		fmt.Printf("ウォームアップに失敗しました: %v\n", err)
+	} else {
+		fmt.Println("ウォームアップ完了")
+	}
+
+	readiness.Lock()
+	defer readiness.Unlock()
+	readiness.done = true
+	readiness.err = err
 }
 
 // リクエストを処理する関数
 func handler(w http.ResponseWriter, r *http.Request) {
 	// リクエストのメソッドによって処理を分岐
 	switch r.Method {
+	case "GET":
+		getHandler(w, r)
 	case "POST":
 		postHandler(w, r)
 	default:
@@ -57,6 +187,48 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GETリクエストを処理する関数
+func getHandler(w http.ResponseWriter, r *http.Request) {
+	// リクエストパスによって処理を分岐
+	switch r.URL.Path {
+
+	// liveness チェック（プロセスが応答できれば常に 200）
+	case "/healthz":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	// readiness チェック（すべてのモデルで推論できることを確認するまでと、推論に失敗した場合は 503）
+	case "/readyz":
+		w.Header().Set("Content-Type", "application/json")
+		readiness.RLock()
+		done, err := readiness.done, readiness.err
+		readiness.RUnlock()
+		if !done {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "error": "モデルのウォームアップ中です"})
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	// 読み込んだモデルの一覧（設定ファイルの順番、先頭がデフォルト）
+	case "/models":
+		models := []ModelInfo{}
+		for _, config := range registry.Configs() {
+			models = append(models, ModelInfo{EmbedderConfig: config, IsDefault: config.ModelName == registry.DefaultName()})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models)
+
+	default:
+		fmt.Fprintf(w, "Not found")
+	}
+}
+
 // POSTリクエストを処理する関数
 func postHandler(w http.ResponseWriter, r *http.Request) {
 	// リクエストパスを取得
@@ -75,50 +247,117 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		chunks, vectors, err := vectorize.ConvertToVector(req.Text, req.IsQuery)
+		// 指定されたモデルを取得
+		embedder, err := registry.Get(req.Model)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		chunks, vectors, err := embedder.ConvertToVector(req.Text, req.IsQuery)
 		if err != nil {
 			fmt.Printf("ベクトル化エラー: %v\n", err)
 			http.Error(w, fmt.Sprintf("ベクトル化エラー: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		// 環境変数からの値を取得し、整数に変換
-		maxTokenLengthStr := os.Getenv("MAX_TOKEN_LENGTH")
-		overlapTokenLengthStr := os.Getenv("OVERLAP_TOKEN_LENGTH")
-		modelVectorLengthStr := os.Getenv("MODEL_VECTOR_LENGTH")
-		maxTokenLength, err := strconv.Atoi(maxTokenLengthStr)
-		if err != nil {
-			fmt.Printf("MAX_TOKEN_LENGTH の変換エラー: %v\n", err)
-			http.Error(w, fmt.Sprintf("MAX_TOKEN_LENGTH の変換エラー: %v", err), http.StatusInternalServerError)
+		// レスポンスを構造体に変換（設定値は起動時に読み込んだものを使用）
+		config := embedder.Config()
+		response := ConvertResponse{
+			MaxTokenLength:     config.MaxTokenLength,
+			OverlapTokenLength: config.OverlapTokenLength,
+			ModelVectorLength:  config.VectorLength,
+			ModelName:          config.ModelName,
+			Pooling:            config.Pooling,
+			Normalized:         config.Normalize,
+			Chunks:             chunks,
+			Vectors:            vectors,
+		}
+
+		// レスポンスをJSON形式で返す
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	case "/embed_batch":
+		var req EmbedBatchRequest
+
+		// リクエストボディを構造体に変換
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			fmt.Printf("リクエストボディのデコードエラー: %v\n", err)
+			http.Error(w, "無効なリクエストボディ", http.StatusBadRequest)
 			return
 		}
-		overlapTokenLength, err := strconv.Atoi(overlapTokenLengthStr)
+		if len(req.Texts) > maxBatchTexts {
+			http.Error(w, fmt.Sprintf("texts は %d 件以下で指定してください", maxBatchTexts), http.StatusBadRequest)
+			return
+		}
+
+		// 指定されたモデルを取得
+		embedder, err := registry.Get(req.Model)
 		if err != nil {
-			fmt.Printf("OVERLAP_TOKEN_LENGTH の変換エラー: %v\n", err)
-			http.Error(w, fmt.Sprintf("OVERLAP_TOKEN_LENGTH の変換エラー: %v", err), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		modelVectorLength, err := strconv.Atoi(modelVectorLengthStr)
+
+		// 全テキストのチャンクをまとめてバッチ推論
+		chunksList, vectorsList, err := embedder.ConvertToVectorBatch(req.Texts, req.IsQuery)
 		if err != nil {
-			fmt.Printf("MODEL_VECTOR_LENGTH の変換エラー: %v\n", err)
-			http.Error(w, fmt.Sprintf("MODEL_VECTOR_LENGTH の変換エラー: %v", err), http.StatusInternalServerError)
+			fmt.Printf("ベクトル化エラー: %v\n", err)
+			http.Error(w, fmt.Sprintf("ベクトル化エラー: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		// レスポンスを構造体に変換
-		response := ConvertResponse{
-			MaxTokenLength:     maxTokenLength,
-			OverlapTokenLength: overlapTokenLength,
-			ModelVectorLength:  modelVectorLength,
-			ModelName:          os.Getenv("MODEL_NAME"),
-			Chunks:             chunks,
-			Vectors:            vectors,
+		// レスポンスを構造体に変換（設定値は起動時に読み込んだものを使用）
+		config := embedder.Config()
+		response := EmbedBatchResponse{
+			MaxTokenLength:     config.MaxTokenLength,
+			OverlapTokenLength: config.OverlapTokenLength,
+			ModelVectorLength:  config.VectorLength,
+			ModelName:          config.ModelName,
+			Pooling:            config.Pooling,
+			Normalized:         config.Normalize,
+			Results:            make([]EmbedBatchResult, len(req.Texts)),
+		}
+		for i := range req.Texts {
+			response.Results[i] = EmbedBatchResult{Chunks: chunksList[i], Vectors: vectorsList[i]}
 		}
 
 		// レスポンスをJSON形式で返す
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 
+	case "/rerank":
+		var req RerankRequest
+
+		// リランカーが設定されていない場合は利用できない
+		if reranker == nil {
+			http.Error(w, "リランカーが設定されていません（RERANKER_MODEL_PATH 環境変数）", http.StatusServiceUnavailable)
+			return
+		}
+
+		// リクエストボディを構造体に変換
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			fmt.Printf("リクエストボディのデコードエラー: %v\n", err)
+			http.Error(w, "無効なリクエストボディ", http.StatusBadRequest)
+			return
+		}
+		if len(req.Passages) > maxRerankPassages {
+			http.Error(w, fmt.Sprintf("passages は %d 件以下で指定してください", maxRerankPassages), http.StatusBadRequest)
+			return
+		}
+
+		// クエリと各文書の組の関連度をバッチ推論
+		scores, err := reranker.Rerank(req.Query, req.Passages)
+		if err != nil {
+			fmt.Printf("リランクエラー: %v\n", err)
+			http.Error(w, fmt.Sprintf("リランクエラー: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// レスポンスをJSON形式で返す
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(RerankResponse{ModelName: reranker.Config().ModelName, Scores: scores})
+
 	default:
 		fmt.Fprintf(w, "Not found")
 	}
@@ -5,5 +5,9 @@ go 1.24.4
 require (
 	github.com/daulet/tokenizers v1.22.2
 	github.com/yalue/onnxruntime_go v1.21.0
-	golang.org/x/text v0.28.0
+	textnorm v0.0.0
 )
+
+require golang.org/x/text v0.28.0 // indirect
+
+replace textnorm => ../textnorm
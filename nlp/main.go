@@ -1,10 +1,65 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yalue/onnxruntime_go"
+
 	"nlp/api"
+	"nlp/vectorize"
 )
 
 func main() {
-	// API サーバー起動
-	api.StartServer()
+	// SIGTERM（コンテナ停止時）と SIGINT（Ctrl+C）でキャンセルされるコンテキスト
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// モデル・トークナイザーを起動時に一度だけ読み込み、全リクエストで使い回す
+	// MODELS_CONFIG にモデル設定ファイル（models.json）が指定されている場合は複数のモデルを読み込み、未指定の場合は環境変数の単一モデルを使用
+	var configs []vectorize.EmbedderConfig
+	if modelsConfigPath := os.Getenv("MODELS_CONFIG"); modelsConfigPath != "" {
+		loadedConfigs, err := vectorize.LoadModelConfigs(modelsConfigPath)
+		if err != nil {
+			fmt.Printf("モデル設定ファイルの読み込みに失敗しました: %v\n", err)
+			return
+		}
+		configs = loadedConfigs
+	} else {
+		config, err := vectorize.EmbedderConfigFromEnv()
+		if err != nil {
+			fmt.Printf("設定の読み込みに失敗しました: %v\n", err)
+			return
+		}
+		configs = []vectorize.EmbedderConfig{config}
+	}
+	registry, err := vectorize.NewRegistry(configs)
+	if err != nil {
+		fmt.Printf("埋め込みモデルの読み込みに失敗しました: %v\n", err)
+		return
+	}
+	defer onnxruntime_go.DestroyEnvironment()
+	defer registry.Close()
+
+	// リランカー（クロスエンコーダー）は RERANKER_MODEL_PATH が指定されている場合のみ読み込む
+	var reranker *vectorize.Reranker
+	rerankerConfig, rerankerEnabled, err := vectorize.RerankerConfigFromEnv()
+	if err != nil {
+		fmt.Printf("リランカーの設定の読み込みに失敗しました: %v\n", err)
+		return
+	}
+	if rerankerEnabled {
+		reranker, err = vectorize.NewReranker(rerankerConfig)
+		if err != nil {
+			fmt.Printf("リランカーの読み込みに失敗しました: %v\n", err)
+			return
+		}
+		defer reranker.Close()
+	}
+
+	// API サーバー起動（停止時は処理中のリクエストの完了を待つ）
+	api.StartServer(ctx, registry, reranker)
 }
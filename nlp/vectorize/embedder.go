@@ -0,0 +1,189 @@
+package vectorize
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/daulet/tokenizers"
+	"github.com/yalue/onnxruntime_go"
+)
+
+// 埋め込みモデルの設定（models.json の各要素に対応する）
+type EmbedderConfig struct {
+	ModelName          string `json:"model_name"`           // モデル名（リクエストでのモデルの指定、レスポンスと nlp_configs に記録される）
+	ModelPath          string `json:"model_path"`           // ONNX モデルファイルのパス
+	TokenizerPath      string `json:"tokenizer_path"`       // tokenizer.json のパス
+	LibraryPath        string `json:"-"`                    // libonnxruntime.so のパス（全モデル共通のため LIBRARY_PATH 環境変数から設定）
+	MaxTokenLength     int    `json:"max_token_length"`     // 最大トークン長
+	OverlapTokenLength int    `json:"overlap_token_length"` // チャンク間のオーバーラップトークン長
+	VectorLength       int    `json:"vector_length"`        // モデルの出力ベクトルの次元数
+	MaxBatchSize       int    `json:"max_batch_size"`       // 1 回の推論でまとめてベクトル化するチャンク数の上限
+	Pooling            string `json:"pooling"`              // プーリング戦略（mean, cls, max, last のいずれか）
+	Normalize          bool   `json:"normalize"`            // プーリング後のベクトルを L2 正規化するかどうか
+	QueryPrefix        string `json:"query_prefix"`         // クエリの文頭に付与するプレフィックス
+	PassagePrefix      string `json:"passage_prefix"`       // 文書（チャンク）の文頭に付与するプレフィックス
+}
+
+// MAX_BATCH_SIZE 環境変数が設定されていない場合のバッチサイズ
+const defaultMaxBatchSize = 32
+
+// トークナイザーと ONNX セッションを保持し、起動時に一度だけ読み込んで使い回すための構造体
+// tokenizer と session は並行して利用できるため、複数のリクエストから同時に呼び出してよい
+type Embedder struct {
+	config    EmbedderConfig
+	tokenizer *tokenizers.Tokenizer
+	session   *onnxruntime_go.DynamicAdvancedSession
+	pooling   poolingFunc // config.Pooling に対応するプーリング関数
+}
+
+/*
+任意設定の項目にデフォルト値を設定した埋め込みモデルの設定を作成する関数
+  - return)	デフォルト値を設定した埋め込みモデルの設定
+*/
+func defaultEmbedderConfig() EmbedderConfig {
+	return EmbedderConfig{
+		LibraryPath:   os.Getenv("LIBRARY_PATH") + "/libonnxruntime.so",
+		MaxBatchSize:  defaultMaxBatchSize,
+		Pooling:       PoolingMean,
+		Normalize:     false, // 正規化の導入前に保存されたベクトル（NLP設定の normalized = false）と一致させる
+		QueryPrefix:   "query: ",
+		PassagePrefix: "passage: ",
+	}
+}
+
+/*
+埋め込みモデルの設定を検証する関数
+  - return) err	エラー（必須項目の不足、無効な値）
+*/
+func (config EmbedderConfig) validate() (err error) {
+	if config.ModelName == "" || config.ModelPath == "" || config.TokenizerPath == "" {
+		return fmt.Errorf("model_name, model_path, tokenizer_path は必須です: %q", config.ModelName)
+	}
+	if config.MaxTokenLength <= 0 || config.OverlapTokenLength < 0 || config.OverlapTokenLength >= config.MaxTokenLength {
+		return fmt.Errorf("max_token_length, overlap_token_length が無効です: %s", config.ModelName)
+	}
+	if config.VectorLength <= 0 {
+		return fmt.Errorf("vector_length が無効です: %s", config.ModelName)
+	}
+	if config.MaxBatchSize <= 0 {
+		return fmt.Errorf("max_batch_size が無効です: %s", config.ModelName)
+	}
+	if _, err = getPoolingFunc(config.Pooling); err != nil {
+		return fmt.Errorf("pooling が無効です: %s: %v", config.ModelName, err)
+	}
+	return nil
+}
+
+/*
+環境変数から埋め込みモデルの設定を読み込む関数（models.json を使用しない単一モデルの場合）
+  - return) config	埋め込みモデルの設定
+  - return) err		エラー
+*/
+func EmbedderConfigFromEnv() (config EmbedderConfig, err error) {
+	config = defaultEmbedderConfig()
+	config.ModelName = os.Getenv("MODEL_NAME")
+	config.ModelPath = os.Getenv("DOWNLOAD_DIR") + "/" + os.Getenv("SAVED_MODEL_PATH")
+	config.TokenizerPath = os.Getenv("DOWNLOAD_DIR") + "/" + os.Getenv("SAVED_TOKENIZER_PATH")
+	for _, value := range []struct {
+		name   string
+		target *int
+	}{
+		{"MAX_TOKEN_LENGTH", &config.MaxTokenLength},
+		{"OVERLAP_TOKEN_LENGTH", &config.OverlapTokenLength},
+		{"MODEL_VECTOR_LENGTH", &config.VectorLength},
+	} {
+		*value.target, err = strconv.Atoi(os.Getenv(value.name))
+		if err != nil {
+			return config, fmt.Errorf("%s 環境変数が設定されていないか無効です: %v", value.name, err)
+		}
+	}
+
+	// バッチサイズ、プーリング戦略、L2 正規化は任意設定（未設定の場合はデフォルト値）
+	if value := os.Getenv("MAX_BATCH_SIZE"); value != "" {
+		config.MaxBatchSize, err = strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("MAX_BATCH_SIZE 環境変数が無効です: %s", value)
+		}
+	}
+	if value := os.Getenv("POOLING"); value != "" {
+		config.Pooling = value
+	}
+	if value := os.Getenv("NORMALIZE"); value != "" {
+		config.Normalize, err = strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("NORMALIZE 環境変数が無効です: %s", value)
+		}
+	}
+
+	err = config.validate()
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+/*
+トークナイザーと ONNX セッションを読み込んで Embedder を作成する関数（ONNX Runtime 環境も初期化する）
+  - config			埋め込みモデルの設定
+  - return) e		作成した Embedder（不要になったら Close を呼ぶ）
+  - return) err		エラー
+*/
+func NewEmbedder(config EmbedderConfig) (e *Embedder, err error) {
+	e = &Embedder{config: config}
+
+	// プーリング関数の取得
+	e.pooling, err = getPoolingFunc(config.Pooling)
+	if err != nil {
+		return nil, err
+	}
+
+	// トークナイザーの読み込み、トランケーション（長すぎるトークンの切り捨て）方向は右側
+	tokenizerData, err := os.ReadFile(config.TokenizerPath)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer.json の読み込みに失敗しました: %v", err)
+	}
+	e.tokenizer, err = tokenizers.FromBytesWithTruncation(tokenizerData, uint32(config.MaxTokenLength), tokenizers.TruncationDirectionRight)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer.json ロードエラー: %v", err)
+	}
+
+	// ONNX Runtime 環境の初期化（プロセスで一度だけ）
+	if !onnxruntime_go.IsInitialized() {
+		onnxruntime_go.SetSharedLibraryPath(config.LibraryPath)
+		err = onnxruntime_go.InitializeEnvironment()
+		if err != nil {
+			e.Close()
+			return nil, fmt.Errorf("ONNX Runtime の初期化に失敗しました: %v", err)
+		}
+	}
+
+	// セッション作成、入力の形状はリクエストごとに異なるため DynamicAdvancedSession を使用
+	inputNames := []string{"input_ids", "attention_mask", "token_type_ids"} // 3つの入力を指定
+	outputNames := []string{"last_hidden_state"}                            // BERT系モデルの一般的な出力名
+	e.session, err = onnxruntime_go.NewDynamicAdvancedSession(config.ModelPath, inputNames, outputNames, nil)
+	if err != nil {
+		e.Close()
+		return nil, fmt.Errorf("セッションの作成に失敗しました: %v", err)
+	}
+
+	return e, nil
+}
+
+// Embedder が保持するトークナイザーとセッションを解放する関数（ONNX Runtime 環境は DestroyEnvironment で別途破棄する）
+func (e *Embedder) Close() {
+	if e.session != nil {
+		e.session.Destroy()
+		e.session = nil
+	}
+	if e.tokenizer != nil {
+		e.tokenizer.Close()
+		e.tokenizer = nil
+	}
+}
+
+// 埋め込みモデルの設定を返す関数
+func (e *Embedder) Config() EmbedderConfig {
+	return e.config
+}
@@ -0,0 +1,145 @@
+package vectorize
+
+import (
+	"fmt"
+	"math"
+)
+
+// プーリング戦略（トークンごとの出力からテキスト全体のベクトルを計算する方法、モデルの学習時の方法に合わせて選択する）
+const (
+	PoolingMean = "mean" // attention_mask で有効なトークンの平均（sentence-transformers の多くのモデル）
+	PoolingCLS  = "cls"  // 先頭トークン（[CLS]）の出力
+	PoolingMax  = "max"  // attention_mask で有効なトークンの要素ごとの最大値
+	PoolingLast = "last" // 最後の有効なトークンの出力（デコーダー系の埋め込みモデル）
+)
+
+/*
+1 テキスト分のトークン出力からベクトルを計算する関数の型
+  - tokenOutputs	1 テキスト分のモデルの出力（seqLen * hiddenSize の 1 次元配列）
+  - attentionMask	1 テキスト分の attention_mask（有効なトークンは 1、パディングは 0）
+  - hiddenSize		モデルの隠れ層の次元数
+  - return)			テキスト全体のベクトル
+*/
+type poolingFunc func(tokenOutputs []float32, attentionMask []int64, hiddenSize int) []float32
+
+// プーリング戦略名と関数の対応
+var poolingFuncs = map[string]poolingFunc{
+	PoolingMean: meanPooling,
+	PoolingCLS:  clsPooling,
+	PoolingMax:  maxPooling,
+	PoolingLast: lastTokenPooling,
+}
+
+/*
+プーリング戦略名から関数を取得する関数
+  - pooling		プーリング戦略名（mean, cls, max, last のいずれか）
+  - return) fn	プーリング関数
+  - return) err	未対応の戦略名の場合はエラー
+*/
+func getPoolingFunc(pooling string) (fn poolingFunc, err error) {
+	fn, ok := poolingFuncs[pooling]
+	if !ok {
+		return nil, fmt.Errorf("未対応のプーリング戦略です: %s（mean, cls, max, last のいずれかを指定してください）", pooling)
+	}
+	return fn, nil
+}
+
+/*
+バッチ全体のモデル出力をテキストごとに分割し、プーリング（と L2 正規化）してベクトルを計算する関数
+  - pool			プーリング関数
+  - normalize		L2 正規化するかどうか
+  - outputData		モデルの出力（batch_size * seqLen * hiddenSize の 1 次元配列）
+  - attentionMask	padBatch で作成した attention_mask
+  - batchSize		バッチサイズ
+  - seqLen			パディング後のトークン数
+  - hiddenSize		モデルの隠れ層の次元数
+  - return) vectors	テキストごとのベクトル
+*/
+func poolBatch(pool poolingFunc, normalize bool, outputData []float32, attentionMask []int64, batchSize int, seqLen int, hiddenSize int) (vectors [][]float32) {
+	vectors = make([][]float32, batchSize)
+	for b := 0; b < batchSize; b++ {
+		tokenOutputs := outputData[b*seqLen*hiddenSize : (b+1)*seqLen*hiddenSize]
+		mask := attentionMask[b*seqLen : (b+1)*seqLen]
+		vectors[b] = pool(tokenOutputs, mask, hiddenSize)
+		if normalize {
+			normalizeL2(vectors[b])
+		}
+	}
+	return vectors
+}
+
+// attention_mask で有効なトークンのみを平均化するプーリング関数
+func meanPooling(tokenOutputs []float32, attentionMask []int64, hiddenSize int) (vector []float32) {
+	vector = make([]float32, hiddenSize)
+	tokenCount := 0
+	for t, m := range attentionMask {
+		if m == 0 {
+			continue
+		}
+		tokenCount++
+		for h := 0; h < hiddenSize; h++ {
+			vector[h] += tokenOutputs[t*hiddenSize+h]
+		}
+	}
+	if tokenCount > 0 {
+		for h := range vector {
+			vector[h] /= float32(tokenCount) // 平均化
+		}
+	}
+	return vector
+}
+
+// 先頭トークン（[CLS]）の出力をそのまま使うプーリング関数
+func clsPooling(tokenOutputs []float32, attentionMask []int64, hiddenSize int) (vector []float32) {
+	vector = make([]float32, hiddenSize)
+	if len(attentionMask) > 0 {
+		copy(vector, tokenOutputs[:hiddenSize])
+	}
+	return vector
+}
+
+// attention_mask で有効なトークンの要素ごとの最大値を取るプーリング関数
+func maxPooling(tokenOutputs []float32, attentionMask []int64, hiddenSize int) (vector []float32) {
+	vector = make([]float32, hiddenSize)
+	first := true
+	for t, m := range attentionMask {
+		if m == 0 {
+			continue
+		}
+		for h := 0; h < hiddenSize; h++ {
+			value := tokenOutputs[t*hiddenSize+h]
+			if first || value > vector[h] {
+				vector[h] = value
+			}
+		}
+		first = false
+	}
+	return vector
+}
+
+// 最後の有効なトークン（右側パディングのためパディング直前のトークン）の出力を使うプーリング関数
+func lastTokenPooling(tokenOutputs []float32, attentionMask []int64, hiddenSize int) (vector []float32) {
+	vector = make([]float32, hiddenSize)
+	for t := len(attentionMask) - 1; t >= 0; t-- {
+		if attentionMask[t] != 0 {
+			copy(vector, tokenOutputs[t*hiddenSize:(t+1)*hiddenSize])
+			break
+		}
+	}
+	return vector
+}
+
+// ベクトルを L2 ノルムが 1 になるように正規化する関数（ゼロベクトルはそのまま）
+func normalizeL2(vector []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+}
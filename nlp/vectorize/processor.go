@@ -2,14 +2,10 @@ package vectorize
 
 import (
 	"fmt"
-	"os"
 	"regexp"
-	"strconv"
 	"strings"
 
-	"github.com/daulet/tokenizers"
 	"github.com/yalue/onnxruntime_go"
-	"golang.org/x/text/unicode/norm"
 )
 
 /*
@@ -29,29 +25,8 @@ func replaceLinks(markdown string) (replacedMarkdown string) {
 	return replacedMarkdown
 }
 
-// テキストの正規化関数
-func normalizeText(text string) (normalizedText string) {
-	// Unicode正規化（NFKC）、全角カタカナに統一、半角英数に統一など
-	normalizedText = norm.NFKC.String(text)
-
-	// 小文字化
-	normalizedText = strings.ToLower(normalizedText)
-
-	// 3 回以上の連続する改行を 2 回の改行に置換
-	normalizedText = regexp.MustCompile(`(\n{3,})`).ReplaceAllString(normalizedText, "\n\n")
-
-	// 「., !, ?, 、, 。」が複数連続する場合は1つに置換
-	punctRe := regexp.MustCompile(`([.!?、。ー])(?:[.!?、。ー])+`)
-	normalizedText = punctRe.ReplaceAllString(normalizedText, "$1")
-
-	// 最初と最後の空白と改行を削除
-	normalizedText = strings.TrimSpace(normalizedText)
-
-	return normalizedText
-}
-
 // チャンキング関数
-func chunkText(text string, maxToken int, overlapMaxToken int) (chunks []string) {
+func (e *Embedder) chunkText(text string, maxToken int, overlapMaxToken int) (chunks []string) {
 	// 簡易的に文単位で分割、正規化段階で句読点の連続を1つにしているため、ここでは単純に句点と改行で分割
 	sentences := regexp.MustCompile(`(?m)([^\n。!?]*[。!?\n]|[^\n。!?]+$)`).FindAllString(text, -1)
 
@@ -61,7 +36,7 @@ func chunkText(text string, maxToken int, overlapMaxToken int) (chunks []string)
 
 	for i, sentence := range sentences {
 		// トークン数を簡易的に文字数で代用（正確にはトークナイザーで計測するのが望ましい）
-		tokenIds, err := tokenize(sentence)
+		tokenIds, err := e.tokenize(sentence)
 		if err != nil {
 			fmt.Printf("トークナイズエラー: %v\n", err)
 			return nil
@@ -112,34 +87,9 @@ func chunkText(text string, maxToken int, overlapMaxToken int) (chunks []string)
 }
 
 // トークナイズ関数
-func tokenize(text string) (ids []uint32, err error) {
-	// 環境変数から設定を読み込む
-	tokenizerPath := os.Getenv("DOWNLOAD_DIR") + "/" + os.Getenv("SAVED_TOKENIZER_PATH")
-
-	tokenizerData, err := os.ReadFile(tokenizerPath)
-	if err != nil {
-		// エラーハンドリング
-		return nil, err
-	}
-
-	// 最大トークン数を環境変数から取得
-	maxTokenLengthStr := os.Getenv("MAX_TOKEN_LENGTH")
-	maxTokenLength, err := strconv.ParseUint(maxTokenLengthStr, 10, 32)
-	if err != nil {
-		fmt.Printf("MAX_TOKEN_LENGTH の変換エラー: %v\n", err)
-		return nil, err
-	}
-
-	// トランケーション（長すぎるトークンの切り捨て）方向は右側
-	tk, err := tokenizers.FromBytesWithTruncation(tokenizerData, uint32(maxTokenLength), tokenizers.TruncationDirectionRight)
-	if err != nil {
-		fmt.Printf("tokenizer.json ロードエラー: %v\n", err)
-		return nil, err
-	}
-	defer tk.Close()
-
+func (e *Embedder) tokenize(text string) (ids []uint32, err error) {
 	// トークン化（デバッグ時は戻り値を ids, tokens に保存）
-	ids, _ = tk.Encode(text, true) // 第二引数 addSpecialTokens を true にしないと python の結果と異なってしまう
+	ids, _ = e.tokenizer.Encode(text, true) // 第二引数 addSpecialTokens を true にしないと python の結果と異なってしまう
 
 	// トークン数を表示
 	// fmt.Printf("%s", text)
@@ -148,86 +98,81 @@ func tokenize(text string) (ids []uint32, err error) {
 	return ids, nil
 }
 
-// ONNX推論用のヘルパー関数
-func vectorize(tokenIds []uint32) (sentenceVector []float32, err error) {
-	modelVectorLengthStr := os.Getenv("MODEL_VECTOR_LENGTH")
-	modelVectorLength, err := strconv.ParseInt(modelVectorLengthStr, 10, 64)
-	if err != nil {
-		return nil, fmt.Errorf("MODEL_VECTOR_LENGTH 環境変数が設定されていないか無効です: %v", err)
+/*
+トークンID列のバッチを最長の長さに合わせて右側をパディングし、ONNX 入力用の 1 次元配列を作成する関数
+パディング位置は attention_mask が 0 になり推論時に無視されるため、パディングの ID には 0 を使用する
+  - batchIds				テキストごとのトークンID列
+  - return) inputIds		パディング済みのトークンID（batch_size * seqLen）
+  - return) attentionMask	有効なトークンは 1、パディングは 0（batch_size * seqLen）
+  - return) seqLen			バッチ内の最長のトークン数
+*/
+func padBatch(batchIds [][]uint32) (inputIds []int64, attentionMask []int64, seqLen int) {
+	for _, ids := range batchIds {
+		seqLen = max(seqLen, len(ids))
 	}
-	onnxruntimePath := os.Getenv("LIBRARY_PATH") + "/libonnxruntime.so"
-	modelPath := os.Getenv("DOWNLOAD_DIR") + "/" + os.Getenv("SAVED_MODEL_PATH")
 
-	// ONNX Runtime 環境の初期化
-	onnxruntime_go.SetSharedLibraryPath(onnxruntimePath)
-	err = onnxruntime_go.InitializeEnvironment()
-	if err != nil {
-		return nil, fmt.Errorf("ONNX Runtime の初期化に失敗しました: %v", err)
+	inputIds = make([]int64, len(batchIds)*seqLen)
+	attentionMask = make([]int64, len(batchIds)*seqLen)
+	for i, ids := range batchIds {
+		for j, id := range ids {
+			inputIds[i*seqLen+j] = int64(id)
+			attentionMask[i*seqLen+j] = 1
+		}
 	}
-	defer onnxruntime_go.DestroyEnvironment()
 
-	// トークンIDを int64 に変換（BERT系モデルで一般的）
-	inputData := make([]int64, len(tokenIds))
-	for i, id := range tokenIds {
-		inputData[i] = int64(id)
-	}
+	return inputIds, attentionMask, seqLen
+}
 
-	// token_type_ids を作成（単一文なので全て0で初期化）、attention_mask （全トークン有効のため、1で初期化）
-	tokenTypeData := make([]int64, len(tokenIds))
-	attentionMaskData := make([]int64, len(tokenIds))
-	for i := range attentionMaskData {
-		attentionMaskData[i] = 1
+// ONNX推論用のヘルパー関数（複数のトークンID列をパディングして 1 回の推論でまとめてベクトル化する）
+func (e *Embedder) vectorizeBatch(batchIds [][]uint32) (vectors [][]float32, err error) {
+	if len(batchIds) == 0 {
+		return [][]float32{}, nil
 	}
+	modelVectorLength := int64(e.config.VectorLength)
+	batchSize := len(batchIds)
+
+	// トークンIDをパディングして int64 に変換（BERT系モデルで一般的）、attention_mask はパディング位置のみ 0
+	inputData, attentionMaskData, seqLen := padBatch(batchIds)
 
-	// 入力テンソルの形状を実際のトークン数に合わせて調整
-	inputShape := onnxruntime_go.NewShape(1, int64(len(tokenIds)))
+	// token_type_ids を作成（単一文なので全て0で初期化）
+	tokenTypeData := make([]int64, len(inputData))
+
+	// 入力テンソルの形状: [batch_size, sequence_length]
+	inputShape := onnxruntime_go.NewShape(int64(batchSize), int64(seqLen))
 
 	// input_ids, token_type_ids, attention_mask テンソルを作成
-	inputTensor, _ := onnxruntime_go.NewTensor(inputShape, inputData)
+	inputTensor, err := onnxruntime_go.NewTensor(inputShape, inputData)
+	if err != nil {
+		return nil, fmt.Errorf("入力テンソルの作成に失敗しました: %v", err)
+	}
 	defer inputTensor.Destroy()
-	tokenTypeTensor, _ := onnxruntime_go.NewTensor(inputShape, tokenTypeData)
+	tokenTypeTensor, err := onnxruntime_go.NewTensor(inputShape, tokenTypeData)
+	if err != nil {
+		return nil, fmt.Errorf("入力テンソルの作成に失敗しました: %v", err)
+	}
 	defer tokenTypeTensor.Destroy()
-	attentionMaskTensor, _ := onnxruntime_go.NewTensor(inputShape, attentionMaskData)
+	attentionMaskTensor, err := onnxruntime_go.NewTensor(inputShape, attentionMaskData)
+	if err != nil {
+		return nil, fmt.Errorf("入力テンソルの作成に失敗しました: %v", err)
+	}
 	defer attentionMaskTensor.Destroy()
 
 	// 出力テンソルの形状: [batch_size, sequence_length, hidden_size]
-	outputShape := onnxruntime_go.NewShape(1, int64(len(tokenIds)), modelVectorLength)
+	outputShape := onnxruntime_go.NewShape(int64(batchSize), int64(seqLen), modelVectorLength)
 	outputTensor, err := onnxruntime_go.NewEmptyTensor[float32](outputShape)
 	if err != nil {
 		return nil, fmt.Errorf("出力テンソルの作成に失敗しました: %v", err)
 	}
 	defer outputTensor.Destroy()
 
-	// セッション作成（実際のモデルファイルパスと入出力名を使用）
-	inputNames := []string{"input_ids", "attention_mask", "token_type_ids"} // 3つの入力を指定
-	outputNames := []string{"last_hidden_state"}                            // BERT系モデルの一般的な出力名
-	session, err := onnxruntime_go.NewAdvancedSession(
-		modelPath, inputNames, outputNames,
+	// モデル推論実行（セッションは起動時に作成したものを使い回す）
+	err = e.session.Run(
 		[]onnxruntime_go.Value{inputTensor, attentionMaskTensor, tokenTypeTensor},
-		[]onnxruntime_go.Value{outputTensor}, nil)
-	if err != nil {
-		return nil, fmt.Errorf("セッションの作成に失敗しました: %v", err)
-	}
-	defer session.Destroy()
-
-	// モデル推論実行
-	err = session.Run()
+		[]onnxruntime_go.Value{outputTensor})
 	if err != nil {
 		return nil, fmt.Errorf("推論の実行に失敗しました: %v", err)
 	}
 
-	// 出力データを取得、ライブラリの使用か何らかの理由で1次元の float32 スライス（384*トークン数）として返される
-	outputData := outputTensor.GetData()
-
-	// 各トークンの埋め込みを平均化して文全体の埋め込みを計算、マスクは考慮しない（すべてのトークンが有効と仮定）
-	hiddenSize := int(modelVectorLength) // モデルの隠れ層の次元数
-	sentenceVector = make([]float32, hiddenSize)
-	for i := 0; i < hiddenSize; i++ {
-		for j := 0; j < len(tokenIds); j++ {
-			sentenceVector[i] += outputData[j*hiddenSize+i]
-		}
-		sentenceVector[i] /= float32(len(tokenIds)) // 平均化
-	}
-
-	return sentenceVector, nil
+	// 出力データは1次元の float32 スライス（batch_size*トークン数*384）として返されるため、テキストごとに設定されたプーリングと正規化を行う
+	return poolBatch(e.pooling, e.config.Normalize, outputTensor.GetData(), attentionMaskData, batchSize, seqLen, int(modelVectorLength)), nil
 }
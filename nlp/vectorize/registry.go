@@ -0,0 +1,133 @@
+package vectorize
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// 名前付きの複数の埋め込みモデルを保持する構造体（起動時に読み込み、全リクエストで使い回す）
+type Registry struct {
+	embedders   map[string]*Embedder
+	names       []string // 設定ファイルに記載された順番のモデル名
+	defaultName string   // モデルが指定されなかった場合に使用するモデル名（先頭のモデル）
+}
+
+/*
+モデル設定ファイル（models.json）から埋め込みモデルの設定を読み込む関数
+未指定の任意項目（max_batch_size, pooling, normalize, query_prefix, passage_prefix）はデフォルト値になる
+  - path			設定ファイルのパス（JSON 配列、先頭のモデルがデフォルトになる）
+  - return) configs	埋め込みモデルの設定の配列
+  - return) err		エラー
+*/
+func LoadModelConfigs(path string) (configs []EmbedderConfig, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("モデル設定ファイルの読み込みに失敗しました: %v", err)
+	}
+
+	var rawConfigs []json.RawMessage
+	if err := json.Unmarshal(data, &rawConfigs); err != nil {
+		return nil, fmt.Errorf("モデル設定ファイルのデコードに失敗しました: %v", err)
+	}
+	if len(rawConfigs) == 0 {
+		return nil, fmt.Errorf("モデル設定ファイルにモデルがありません: %s", path)
+	}
+
+	// デフォルト値を設定した構造体にデコードし、指定された項目のみ上書きする
+	for _, rawConfig := range rawConfigs {
+		config := defaultEmbedderConfig()
+		if err := json.Unmarshal(rawConfig, &config); err != nil {
+			return nil, fmt.Errorf("モデル設定のデコードに失敗しました: %v", err)
+		}
+		if err := config.validate(); err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+/*
+埋め込みモデルの設定をすべて読み込んで Registry を作成する関数
+  - configs			埋め込みモデルの設定の配列（先頭のモデルがデフォルトになる）
+  - return) r		作成した Registry（不要になったら Close を呼ぶ）
+  - return) err		エラー（モデル名の重複を含む）
+*/
+func NewRegistry(configs []EmbedderConfig) (r *Registry, err error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("埋め込みモデルの設定がありません")
+	}
+
+	r = &Registry{embedders: map[string]*Embedder{}, defaultName: configs[0].ModelName}
+	for _, config := range configs {
+		if _, exists := r.embedders[config.ModelName]; exists {
+			r.Close()
+			return nil, fmt.Errorf("モデル名が重複しています: %s", config.ModelName)
+		}
+
+		e, err := NewEmbedder(config)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("モデルの読み込みに失敗しました: %s: %v", config.ModelName, err)
+		}
+		r.embedders[config.ModelName] = e
+		r.names = append(r.names, config.ModelName)
+	}
+
+	return r, nil
+}
+
+/*
+モデル名から埋め込みモデルを取得する関数
+  - name		モデル名（空文字の場合はデフォルトのモデル）
+  - return) e	埋め込みモデル
+  - return) err	存在しないモデル名の場合はエラー
+*/
+func (r *Registry) Get(name string) (e *Embedder, err error) {
+	if name == "" {
+		name = r.defaultName
+	}
+	e, ok := r.embedders[name]
+	if !ok {
+		return nil, fmt.Errorf("モデルが見つかりません: %s", name)
+	}
+	return e, nil
+}
+
+// デフォルトのモデル名を返す関数
+func (r *Registry) DefaultName() string {
+	return r.defaultName
+}
+
+// 読み込んだすべての埋め込みモデルの設定を設定ファイルの順番で返す関数
+func (r *Registry) Configs() (configs []EmbedderConfig) {
+	for _, name := range r.names {
+		configs = append(configs, r.embedders[name].Config())
+	}
+	return configs
+}
+
+// 起動時の推論の確認（ウォームアップ）に使用するテキスト
+const warmUpText = "ウォームアップ"
+
+/*
+読み込んだすべての埋め込みモデルで一度推論する関数（初回のリクエストの遅延をなくし、推論できることを確認する）
+  - return) err		エラー（推論に失敗したモデル名を含む）
+*/
+func (r *Registry) WarmUp() (err error) {
+	for _, name := range r.names {
+		if _, _, err := r.embedders[name].ConvertToVector(warmUpText, true); err != nil {
+			return fmt.Errorf("モデルの推論に失敗しました: %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// 読み込んだすべての埋め込みモデルを解放する関数
+func (r *Registry) Close() {
+	for _, e := range r.embedders {
+		e.Close()
+	}
+}
@@ -0,0 +1,330 @@
+package vectorize
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"textnorm"
+
+	"github.com/daulet/tokenizers"
+	"github.com/yalue/onnxruntime_go"
+)
+
+// クロスエンコーダー（リランカー）の設定
+type RerankerConfig struct {
+	ModelName      string `json:"model_name"`       // モデル名（レスポンスに記録される）
+	ModelPath      string `json:"model_path"`       // ONNX モデルファイルのパス
+	TokenizerPath  string `json:"tokenizer_path"`   // tokenizer.json のパス
+	LibraryPath    string `json:"-"`                // libonnxruntime.so のパス（埋め込みモデルと共通）
+	MaxTokenLength int    `json:"max_token_length"` // クエリと文書を合わせた最大トークン長（超える場合は文書の末尾を切り捨てる）
+	MaxBatchSize   int    `json:"max_batch_size"`   // 1 回の推論でまとめてスコアを計算する組の数の上限
+	NumLabels      int    `json:"num_labels"`       // モデルの出力（logits）のラベル数（1 の場合はシグモイド、2 以上の場合は最後のラベルのソフトマックス）
+	PairFormat     string `json:"pair_format"`      // クエリと文書の組の形式（bert, xlm-roberta のいずれか、モデルのトークナイザーに合わせて選択する）
+}
+
+// クエリと文書の組の形式（モデルの学習時の入力の形式に合わせて選択する）
+const (
+	PairFormatBert       = "bert"        // [CLS] クエリ [SEP] 文書 [SEP]、token_type_ids はクエリ側を 0、文書側を 1
+	PairFormatXlmRoberta = "xlm-roberta" // <s> クエリ </s></s> 文書 </s>、token_type_ids を入力しない
+)
+
+// クエリと文書の組を入力して関連度を出力するクロスエンコーダーを保持する構造体（起動時に一度だけ読み込んで使い回す）
+type Reranker struct {
+	config    RerankerConfig
+	tokenizer *tokenizers.Tokenizer
+	session   *onnxruntime_go.DynamicAdvancedSession
+}
+
+/*
+環境変数からリランカーの設定を読み込む関数（RERANKER_MODEL_PATH が未設定の場合はリランカーを使用しない）
+  - return) config	リランカーの設定
+  - return) enabled	リランカーを使用するかどうか
+  - return) err		エラー
+*/
+func RerankerConfigFromEnv() (config RerankerConfig, enabled bool, err error) {
+	if os.Getenv("RERANKER_MODEL_PATH") == "" {
+		return config, false, nil
+	}
+
+	config = RerankerConfig{
+		ModelName:      os.Getenv("RERANKER_MODEL_NAME"),
+		ModelPath:      os.Getenv("RERANKER_MODEL_PATH"),
+		TokenizerPath:  os.Getenv("RERANKER_TOKENIZER_PATH"),
+		LibraryPath:    os.Getenv("LIBRARY_PATH") + "/libonnxruntime.so",
+		MaxTokenLength: 512,
+		MaxBatchSize:   defaultMaxBatchSize,
+		NumLabels:      1,
+		PairFormat:     PairFormatBert,
+	}
+	if value := os.Getenv("RERANKER_PAIR_FORMAT"); value != "" {
+		config.PairFormat = value
+	}
+
+	// 最大トークン長、バッチサイズ、ラベル数は任意設定（未設定の場合はデフォルト値）
+	for _, value := range []struct {
+		name   string
+		target *int
+	}{
+		{"RERANKER_MAX_TOKEN_LENGTH", &config.MaxTokenLength},
+		{"RERANKER_MAX_BATCH_SIZE", &config.MaxBatchSize},
+		{"RERANKER_NUM_LABELS", &config.NumLabels},
+	} {
+		if envValue := os.Getenv(value.name); envValue != "" {
+			*value.target, err = strconv.Atoi(envValue)
+			if err != nil {
+				return config, false, fmt.Errorf("%s 環境変数が無効です: %s", value.name, envValue)
+			}
+		}
+	}
+
+	if config.ModelName == "" || config.TokenizerPath == "" {
+		return config, false, fmt.Errorf("RERANKER_MODEL_NAME, RERANKER_TOKENIZER_PATH 環境変数が設定されていません")
+	}
+	if config.MaxTokenLength <= 0 || config.MaxBatchSize <= 0 || config.NumLabels <= 0 {
+		return config, false, fmt.Errorf("RERANKER_MAX_TOKEN_LENGTH, RERANKER_MAX_BATCH_SIZE, RERANKER_NUM_LABELS が無効です")
+	}
+	if config.PairFormat != PairFormatBert && config.PairFormat != PairFormatXlmRoberta {
+		return config, false, fmt.Errorf("未対応の組の形式です: %s（RERANKER_PAIR_FORMAT は bert, xlm-roberta のいずれかを指定してください）", config.PairFormat)
+	}
+
+	return config, true, nil
+}
+
+/*
+トークナイザーと ONNX セッションを読み込んで Reranker を作成する関数（ONNX Runtime 環境も初期化する）
+  - config			リランカーの設定
+  - return) r		作成した Reranker（不要になったら Close を呼ぶ）
+  - return) err		エラー
+*/
+func NewReranker(config RerankerConfig) (r *Reranker, err error) {
+	r = &Reranker{config: config}
+
+	// トークナイザーの読み込み（組にしてから最大トークン長に切り詰めるため、ここでは単独のテキストの最大トークン長で切り捨てる）
+	tokenizerData, err := os.ReadFile(config.TokenizerPath)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer.json の読み込みに失敗しました: %v", err)
+	}
+	r.tokenizer, err = tokenizers.FromBytesWithTruncation(tokenizerData, uint32(config.MaxTokenLength), tokenizers.TruncationDirectionRight)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer.json ロードエラー: %v", err)
+	}
+
+	// ONNX Runtime 環境の初期化（プロセスで一度だけ）
+	if !onnxruntime_go.IsInitialized() {
+		onnxruntime_go.SetSharedLibraryPath(config.LibraryPath)
+		err = onnxruntime_go.InitializeEnvironment()
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("ONNX Runtime の初期化に失敗しました: %v", err)
+		}
+	}
+
+	// セッション作成、入力は BERT 系の場合は埋め込みモデルと同じ 3 つ（XLM-RoBERTa 系は token_type_ids なし）、出力は組ごとの logits
+	inputNames := []string{"input_ids", "attention_mask"}
+	if config.PairFormat == PairFormatBert {
+		inputNames = append(inputNames, "token_type_ids")
+	}
+	outputNames := []string{"logits"}
+	r.session, err = onnxruntime_go.NewDynamicAdvancedSession(config.ModelPath, inputNames, outputNames, nil)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("セッションの作成に失敗しました: %v", err)
+	}
+
+	return r, nil
+}
+
+// Reranker が保持するトークナイザーとセッションを解放する関数
+func (r *Reranker) Close() {
+	if r.session != nil {
+		r.session.Destroy()
+		r.session = nil
+	}
+	if r.tokenizer != nil {
+		r.tokenizer.Close()
+		r.tokenizer = nil
+	}
+}
+
+// リランカーの設定を返す関数
+func (r *Reranker) Config() RerankerConfig {
+	return r.config
+}
+
+/*
+リランカーで一度推論する関数（初回のリクエストの遅延をなくし、推論できることを確認する）
+  - return) err		エラー
+*/
+func (r *Reranker) WarmUp() (err error) {
+	if _, err := r.Rerank(warmUpText, []string{warmUpText}); err != nil {
+		return fmt.Errorf("リランカーの推論に失敗しました: %s: %v", r.config.ModelName, err)
+	}
+	return nil
+}
+
+/*
+クエリと各文書の組の関連度を計算する関数
+  - query			検索クエリ
+  - passages		文書（チャンク）の配列
+  - return) scores	文書ごとの関連度（0〜1、1 に近いほど関連が強い、passages と同じ順番）
+  - return) err		エラー
+*/
+func (r *Reranker) Rerank(query string, passages []string) (scores []float32, err error) {
+	// クエリは全文書で共通のため一度だけトークン化（文書と同じ規則で正規化する）
+	queryIds, _ := r.tokenizer.Encode(textnorm.Normalize(query), true)
+
+	pairIds := make([][]uint32, len(passages))
+	pairTypeIds := make([][]int64, len(passages))
+	for i, passage := range passages {
+		passageIds, _ := r.tokenizer.Encode(textnorm.Normalize(passage), true)
+		pairIds[i], pairTypeIds[i] = buildPair(queryIds, passageIds, r.config.MaxTokenLength, r.config.PairFormat)
+	}
+
+	scores = make([]float32, 0, len(passages))
+	for start := 0; start < len(passages); start += r.config.MaxBatchSize {
+		end := min(start+r.config.MaxBatchSize, len(passages))
+		batchScores, err := r.scoreBatch(pairIds[start:end], pairTypeIds[start:end])
+		if err != nil {
+			fmt.Printf("ONNX推論実行エラー: %v\n", err)
+			return nil, err
+		}
+		scores = append(scores, batchScores...)
+	}
+
+	return scores, nil
+}
+
+/*
+クエリと文書のトークンID列（それぞれ特殊トークン付き）を、クロスエンコーダーに入力する 1 つの組にする関数
+BERT 系は [CLS] クエリ [SEP] 文書 [SEP] とし、token_type_ids はクエリ側を 0、文書側を 1 にする
+XLM-RoBERTa 系は <s> クエリ </s></s> 文書 </s> とし、token_type_ids はすべて 0 にする（モデルには入力しない）
+  - queryIds		クエリのトークンID列（[CLS] クエリ [SEP] または <s> クエリ </s>）
+  - passageIds		文書のトークンID列（[CLS] 文書 [SEP] または <s> 文書 </s>）
+  - maxTokenLength	組の最大トークン長（超える場合は文書の末尾を切り捨てる）
+  - pairFormat		組の形式（PairFormatBert, PairFormatXlmRoberta）
+  - return) ids		組のトークンID列
+  - return) typeIds	組の token_type_ids
+*/
+func buildPair(queryIds []uint32, passageIds []uint32, maxTokenLength int, pairFormat string) (ids []uint32, typeIds []int64) {
+	// クエリが長すぎる場合は最大トークン長の半分までにする（末尾の [SEP] は残す）
+	if limit := maxTokenLength / 2; len(queryIds) > limit && limit >= 2 {
+		queryIds = append(append([]uint32{}, queryIds[:limit-1]...), queryIds[len(queryIds)-1])
+	}
+
+	// 文書は先頭の [CLS]（<s>）を除き、残りの長さに収まるように本文の末尾を切り捨てる（末尾の [SEP]（</s>）は残す）
+	passageBody := []uint32{}
+	var passageSep []uint32
+	if len(passageIds) >= 2 {
+		passageBody = passageIds[1 : len(passageIds)-1]
+		passageSep = passageIds[len(passageIds)-1:]
+	}
+
+	// XLM-RoBERTa 系はクエリと文書の間の区切りを </s></s> にする
+	var pairSep []uint32
+	if pairFormat == PairFormatXlmRoberta && len(queryIds) > 0 {
+		pairSep = queryIds[len(queryIds)-1:]
+	}
+	if room := maxTokenLength - len(queryIds) - len(pairSep) - len(passageSep); len(passageBody) > room {
+		passageBody = passageBody[:max(room, 0)]
+	}
+
+	ids = make([]uint32, 0, len(queryIds)+len(pairSep)+len(passageBody)+len(passageSep))
+	ids = append(ids, queryIds...)
+	ids = append(ids, pairSep...)
+	ids = append(ids, passageBody...)
+	ids = append(ids, passageSep...)
+	typeIds = make([]int64, len(ids))
+	if pairFormat == PairFormatBert {
+		for i := len(queryIds); i < len(ids); i++ {
+			typeIds[i] = 1
+		}
+	}
+
+	return ids, typeIds
+}
+
+// ONNX推論用のヘルパー関数（複数の組をパディングして 1 回の推論でまとめて関連度を計算する）
+func (r *Reranker) scoreBatch(batchIds [][]uint32, batchTypeIds [][]int64) (scores []float32, err error) {
+	if len(batchIds) == 0 {
+		return []float32{}, nil
+	}
+	batchSize := len(batchIds)
+	numLabels := r.config.NumLabels
+
+	// トークンIDと attention_mask は埋め込みと同じ方法でパディングし、token_type_ids のパディングは 0 にする
+	inputData, attentionMaskData, seqLen := padBatch(batchIds)
+	tokenTypeData := make([]int64, len(inputData))
+	for i, typeIds := range batchTypeIds {
+		copy(tokenTypeData[i*seqLen:], typeIds)
+	}
+
+	// 入力テンソルの形状: [batch_size, sequence_length]
+	inputShape := onnxruntime_go.NewShape(int64(batchSize), int64(seqLen))
+	inputTensor, err := onnxruntime_go.NewTensor(inputShape, inputData)
+	if err != nil {
+		return nil, fmt.Errorf("入力テンソルの作成に失敗しました: %v", err)
+	}
+	defer inputTensor.Destroy()
+	tokenTypeTensor, err := onnxruntime_go.NewTensor(inputShape, tokenTypeData)
+	if err != nil {
+		return nil, fmt.Errorf("入力テンソルの作成に失敗しました: %v", err)
+	}
+	defer tokenTypeTensor.Destroy()
+	attentionMaskTensor, err := onnxruntime_go.NewTensor(inputShape, attentionMaskData)
+	if err != nil {
+		return nil, fmt.Errorf("入力テンソルの作成に失敗しました: %v", err)
+	}
+	defer attentionMaskTensor.Destroy()
+
+	// 出力テンソルの形状: [batch_size, num_labels]
+	outputShape := onnxruntime_go.NewShape(int64(batchSize), int64(numLabels))
+	outputTensor, err := onnxruntime_go.NewEmptyTensor[float32](outputShape)
+	if err != nil {
+		return nil, fmt.Errorf("出力テンソルの作成に失敗しました: %v", err)
+	}
+	defer outputTensor.Destroy()
+
+	// 入力はセッション作成時の inputNames と同じ順番（XLM-RoBERTa 系は token_type_ids なし）
+	inputs := []onnxruntime_go.Value{inputTensor, attentionMaskTensor}
+	if r.config.PairFormat == PairFormatBert {
+		inputs = append(inputs, tokenTypeTensor)
+	}
+	err = r.session.Run(inputs, []onnxruntime_go.Value{outputTensor})
+	if err != nil {
+		return nil, fmt.Errorf("推論の実行に失敗しました: %v", err)
+	}
+
+	return logitsToScores(outputTensor.GetData(), batchSize, numLabels), nil
+}
+
+/*
+クロスエンコーダーの出力（logits）を 0〜1 の関連度に変換する関数
+  - logits		出力データ（batch_size * num_labels）
+  - batchSize	組の数
+  - numLabels	ラベル数（1 の場合はシグモイド、2 以上の場合は最後のラベル（関連あり）のソフトマックス）
+  - return)		組ごとの関連度
+*/
+func logitsToScores(logits []float32, batchSize int, numLabels int) (scores []float32) {
+	scores = make([]float32, batchSize)
+	for i := 0; i < batchSize; i++ {
+		row := logits[i*numLabels : (i+1)*numLabels]
+		if numLabels == 1 {
+			scores[i] = float32(1 / (1 + math.Exp(-float64(row[0]))))
+			continue
+		}
+
+		// オーバーフローを防ぐため最大値を引いてからソフトマックスを計算
+		maxLogit := row[0]
+		for _, logit := range row {
+			maxLogit = max(maxLogit, logit)
+		}
+		var sum float64
+		for _, logit := range row {
+			sum += math.Exp(float64(logit - maxLogit))
+		}
+		scores[i] = float32(math.Exp(float64(row[numLabels-1]-maxLogit)) / sum)
+	}
+
+	return scores
+}
@@ -2,61 +2,107 @@ package vectorize
 
 import (
 	"fmt"
-	"os"
-	"strconv"
+	"sort"
+	"textnorm"
 )
 
-func ConvertToVector(text string, isQuery bool) (chunks []string, vectors [][]float32, err error) {
-	if !isQuery {
-		// マークダウンのリンクを置換
-		text = replaceLinks(text)
-	}
-
-	// テキストを正規化
-	normalizedText := normalizeText(text)
-
-	// 環境変数から最大トークン長とオーバーラップ長を取得
-	maxTokenLengthStr := os.Getenv("MAX_TOKEN_LENGTH")
-	overlapTokenLengthStr := os.Getenv("OVERLAP_TOKEN_LENGTH")
-	maxTokenLength, err := strconv.Atoi(maxTokenLengthStr)
+/*
+テキストを正規化・チャンク分割して、チャンクごとにベクトル化する関数
+  - text			変換するテキスト
+  - isQuery			クエリかどうかの真偽値（True なら QueryPrefix、False なら PassagePrefix が文頭に付与される）
+  - return) chunks	チャンクの配列
+  - return) vectors	チャンクごとのベクトルの2次元配列
+  - return) err		エラー
+*/
+func (e *Embedder) ConvertToVector(text string, isQuery bool) (chunks []string, vectors [][]float32, err error) {
+	chunksList, vectorsList, err := e.ConvertToVectorBatch([]string{text}, isQuery)
 	if err != nil {
-		fmt.Printf("MAX_TOKEN_LENGTH の変換エラー: %v\n", err)
 		return nil, nil, err
 	}
-	overlapTokenLength, err := strconv.Atoi(overlapTokenLengthStr)
-	if err != nil {
-		fmt.Printf("OVERLAP_TOKEN_LENGTH の変換エラー: %v\n", err)
-		return nil, nil, err
+
+	return chunksList[0], vectorsList[0], nil
+}
+
+/*
+複数のテキストをそれぞれ正規化・チャンク分割し、全チャンクをバッチ推論でまとめてベクトル化する関数
+  - texts				変換するテキストの配列
+  - isQuery				クエリかどうかの真偽値（True なら QueryPrefix、False なら PassagePrefix が文頭に付与される）
+  - return) chunksList	テキストごとのチャンクの配列（texts と同じ順番）
+  - return) vectorsList	テキストごとの、チャンクごとのベクトルの2次元配列（texts と同じ順番）
+  - return) err			エラー
+*/
+func (e *Embedder) ConvertToVectorBatch(texts []string, isQuery bool) (chunksList [][]string, vectorsList [][][]float32, err error) {
+	// 最大トークン長とオーバーラップ長はモデルの設定から取得
+	maxTokenLength := e.config.MaxTokenLength
+	overlapTokenLength := e.config.OverlapTokenLength
+
+	// 全テキストのチャンクを 1 つの列にまとめ、元のテキストとチャンクの位置を保持する
+	type chunkRef struct {
+		textIndex  int
+		chunkIndex int
+		ids        []uint32
 	}
+	var refs []chunkRef
 
-	// テキストを分割（チャンキング）
-	chunks = chunkText(normalizedText, maxTokenLength-3, overlapTokenLength) // -3 はプレフィックス分、-103 はオーバーラップ
+	chunksList = make([][]string, len(texts))
+	vectorsList = make([][][]float32, len(texts))
+	for i, text := range texts {
+		if !isQuery {
+			// マークダウンのリンクを置換
+			text = replaceLinks(text)
+		}
+
+		// テキストを正規化
+		normalizedText := textnorm.Normalize(text)
+
+		// テキストを分割（チャンキング）
+		chunks := e.chunkText(normalizedText, maxTokenLength-3, overlapTokenLength) // -3 はプレフィックス分、-103 はオーバーラップ
+		chunksList[i] = chunks
+		vectorsList[i] = make([][]float32, len(chunks))
 
-	// チャンク数分のスライスを確保
-	vectors = make([][]float32, len(chunks))
+		for j, chunk := range chunks {
+			// プレフィックスの付与（モデルの設定で指定されたもの）
+			if isQuery {
+				chunk = e.config.QueryPrefix + chunk
+			} else {
+				chunk = e.config.PassagePrefix + chunk
+			}
 
-	for i, chunk := range chunks {
-		// プレフィックスの付与
-		if isQuery {
-			chunk = "query: " + chunk
-		} else {
-			chunk = "passage: " + chunk
+			// トークン化
+			ids, err := e.tokenize(chunk)
+			if err != nil {
+				fmt.Printf("トークナイズエラー: %v\n", err)
+				return nil, nil, err
+			}
+			refs = append(refs, chunkRef{textIndex: i, chunkIndex: j, ids: ids})
 		}
+	}
 
-		// トークン化
-		ids, err := tokenize(chunk)
-		if err != nil {
-			fmt.Printf("トークナイズエラー: %v\n", err)
-			return nil, nil, err
+	// トークン数の近いチャンク同士をまとめるとパディングが減るため、トークン数順に並べてからバッチに分ける
+	sort.SliceStable(refs, func(a, b int) bool {
+		return len(refs[a].ids) < len(refs[b].ids)
+	})
+
+	batchSize := e.config.MaxBatchSize
+	for start := 0; start < len(refs); start += batchSize {
+		end := min(start+batchSize, len(refs))
+		batchIds := make([][]uint32, 0, end-start)
+		for _, ref := range refs[start:end] {
+			batchIds = append(batchIds, ref.ids)
 		}
 
 		// ベクトル化
-		vectors[i], err = vectorize(ids)
+		vectors, err := e.vectorizeBatch(batchIds)
 		if err != nil {
 			fmt.Printf("ONNX推論実行エラー: %v\n", err)
 			return nil, nil, err
 		}
+
+		// 元のテキストとチャンクの位置に戻す
+		for k, ref := range refs[start:end] {
+			vectorsList[ref.textIndex][ref.chunkIndex] = vectors[k]
+		}
 	}
 
-	return chunks, vectors, nil
+	return chunksList, vectorsList, nil
 }
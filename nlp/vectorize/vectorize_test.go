@@ -1,13 +1,36 @@
 package vectorize
 
 import (
+	"math"
+	"os"
 	"reflect"
 	"testing"
+
+	"github.com/daulet/tokenizers"
 )
 
 // 単体テスト（外部依存がない関数のテスト）を定義
 // `docker compose exec nlp go test ./vectorize`
 
+// テスト用にトークナイザーのみを読み込んだ Embedder を作成する（ONNX Runtime は使用しない）
+func newTestEmbedder(t *testing.T) *Embedder {
+	config, err := EmbedderConfigFromEnv()
+	if err != nil {
+		t.Fatalf("設定の読み込みに失敗しました: %v", err)
+	}
+	tokenizerData, err := os.ReadFile(config.TokenizerPath)
+	if err != nil {
+		t.Fatalf("tokenizer.json の読み込みに失敗しました: %v", err)
+	}
+	tk, err := tokenizers.FromBytesWithTruncation(tokenizerData, uint32(config.MaxTokenLength), tokenizers.TruncationDirectionRight)
+	if err != nil {
+		t.Fatalf("tokenizer.json ロードエラー: %v", err)
+	}
+	t.Cleanup(func() { tk.Close() })
+
+	return &Embedder{config: config, tokenizer: tk}
+}
+
 func TestReplaceLinks(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -62,12 +85,241 @@ func TestChunkText(t *testing.T) {
 すもももももももものうち`}},
 	}
 
+	embedder := newTestEmbedder(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := embedder.chunkText(tc.input, 60, 15)
+			if !reflect.DeepEqual(output, tc.expectedOutput) {
+				t.Errorf("期待される出力 '%v' ですが、実際は '%v' でした", tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestPadBatch(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		input                 [][]uint32
+		expectedInputIds      []int64
+		expectedAttentionMask []int64
+		expectedSeqLen        int
+	}{
+		{"同じ長さ", [][]uint32{{5, 6}, {7, 8}}, []int64{5, 6, 7, 8}, []int64{1, 1, 1, 1}, 2},
+		{"長さが異なる", [][]uint32{{5}, {6, 7, 8}}, []int64{5, 0, 0, 6, 7, 8}, []int64{1, 0, 0, 1, 1, 1}, 3},
+		{"空のバッチ", [][]uint32{}, []int64{}, []int64{}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inputIds, attentionMask, seqLen := padBatch(tc.input)
+			if !reflect.DeepEqual(inputIds, tc.expectedInputIds) {
+				t.Errorf("期待される inputIds '%v' ですが、実際は '%v' でした", tc.expectedInputIds, inputIds)
+			}
+			if !reflect.DeepEqual(attentionMask, tc.expectedAttentionMask) {
+				t.Errorf("期待される attentionMask '%v' ですが、実際は '%v' でした", tc.expectedAttentionMask, attentionMask)
+			}
+			if seqLen != tc.expectedSeqLen {
+				t.Errorf("期待される seqLen '%d' ですが、実際は '%d' でした", tc.expectedSeqLen, seqLen)
+			}
+		})
+	}
+}
+
+func TestPoolBatch(t *testing.T) {
+	// batch_size=2, seqLen=3, hiddenSize=2 の出力（2 件目の 3 トークン目はパディング）
+	outputData := []float32{
+		1, 6, 3, 4, 5, 2,
+		7, 8, 9, 0, 100, 100,
+	}
+	attentionMask := []int64{1, 1, 1, 1, 1, 0}
+
+	testCases := []struct {
+		name           string
+		pooling        string
+		expectedOutput [][]float32
+	}{
+		{"平均", PoolingMean, [][]float32{{3, 4}, {8, 4}}},
+		{"先頭トークン", PoolingCLS, [][]float32{{1, 6}, {7, 8}}},
+		{"最大値", PoolingMax, [][]float32{{5, 6}, {9, 8}}},
+		{"最後のトークン", PoolingLast, [][]float32{{5, 2}, {9, 0}}},
+	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			output := chunkText(tc.input, 60, 15)
+			pool, err := getPoolingFunc(tc.pooling)
+			if err != nil {
+				t.Fatalf("プーリング関数の取得に失敗しました: %v", err)
+			}
+			output := poolBatch(pool, false, outputData, attentionMask, 2, 3, 2)
 			if !reflect.DeepEqual(output, tc.expectedOutput) {
 				t.Errorf("期待される出力 '%v' ですが、実際は '%v' でした", tc.expectedOutput, output)
 			}
 		})
 	}
+
+	if _, err := getPoolingFunc("unknown"); err == nil {
+		t.Errorf("未対応のプーリング戦略でエラーが返されませんでした")
+	}
+}
+
+func TestNormalizeL2(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          []float32
+		expectedOutput []float32
+	}{
+		{"正規化", []float32{3, 4}, []float32{0.6, 0.8}},
+		{"ゼロベクトル", []float32{0, 0}, []float32{0, 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			normalizeL2(tc.input)
+			if !reflect.DeepEqual(tc.input, tc.expectedOutput) {
+				t.Errorf("期待される出力 '%v' ですが、実際は '%v' でした", tc.expectedOutput, tc.input)
+			}
+		})
+	}
+}
+
+func TestLoadModelConfigs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		expectedError bool
+		expectedCount int
+	}{
+		{"任意項目を省略", `[{"model_name": "a", "model_path": "a.onnx", "tokenizer_path": "a.json", "max_token_length": 512, "overlap_token_length": 128, "vector_length": 384}]`, false, 1},
+		{"複数のモデル", `[{"model_name": "a", "model_path": "a.onnx", "tokenizer_path": "a.json", "max_token_length": 512, "overlap_token_length": 128, "vector_length": 384}, {"model_name": "b", "model_path": "b.onnx", "tokenizer_path": "b.json", "max_token_length": 256, "overlap_token_length": 32, "vector_length": 768, "pooling": "cls", "normalize": true, "query_prefix": ""}]`, false, 2},
+		{"必須項目がない", `[{"model_name": "a", "max_token_length": 512, "overlap_token_length": 128, "vector_length": 384}]`, true, 0},
+		{"無効なプーリング", `[{"model_name": "a", "model_path": "a.onnx", "tokenizer_path": "a.json", "max_token_length": 512, "overlap_token_length": 128, "vector_length": 384, "pooling": "sum"}]`, true, 0},
+		{"モデルがない", `[]`, true, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := t.TempDir() + "/models.json"
+			if err := os.WriteFile(path, []byte(tc.input), 0o644); err != nil {
+				t.Fatalf("設定ファイルの作成に失敗しました: %v", err)
+			}
+
+			configs, err := LoadModelConfigs(path)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("期待されるエラーの有無 '%v' ですが、実際は '%v' でした", tc.expectedError, err)
+			}
+			if len(configs) != tc.expectedCount {
+				t.Errorf("期待される件数 '%d' ですが、実際は '%d' でした", tc.expectedCount, len(configs))
+			}
+		})
+	}
+
+	// 省略した任意項目はデフォルト値、指定した項目（空文字を含む）は指定した値になる
+	path := t.TempDir() + "/models.json"
+	os.WriteFile(path, []byte(testCases[1].input), 0o644)
+	configs, _ := LoadModelConfigs(path)
+	if configs[0].Pooling != PoolingMean || configs[0].Normalize || configs[0].QueryPrefix != "query: " || configs[0].MaxBatchSize != defaultMaxBatchSize {
+		t.Errorf("デフォルト値が設定されていません: %+v", configs[0])
+	}
+	if configs[1].Pooling != PoolingCLS || !configs[1].Normalize || configs[1].QueryPrefix != "" || configs[1].PassagePrefix != "passage: " {
+		t.Errorf("指定した値が設定されていません: %+v", configs[1])
+	}
+}
+
+func TestBuildPair(t *testing.T) {
+	// BERT 系は 101 = [CLS]、102 = [SEP]、XLM-RoBERTa 系は 0 = <s>、2 = </s> とする
+	testCases := []struct {
+		name            string
+		queryIds        []uint32
+		passageIds      []uint32
+		maxTokenLength  int
+		pairFormat      string
+		expectedIds     []uint32
+		expectedTypeIds []int64
+	}{
+		{
+			name:            "最大トークン長に収まる",
+			queryIds:        []uint32{101, 1, 2, 102},
+			passageIds:      []uint32{101, 3, 4, 5, 102},
+			maxTokenLength:  16,
+			pairFormat:      PairFormatBert,
+			expectedIds:     []uint32{101, 1, 2, 102, 3, 4, 5, 102},
+			expectedTypeIds: []int64{0, 0, 0, 0, 1, 1, 1, 1},
+		},
+		{
+			name:            "文書の末尾を切り捨てて [SEP] を残す",
+			queryIds:        []uint32{101, 1, 102},
+			passageIds:      []uint32{101, 3, 4, 5, 6, 102},
+			maxTokenLength:  6,
+			pairFormat:      PairFormatBert,
+			expectedIds:     []uint32{101, 1, 102, 3, 4, 102},
+			expectedTypeIds: []int64{0, 0, 0, 1, 1, 1},
+		},
+		{
+			name:            "長すぎるクエリは最大トークン長の半分にする",
+			queryIds:        []uint32{101, 1, 2, 3, 4, 5, 102},
+			passageIds:      []uint32{101, 6, 7, 102},
+			maxTokenLength:  8,
+			pairFormat:      PairFormatBert,
+			expectedIds:     []uint32{101, 1, 2, 102, 6, 7, 102},
+			expectedTypeIds: []int64{0, 0, 0, 0, 1, 1, 1},
+		},
+		{
+			name:            "XLM-RoBERTa 系は </s></s> で区切り token_type_ids はすべて 0",
+			queryIds:        []uint32{0, 10, 11, 2},
+			passageIds:      []uint32{0, 12, 13, 14, 2},
+			maxTokenLength:  16,
+			pairFormat:      PairFormatXlmRoberta,
+			expectedIds:     []uint32{0, 10, 11, 2, 2, 12, 13, 14, 2},
+			expectedTypeIds: []int64{0, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:            "XLM-RoBERTa 系は区切りを含めて最大トークン長に切り捨てる",
+			queryIds:        []uint32{0, 10, 2},
+			passageIds:      []uint32{0, 12, 13, 14, 15, 2},
+			maxTokenLength:  7,
+			pairFormat:      PairFormatXlmRoberta,
+			expectedIds:     []uint32{0, 10, 2, 2, 12, 13, 2},
+			expectedTypeIds: []int64{0, 0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ids, typeIds := buildPair(tc.queryIds, tc.passageIds, tc.maxTokenLength, tc.pairFormat)
+			if !reflect.DeepEqual(ids, tc.expectedIds) {
+				t.Errorf("期待される ids '%v' ですが、実際は '%v' でした", tc.expectedIds, ids)
+			}
+			if !reflect.DeepEqual(typeIds, tc.expectedTypeIds) {
+				t.Errorf("期待される typeIds '%v' ですが、実際は '%v' でした", tc.expectedTypeIds, typeIds)
+			}
+		})
+	}
+}
+
+func TestLogitsToScores(t *testing.T) {
+	testCases := []struct {
+		name      string
+		logits    []float32
+		batchSize int
+		numLabels int
+		expected  []float32
+	}{
+		{"ラベル数 1 はシグモイド", []float32{0, 2}, 2, 1, []float32{0.5, 0.880797}},
+		{"ラベル数 2 は最後のラベルのソフトマックス", []float32{0, 0, 1, 3}, 2, 2, []float32{0.5, 0.880797}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scores := logitsToScores(tc.logits, tc.batchSize, tc.numLabels)
+			if len(scores) != len(tc.expected) {
+				t.Fatalf("期待されるスコア数 '%d' ですが、実際は '%d' でした", len(tc.expected), len(scores))
+			}
+			for i := range scores {
+				if math.Abs(float64(scores[i]-tc.expected[i])) > 1e-5 {
+					t.Errorf("期待されるスコア '%v' ですが、実際は '%v' でした", tc.expected, scores)
+					break
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,5 @@
+module textnorm
+
+go 1.24.1
+
+require golang.org/x/text v0.28.0
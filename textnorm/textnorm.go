@@ -0,0 +1,38 @@
+// app と nlp で共通のテキストの正規化を行うパッケージ（ベクトル化されるチャンク・キーワード検索の語・検索クエリを同じ規則で扱うため）
+package textnorm
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	newlinesRe = regexp.MustCompile(`(\n{3,})`)
+	punctRe    = regexp.MustCompile(`([.!?、。ー])(?:[.!?、。ー])+`)
+)
+
+/*
+テキストを正規化する関数
+  - text		正規化するテキスト
+  - return)		正規化したテキスト
+*/
+func Normalize(text string) (normalizedText string) {
+	// Unicode正規化（NFKC）、全角カタカナに統一、半角英数に統一など
+	normalizedText = norm.NFKC.String(text)
+
+	// 小文字化
+	normalizedText = strings.ToLower(normalizedText)
+
+	// 3 回以上の連続する改行を 2 回の改行に置換
+	normalizedText = newlinesRe.ReplaceAllString(normalizedText, "\n\n")
+
+	// 「., !, ?, 、, 。」が複数連続する場合は1つに置換
+	normalizedText = punctRe.ReplaceAllString(normalizedText, "$1")
+
+	// 最初と最後の空白と改行を削除
+	normalizedText = strings.TrimSpace(normalizedText)
+
+	return normalizedText
+}
@@ -0,0 +1,33 @@
+package textnorm
+
+import (
+	"testing"
+)
+
+// 単体テスト（外部依存がない関数のテスト）を定義
+// `cd textnorm && go test ./...`
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"全角英数を半角に統一して小文字化", "ＡＢＣ１２３", "abc123"},
+		{"半角カタカナを全角に統一", "ｶﾀｶﾅ", "カタカナ"},
+		{"連続する改行を 2 回に置換", "a\n\n\n\nb", "a\n\nb"},
+		{"連続する句読点を 1 つに置換", "はい。。。いいえ、、", "はい。いいえ、"},
+		{"連続する長音記号を 1 つに置換", "すごーーい", "すごーい"},
+		{"全角の感嘆符・疑問符を半角に統一して 1 つに置換", "本当！？", "本当!"},
+		{"前後の空白を削除", "  text \n", "text"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Normalize(tc.text)
+			if result != tc.expected {
+				t.Errorf("期待される正規化後のテキスト '%q' ですが、実際は '%q' でした", tc.expected, result)
+			}
+		})
+	}
+}